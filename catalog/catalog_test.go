@@ -0,0 +1,205 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+func TestCachedBookClient(t *testing.T) {
+	ctx := context.Background()
+	upstream := reservation.NewMockBookClient([]reservation.Book{{ID: 2, Title: "Book_2", AvailableCopies: 3}})
+	store := NewMockStore([]reservation.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}})
+	client := NewCachedBookClient(upstream, store)
+
+	// Book 1 is only known locally, as if the book service were down
+	book, err := client.GetBook(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected cached book; got error %v", err)
+	}
+	if book.AvailableCopies != 1 {
+		t.Errorf("Expected AvailableCopies %d; got %d", 1, book.AvailableCopies)
+	}
+
+	// Book 2 is fetched from the book service and cached
+	_, err = client.GetBook(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Get(ctx, 2)
+	if err != nil {
+		t.Errorf("Expected book 2 to be cached; got %v", err)
+	}
+
+	// Updates go to the book service and are mirrored locally
+	availableCopies, err := client.AdjustAvailableCopies(ctx, 2, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if availableCopies != 2 {
+		t.Errorf("Expected AvailableCopies %d; got %d", 2, availableCopies)
+	}
+	entry, _ := store.Get(ctx, 2)
+	if entry.AvailableCopies != 2 {
+		t.Errorf("Expected cached AvailableCopies %d; got %d", 2, entry.AvailableCopies)
+	}
+	upstreamBook, _ := upstream.GetBook(ctx, 2)
+	if upstreamBook.AvailableCopies != 2 {
+		t.Errorf("Expected upstream AvailableCopies %d; got %d", 2, upstreamBook.AvailableCopies)
+	}
+
+	// Unknown everywhere
+	_, err = client.GetBook(ctx, 3)
+	if err == nil {
+		t.Error("Expected an error for an unknown book")
+	}
+}
+
+func TestCachedBookClientStaleCache(t *testing.T) {
+	ctx := context.Background()
+	// Another service lent two copies since the book was cached.
+	upstream := reservation.NewMockBookClient([]reservation.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}})
+	store := NewMockStore([]reservation.Book{{ID: 1, Title: "Book_1", AvailableCopies: 3}})
+	client := NewCachedBookClient(upstream, store)
+
+	_, err := client.AdjustAvailableCopies(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstreamBook, _ := upstream.GetBook(ctx, 1)
+	if upstreamBook.AvailableCopies != 2 {
+		t.Errorf("Expected upstream AvailableCopies %d; got %d", 2, upstreamBook.AvailableCopies)
+	}
+	entry, _ := store.Get(ctx, 1)
+	if entry.AvailableCopies != 2 {
+		t.Errorf("Expected cached AvailableCopies %d; got %d", 2, entry.AvailableCopies)
+	}
+}
+
+func TestCachedBookClientRefusesNegativeCounts(t *testing.T) {
+	ctx := context.Background()
+	// The last copy was lent since the book was cached.
+	upstream := reservation.NewMockBookClient([]reservation.Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}})
+	store := NewMockStore([]reservation.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}})
+	client := NewCachedBookClient(upstream, store)
+
+	_, err := client.AdjustAvailableCopies(ctx, 1, -1)
+	if !errors.Is(err, reservation.ErrNoCopiesLeft) {
+		t.Fatalf("Expected %v; got %v", reservation.ErrNoCopiesLeft, err)
+	}
+	upstreamBook, _ := upstream.GetBook(ctx, 1)
+	if upstreamBook.AvailableCopies != 0 {
+		t.Errorf("Expected upstream AvailableCopies %d; got %d", 0, upstreamBook.AvailableCopies)
+	}
+}
+
+func TestGetAvailability(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	start, end := time.Now().Add(24*time.Hour), time.Now().Add(72*time.Hour)
+	books := []reservation.Book{{ID: 1, Title: "Book_1", AvailableCopies: 2}, {ID: 2, Title: "Book_2", AvailableCopies: 1}}
+	repo := reservation.NewMockReservationRepo([]reservation.Reservation{
+		{ID: 1, BookId: 2, UserId: 1, Status: reservation.StatusBooked, StartDate: &start, EndDate: &end},
+	})
+	service := reservation.NewService(repo, NewCachedBookClient(reservation.NewMockBookClient(books), NewMockStore(books)),
+		events.NewMemoryPublisher(), reservation.Policy{LoanPeriod: 14 * 24 * time.Hour, DefaultBranch: "main"})
+	server := gin.New()
+	server.GET("/books/:id/availability", NewHandler(service).GetAvailability)
+
+	testCases := []struct {
+		testName          string
+		bookId            string
+		expectedCopies    int64
+		expectedAvailable bool
+	}{
+		{"Copies on the shelf", "1", 2, true},
+		{"Last copy booked", "2", 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/"+tc.bookId+"/availability", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d; got %d", http.StatusOK, w.Code)
+			}
+
+			var response struct {
+				AvailableCopies int64 `json:"available_copies"`
+				Available       bool  `json:"available"`
+			}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.AvailableCopies != tc.expectedCopies || response.Available != tc.expectedAvailable {
+				t.Errorf("Unexpected availability %+v", response)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockStore([]reservation.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}})
+
+	testCases := []struct {
+		testName       string
+		event          BookEvent
+		bookID         int64
+		expectedCached bool
+		expectedCopies int64
+	}{
+		{
+			testName:       "Book created",
+			event:          BookEvent{Type: BookCreated, Data: reservation.Book{ID: 2, Title: "Book_2", AvailableCopies: 4}},
+			bookID:         2,
+			expectedCached: true,
+			expectedCopies: 4,
+		},
+		{
+			testName:       "Book updated",
+			event:          BookEvent{Type: BookUpdated, Data: reservation.Book{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			bookID:         1,
+			expectedCached: true,
+			expectedCopies: 0,
+		},
+		{
+			testName:       "Book deleted",
+			event:          BookEvent{Type: BookDeleted, Data: reservation.Book{ID: 2}},
+			bookID:         2,
+			expectedCached: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := Apply(ctx, store, tc.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entry, err := store.Get(ctx, tc.bookID)
+			if tc.expectedCached != (err == nil) {
+				t.Fatalf("Expected cached=%v; got error %v", tc.expectedCached, err)
+			}
+			if tc.expectedCached && entry.AvailableCopies != tc.expectedCopies {
+				t.Errorf("Expected AvailableCopies %d; got %d", tc.expectedCopies, entry.AvailableCopies)
+			}
+		})
+	}
+}
+
+func TestSyncerDefaultInterval(t *testing.T) {
+	syncer := NewSyncer(nil, nil, 0)
+	if syncer.interval != DefaultSyncInterval {
+		t.Errorf("Expected interval %v; got %v", DefaultSyncInterval, syncer.interval)
+	}
+}
@@ -0,0 +1,65 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+// CachedBookClient answers availability questions from the local catalog and
+// only goes to the book service on a cache miss or to change copies.
+type CachedBookClient struct {
+	upstream reservation.BookClient
+	store    Store
+}
+
+func NewCachedBookClient(upstream reservation.BookClient, store Store) *CachedBookClient {
+	return &CachedBookClient{upstream: upstream, store: store}
+}
+
+func (c *CachedBookClient) GetBook(ctx context.Context, bookID int64) (reservation.Book, error) {
+	entry, err := c.store.Get(ctx, bookID)
+	if err == nil {
+		return entry.Book, nil
+	}
+	if !errors.Is(err, ErrNotCached) {
+		slog.WarnContext(ctx, "Could not read local catalog!", "error", err, "book_id", bookID)
+	}
+
+	book, err := c.upstream.GetBook(ctx, bookID)
+	if err != nil {
+		return reservation.Book{}, err
+	}
+
+	err = c.store.Upsert(ctx, book)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not cache book!", "error", err, "book_id", bookID)
+	}
+
+	return book, nil
+}
+
+// AdjustAvailableCopies changes the count in the book service first, which
+// stays the source of truth, and then mirrors the new count locally. The
+// cached count is never written back, as it may be stale.
+func (c *CachedBookClient) AdjustAvailableCopies(ctx context.Context, bookID, delta int64) (int64, error) {
+	availableCopies, err := c.upstream.AdjustAvailableCopies(ctx, bookID, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	entry, err := c.store.Get(ctx, bookID)
+	if err != nil {
+		return availableCopies, nil
+	}
+	entry.AvailableCopies = availableCopies
+
+	err = c.store.Upsert(ctx, entry.Book)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not update local catalog!", "error", err, "book_id", bookID)
+	}
+
+	return availableCopies, nil
+}
@@ -0,0 +1,43 @@
+package catalog
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+	"github.com/shkuran/go-library-microservices/reservation-service/utils"
+)
+
+type Handler struct {
+	service *reservation.Service
+}
+
+// NewHandler takes a Service whose BookClient is a CachedBookClient.
+func NewHandler(service *reservation.Service) Handler {
+	return Handler{service: service}
+}
+
+// GetAvailability answers from the local catalog, so it keeps working while
+// the book service is briefly down. The count agrees with what a reservation
+// would find: tracked copies and bookings are taken into account.
+func (h Handler) GetAvailability(context *gin.Context) {
+	bookId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse bookId!", err)
+		return
+	}
+
+	book, available, err := h.service.Availability(context.Request.Context(), bookId)
+	if err != nil {
+		utils.HandleInternalServerError(context, "Could not fetch book!", err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"book_id":          book.ID,
+		"title":            book.Title,
+		"available_copies": available,
+		"available":        available > 0,
+	})
+}
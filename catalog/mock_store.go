@@ -0,0 +1,49 @@
+package catalog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+type MockStore struct {
+	mu      sync.Mutex
+	entries map[int64]Entry
+}
+
+func NewMockStore(books []reservation.Book) *MockStore {
+	s := &MockStore{entries: make(map[int64]Entry)}
+	s.Upsert(context.Background(), books...)
+	return s
+}
+
+func (s *MockStore) Get(ctx context.Context, bookID int64) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[bookID]
+	if !ok {
+		return Entry{}, ErrNotCached
+	}
+	return entry, nil
+}
+
+func (s *MockStore) Upsert(ctx context.Context, books ...reservation.Book) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range books {
+		s.entries[b.ID] = Entry{Book: b, SyncedAt: time.Now()}
+	}
+	return nil
+}
+
+func (s *MockStore) Delete(ctx context.Context, bookID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, bookID)
+	return nil
+}
@@ -0,0 +1,83 @@
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+var ErrNotCached = errors.New("book is not in the local catalog")
+
+// Entry is a cached book together with the time it was last refreshed.
+type Entry struct {
+	reservation.Book
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// Store is the local read model of the book service catalog.
+type Store interface {
+	Get(ctx context.Context, bookID int64) (Entry, error)
+	Upsert(ctx context.Context, books ...reservation.Book) error
+	Delete(ctx context.Context, bookID int64) error
+}
+
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) Get(ctx context.Context, bookID int64) (Entry, error) {
+	query := `
+	SELECT id, title, author, isbn, publication_year, available_copies, synced_at
+	FROM books_cache
+	WHERE id = $1
+	`
+	var entry Entry
+	err := s.db.QueryRowContext(ctx, query, bookID).Scan(&entry.ID, &entry.Title, &entry.Author,
+		&entry.ISBN, &entry.PublicationYear, &entry.AvailableCopies, &entry.SyncedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, ErrNotCached
+	}
+
+	return entry, err
+}
+
+func (s *SQLStore) Upsert(ctx context.Context, books ...reservation.Book) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO books_cache (id, title, author, isbn, publication_year, available_copies, synced_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (id) DO UPDATE SET
+		title = EXCLUDED.title,
+		author = EXCLUDED.author,
+		isbn = EXCLUDED.isbn,
+		publication_year = EXCLUDED.publication_year,
+		available_copies = EXCLUDED.available_copies,
+		synced_at = EXCLUDED.synced_at
+	`
+	now := time.Now()
+	for _, b := range books {
+		_, err := tx.ExecContext(ctx, query, b.ID, b.Title, b.Author, b.ISBN, b.PublicationYear, b.AvailableCopies, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) Delete(ctx context.Context, bookID int64) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM books_cache WHERE id = $1", bookID)
+	return err
+}
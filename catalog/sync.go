@@ -0,0 +1,102 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+type Lister interface {
+	ListBooks(ctx context.Context) ([]reservation.Book, error)
+}
+
+// DefaultSyncInterval is used when no sync interval is configured.
+const DefaultSyncInterval = 5 * time.Minute
+
+// Syncer periodically copies the whole catalog of the book service into the
+// local store, covering events that were missed.
+type Syncer struct {
+	upstream Lister
+	store    Store
+	interval time.Duration
+}
+
+// NewSyncer returns a Syncer running every interval, or every
+// DefaultSyncInterval if interval is not positive.
+func NewSyncer(upstream Lister, store Store, interval time.Duration) *Syncer {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	return &Syncer{upstream: upstream, store: store, interval: interval}
+}
+
+// Start syncs right away and then every interval until ctx is cancelled.
+func (s *Syncer) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.Sync(ctx)
+		if err != nil {
+			slog.Error("Could not sync book catalog!", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Syncer) Sync(ctx context.Context) error {
+	books, err := s.upstream.ListBooks(ctx)
+	if err != nil {
+		return err
+	}
+	return s.store.Upsert(ctx, books...)
+}
+
+// BookEvent is a change notification published by the book service.
+type BookEvent struct {
+	Type string           `json:"type"`
+	Data reservation.Book `json:"data"`
+}
+
+const (
+	BookCreated = "BookCreated"
+	BookUpdated = "BookUpdated"
+	BookDeleted = "BookDeleted"
+)
+
+// Apply updates the store from a single book event.
+func Apply(ctx context.Context, store Store, event BookEvent) error {
+	switch event.Type {
+	case BookCreated, BookUpdated:
+		return store.Upsert(ctx, event.Data)
+	case BookDeleted:
+		return store.Delete(ctx, event.Data.ID)
+	default:
+		return fmt.Errorf("unknown book event %q", event.Type)
+	}
+}
+
+// Subscribe applies book-service events received on subject until the
+// returned subscription is drained.
+func Subscribe(conn *nats.Conn, subject string, store Store) (*nats.Subscription, error) {
+	return conn.Subscribe(subject, func(msg *nats.Msg) {
+		var event BookEvent
+		err := json.Unmarshal(msg.Data, &event)
+		if err == nil {
+			err = Apply(context.Background(), store, event)
+		}
+		if err != nil {
+			slog.Error("Could not apply book event!", "error", err, "subject", msg.Subject)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+// Package cmd holds the commands of the service besides serving.
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/db"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+// Import implements the import command, which loads reservations from a
+// file (or standard input for "-") and prints the report as JSON:
+//
+//	reservation-service import [-format csv|ndjson] [-batch-size n] [-dry-run] [-overwrite] file
+//
+// It returns the exit code: 1 when rows were rejected, 2 when the import
+// could not run.
+func Import(conn *sql.DB, dialect db.Dialect, repo reservation.Repository, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", string(reservation.FormatCSV), "file format, csv or ndjson")
+	batchSize := flags.Int("batch-size", reservation.DefaultImportBatchSize, "reservations committed together")
+	dryRun := flags.Bool("dry-run", false, "report the rejected rows without importing anything")
+	overwrite := flags.Bool("overwrite", false, "replace closed reservations with the same ID")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: reservation-service import [flags] file")
+		flags.PrintDefaults()
+		return 2
+	}
+
+	// A dry run must not change the database, not even its schema.
+	if !*dryRun {
+		err = db.Migrate(conn, dialect)
+		if err != nil {
+			slog.Error("Could not migrate the database!", "error", err)
+			return 2
+		}
+	}
+
+	var file io.Reader = os.Stdin
+	if name := flags.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			slog.Error("Could not open the import file!", "error", err)
+			return 2
+		}
+		defer f.Close()
+		file = f
+	}
+
+	report, err := reservation.Import(context.Background(), repo, file, reservation.ImportOptions{
+		Format:    reservation.Format(*format),
+		BatchSize: *batchSize,
+		DryRun:    *dryRun,
+		Overwrite: *overwrite,
+	})
+	if err != nil {
+		slog.Error("Could not import reservations!", "error", err, "rows", report.Rows, "imported", report.Imported)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(report)
+	if err != nil {
+		slog.Error("Could not print the import report!", "error", err)
+		return 2
+	}
+	if len(report.Rejected) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -15,11 +16,77 @@ type Config struct {
 		Password   string `yaml:"pass"`
 		DbName     string `yaml:"dbname"`
 		SslMode    string `yaml:"sslmode"`
+		Path       string `yaml:"path"`
+		ReplicaDSN string `yaml:"replica_dsn"`
 	} `yaml:"database"`
 	Server struct {
-		Host string `yaml:"host"`
-		Port string `yaml:"port"`
+		Host     string `yaml:"host"`
+		Port     string `yaml:"port"`
+		GRPCPort string `yaml:"grpc_port"`
 	} `yaml:"server"`
+	BookService struct {
+		URL     string        `yaml:"url"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"book_service"`
+	UserService struct {
+		URL     string        `yaml:"url"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"user_service"`
+	Catalog struct {
+		SyncInterval time.Duration `yaml:"sync_interval"`
+		Subject      string        `yaml:"subject"`
+	} `yaml:"catalog"`
+	Reservation struct {
+		CancelWindow         time.Duration `yaml:"cancel_window"`
+		LoanPeriod           time.Duration `yaml:"loan_period"`
+		BookingCheckInterval time.Duration `yaml:"booking_check_interval"`
+		MaxRenewals          int64         `yaml:"max_renewals"`
+		DefaultBranch        string        `yaml:"default_branch"`
+		Branches             map[string]struct {
+			LoanPeriod time.Duration `yaml:"loan_period"`
+			MaxLoans   int64         `yaml:"max_loans"`
+		} `yaml:"branches"`
+	} `yaml:"reservation"`
+	Reminders struct {
+		Enabled  bool          `yaml:"enabled"`
+		RunAt    time.Duration `yaml:"run_at"`
+		DueSoon  time.Duration `yaml:"due_soon"`
+		Channels []string      `yaml:"channels"`
+		SMTP     struct {
+			Host     string        `yaml:"host"`
+			Port     string        `yaml:"port"`
+			User     string        `yaml:"user"`
+			Password string        `yaml:"pass"`
+			From     string        `yaml:"from"`
+			Timeout  time.Duration `yaml:"timeout"`
+		} `yaml:"smtp"`
+		Webhook struct {
+			URL     string        `yaml:"url"`
+			Timeout time.Duration `yaml:"timeout"`
+		} `yaml:"webhook"`
+	} `yaml:"reminders"`
+	Events struct {
+		Broker string `yaml:"broker"`
+		NATS   struct {
+			URL           string `yaml:"url"`
+			SubjectPrefix string `yaml:"subject_prefix"`
+		} `yaml:"nats"`
+		Kafka struct {
+			Brokers []string `yaml:"brokers"`
+			Topic   string   `yaml:"topic"`
+		} `yaml:"kafka"`
+	} `yaml:"events"`
+	Tracing struct {
+		Enabled     bool    `yaml:"enabled"`
+		Exporter    string  `yaml:"exporter"`
+		Endpoint    string  `yaml:"endpoint"`
+		Insecure    bool    `yaml:"insecure"`
+		ServiceName string  `yaml:"service_name"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+	Log struct {
+		Level string `yaml:"level"`
+	} `yaml:"log"`
 }
 
 func LoadConfig() *Config {
@@ -0,0 +1,25 @@
+package db
+
+import "fmt"
+
+// Dialect is the flavour of SQL spoken by a database driver. The repositories
+// stick to the SQL both dialects understand; the schema and error reporting
+// differ.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// DialectOf returns the Dialect of a database/sql driver name.
+func DialectOf(driverName string) (Dialect, error) {
+	switch Dialect(driverName) {
+	case Postgres:
+		return Postgres, nil
+	case SQLite:
+		return SQLite, nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q", driverName)
+	}
+}
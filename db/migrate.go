@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// migrationDirs holds the migrations of each dialect. The SQLite schema
+// starts out as one migration matching the Postgres ones up to its version;
+// later changes get a migration in both directories.
+var migrationDirs = map[Dialect]string{
+	Postgres: "migrations",
+	SQLite:   "migrations/sqlite",
+}
+
+// Migrate applies every embedded migration of the dialect that is not yet
+// recorded in the schema_migrations table. Migrations run in file name order,
+// each in its own transaction.
+func Migrate(db *sql.DB, dialect Dialect) error {
+	dir, ok := migrationDirs[dialect]
+	if !ok {
+		return fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+
+	createMigrationsTable := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version VARCHAR(255) PRIMARY KEY NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	);
+	`
+	_, err := db.Exec(createMigrationsTable)
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrationFiles, path.Join(dir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(path.Base(name), ".sql")
+		if applied[version] {
+			continue
+		}
+
+		script, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		err = applyMigration(db, version, string(script))
+		if err != nil {
+			return err
+		}
+		slog.Info("Migration was successfully applied!", "version", version)
+	}
+
+	return nil
+}
+
+func appliedMigrations(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		err := rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+func applyMigration(db *sql.DB, version, script string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(script)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)", version, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
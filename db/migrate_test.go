@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestMigrateSQLite(t *testing.T) {
+	conn, err := sql.Open("sqlite", SQLiteDSN(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	defer conn.Close()
+
+	// Applying the migrations again is a no-op.
+	for i := 0; i < 2; i++ {
+		err = Migrate(conn, SQLite)
+		if err != nil {
+			t.Fatalf("Run %d: %v", i+1, err)
+		}
+	}
+
+	var applied int
+	err = conn.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 3 {
+		t.Errorf("Expected 3 applied migrations; got %d", applied)
+	}
+}
+
+func TestDialectOf(t *testing.T) {
+	testCases := []struct {
+		driverName      string
+		expectedDialect Dialect
+		expectedErr     bool
+	}{
+		{"postgres", Postgres, false},
+		{"sqlite", SQLite, false},
+		{"mysql", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.driverName, func(t *testing.T) {
+			dialect, err := DialectOf(tc.driverName)
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("Expected error %t; got %v", tc.expectedErr, err)
+			}
+			if dialect != tc.expectedDialect {
+				t.Errorf("Expected %q; got %q", tc.expectedDialect, dialect)
+			}
+		})
+	}
+}
+
+// The Postgres migration resolving duplicate open reservations sticks to SQL
+// that SQLite understands as well, so it is checked on a minimal table here.
+func TestUniqueOpenReservationsMigration(t *testing.T) {
+	conn, err := sql.Open("sqlite", SQLiteDSN(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	defer conn.Close()
+
+	_, err = conn.Exec(`
+	CREATE TABLE reservations (
+		id INTEGER PRIMARY KEY,
+		book_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		checkout_date TIMESTAMP NOT NULL,
+		return_date TIMESTAMP,
+		status TEXT NOT NULL,
+		status_changed_at TIMESTAMP NOT NULL,
+		status_note TEXT NOT NULL DEFAULT ''
+	);
+	INSERT INTO reservations (id, book_id, user_id, checkout_date, return_date, status, status_changed_at) VALUES
+		(1, 1, 1, '2025-01-01', NULL, 'active', '2025-01-01'),
+		(2, 1, 1, '2025-01-05', NULL, 'requested', '2025-01-05'),
+		(3, 2, 1, '2025-01-01', NULL, 'requested', '2025-01-01'),
+		(4, 2, 1, '2025-01-05', NULL, 'booked', '2025-01-05'),
+		(5, 2, 1, '2025-01-03', '2025-01-04', 'returned', '2025-01-04'),
+		(6, 1, 2, '2025-01-01', NULL, 'requested', '2025-01-01');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := migrationFiles.ReadFile("migrations/0010_unique_open_reservations.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(string(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The loan wins over the newer reservation; otherwise the newest wins.
+	expected := map[int64]string{1: "active", 2: "cancelled", 3: "cancelled", 4: "booked", 5: "returned", 6: "requested"}
+	for id, status := range expected {
+		var got string
+		err := conn.QueryRow("SELECT status FROM reservations WHERE id = $1", id).Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != status {
+			t.Errorf("Reservation %d: expected status %s; got %s", id, status, got)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS reservations (
+	id SERIAL PRIMARY KEY NOT NULL,
+	book_id INT NOT NULL,
+	user_id INT NOT NULL,
+	checkout_date TIMESTAMP NOT NULL,
+	return_date TIMESTAMP
+);
@@ -0,0 +1,10 @@
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+
+UPDATE reservations SET status = 'returned' WHERE return_date IS NOT NULL;
+UPDATE reservations SET status_changed_at = COALESCE(return_date, checkout_date);
+
+ALTER TABLE reservations ALTER COLUMN status_changed_at SET NOT NULL;
+ALTER TABLE reservations ALTER COLUMN status SET DEFAULT 'requested';
+ALTER TABLE reservations ADD CONSTRAINT reservations_status_check
+	CHECK (status IN ('requested', 'active', 'overdue', 'returned', 'lost', 'cancelled'));
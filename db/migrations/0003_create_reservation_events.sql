@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS reservation_events (
+	id SERIAL PRIMARY KEY NOT NULL,
+	reservation_id INT NOT NULL REFERENCES reservations(id),
+	event_type VARCHAR(20) NOT NULL,
+	actor_id INT NOT NULL,
+	source_ip VARCHAR(45) NOT NULL,
+	before_snapshot JSONB,
+	after_snapshot JSONB,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS reservation_events_reservation_id_idx ON reservation_events (reservation_id, created_at);
+
+-- The history is append-only: updates and deletes are silently discarded.
+CREATE OR REPLACE RULE reservation_events_no_update AS ON UPDATE TO reservation_events DO INSTEAD NOTHING;
+CREATE OR REPLACE RULE reservation_events_no_delete AS ON DELETE TO reservation_events DO INSTEAD NOTHING;
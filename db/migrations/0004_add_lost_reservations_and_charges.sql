@@ -0,0 +1,17 @@
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS status_note TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_status_check;
+ALTER TABLE reservations ADD CONSTRAINT reservations_status_check
+	CHECK (status IN ('requested', 'active', 'overdue', 'returned', 'lost', 'damaged', 'cancelled'));
+
+CREATE TABLE IF NOT EXISTS charges (
+	id SERIAL PRIMARY KEY NOT NULL,
+	reservation_id INT NOT NULL REFERENCES reservations(id),
+	user_id INT NOT NULL,
+	amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+	reason VARCHAR(20) NOT NULL,
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS charges_user_id_idx ON charges (user_id);
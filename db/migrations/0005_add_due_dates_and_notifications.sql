@@ -0,0 +1,11 @@
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS due_date TIMESTAMP;
+
+UPDATE reservations SET due_date = checkout_date + INTERVAL '14 days'
+WHERE status IN ('active', 'overdue') AND due_date IS NULL;
+
+CREATE TABLE IF NOT EXISTS reservation_notifications (
+	reservation_id INT NOT NULL REFERENCES reservations(id),
+	kind VARCHAR(20) NOT NULL,
+	sent_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (reservation_id, kind)
+);
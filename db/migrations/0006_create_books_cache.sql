@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS books_cache (
+	id INT PRIMARY KEY NOT NULL,
+	title VARCHAR(255) NOT NULL,
+	author VARCHAR(100) NOT NULL DEFAULT '',
+	isbn VARCHAR(20) NOT NULL DEFAULT '',
+	publication_year INT NOT NULL DEFAULT 0,
+	available_copies INT NOT NULL,
+	synced_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,9 @@
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS start_date TIMESTAMP;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS end_date TIMESTAMP;
+
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_status_check;
+ALTER TABLE reservations ADD CONSTRAINT reservations_status_check
+	CHECK (status IN ('booked', 'requested', 'active', 'overdue', 'returned', 'lost', 'damaged', 'cancelled'));
+
+CREATE INDEX IF NOT EXISTS reservations_book_id_status_idx ON reservations (book_id, status);
+CREATE INDEX IF NOT EXISTS reservations_booked_start_date_idx ON reservations (start_date) WHERE status = 'booked';
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS copies (
+	id SERIAL PRIMARY KEY,
+	book_id INTEGER NOT NULL,
+	barcode TEXT NOT NULL UNIQUE,
+	branch TEXT NOT NULL DEFAULT '',
+	condition TEXT NOT NULL DEFAULT 'good'
+		CHECK (condition IN ('good', 'worn', 'damaged', 'lost')),
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS copies_book_id_idx ON copies (book_id);
+
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS copy_id INTEGER REFERENCES copies (id);
+
+-- A physical copy can only be out on one loan at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_copy_on_loan_idx ON reservations (copy_id)
+	WHERE status IN ('active', 'overdue');
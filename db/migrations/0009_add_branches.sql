@@ -0,0 +1,14 @@
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS branch TEXT NOT NULL DEFAULT '';
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS return_branch TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_status_check;
+ALTER TABLE reservations ADD CONSTRAINT reservations_status_check
+	CHECK (status IN ('booked', 'requested', 'active', 'overdue', 'in_transit', 'returned', 'lost', 'damaged', 'cancelled'));
+
+CREATE INDEX IF NOT EXISTS reservations_branch_idx ON reservations (branch);
+CREATE INDEX IF NOT EXISTS copies_branch_idx ON copies (branch);
+
+-- A copy travelling back to its branch is not on the shelf yet either.
+DROP INDEX IF EXISTS reservations_copy_on_loan_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_copy_on_loan_idx ON reservations (copy_id)
+	WHERE status IN ('active', 'overdue', 'in_transit');
@@ -0,0 +1,24 @@
+-- Earlier versions let a patron open the same book twice. All but one of such
+-- reservations are cancelled before the index is created: a loan the patron
+-- already holds wins, otherwise the newest reservation. The copies they held
+-- are not given back to the book service; the note marks them for staff.
+UPDATE reservations
+SET status = 'cancelled',
+	status_changed_at = CURRENT_TIMESTAMP,
+	status_note = 'Cancelled as a duplicate of another open reservation of the book'
+WHERE id IN (
+	SELECT id FROM (
+		SELECT id, ROW_NUMBER() OVER (
+			PARTITION BY user_id, book_id
+			ORDER BY status IN ('active', 'overdue') DESC, checkout_date DESC, id DESC
+		) AS position
+		FROM reservations
+		WHERE return_date IS NULL AND status IN ('booked', 'requested', 'active', 'overdue')
+	) AS open_reservations
+	WHERE position > 1
+);
+
+-- A patron holds at most one open reservation per book. Cancelled, lost and
+-- damaged reservations keep a NULL return_date, so only open statuses count.
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_open_per_user_book_idx ON reservations (user_id, book_id)
+	WHERE return_date IS NULL AND status IN ('booked', 'requested', 'active', 'overdue');
@@ -0,0 +1,3 @@
+-- Every change to a reservation bumps its version, which clients send back
+-- in If-Match to detect concurrent changes.
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
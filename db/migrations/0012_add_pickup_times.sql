@@ -0,0 +1,12 @@
+-- Loans are reported by when the copy was picked up rather than by when it
+-- was reserved. Loans started before this column existed take the time from
+-- their history, or their checkout date when it has none.
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS picked_up_at TIMESTAMP;
+
+UPDATE reservations SET picked_up_at = COALESCE(
+	(SELECT MIN(created_at) FROM reservation_events
+		WHERE reservation_events.reservation_id = reservations.id AND event_type = 'picked_up'),
+	checkout_date)
+WHERE picked_up_at IS NULL AND due_date IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS reservations_picked_up_at_idx ON reservations (picked_up_at);
@@ -0,0 +1,14 @@
+-- Reminders are recorded per due date, so that a renewed loan is reminded
+-- again, and per channel, so that a failed channel is retried without
+-- repeating the others. Notifications recorded before have an empty channel,
+-- which stands for every channel.
+ALTER TABLE reservation_notifications ADD COLUMN IF NOT EXISTS due_date TIMESTAMP;
+ALTER TABLE reservation_notifications ADD COLUMN IF NOT EXISTS channel VARCHAR(20) NOT NULL DEFAULT '';
+
+UPDATE reservation_notifications SET due_date = COALESCE(reservations.due_date, reservation_notifications.sent_at)
+FROM reservations
+WHERE reservations.id = reservation_notifications.reservation_id AND reservation_notifications.due_date IS NULL;
+
+ALTER TABLE reservation_notifications ALTER COLUMN due_date SET NOT NULL;
+ALTER TABLE reservation_notifications DROP CONSTRAINT IF EXISTS reservation_notifications_pkey;
+ALTER TABLE reservation_notifications ADD PRIMARY KEY (reservation_id, kind, due_date, channel);
@@ -0,0 +1,97 @@
+-- The schema of the Postgres migrations up to 0011, for local development and
+-- tests. Later migrations are added here under the same version.
+CREATE TABLE IF NOT EXISTS copies (
+	id INTEGER PRIMARY KEY,
+	book_id INTEGER NOT NULL,
+	barcode TEXT NOT NULL UNIQUE,
+	branch TEXT NOT NULL DEFAULT '',
+	condition TEXT NOT NULL DEFAULT 'good'
+		CHECK (condition IN ('good', 'worn', 'damaged', 'lost')),
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS copies_book_id_idx ON copies (book_id);
+CREATE INDEX IF NOT EXISTS copies_branch_idx ON copies (branch);
+
+CREATE TABLE IF NOT EXISTS reservations (
+	id INTEGER PRIMARY KEY,
+	book_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	checkout_date TIMESTAMP NOT NULL,
+	return_date TIMESTAMP,
+	due_date TIMESTAMP,
+	start_date TIMESTAMP,
+	end_date TIMESTAMP,
+	copy_id INTEGER REFERENCES copies (id),
+	branch TEXT NOT NULL DEFAULT '',
+	return_branch TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'requested'
+		CHECK (status IN ('booked', 'requested', 'active', 'overdue', 'in_transit', 'returned', 'lost', 'damaged', 'cancelled')),
+	status_changed_at TIMESTAMP NOT NULL,
+	status_note TEXT NOT NULL DEFAULT '',
+	version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS reservations_book_id_status_idx ON reservations (book_id, status);
+CREATE INDEX IF NOT EXISTS reservations_booked_start_date_idx ON reservations (start_date) WHERE status = 'booked';
+CREATE INDEX IF NOT EXISTS reservations_branch_idx ON reservations (branch);
+
+-- A physical copy can only be out on one loan at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_copy_on_loan_idx ON reservations (copy_id)
+	WHERE status IN ('active', 'overdue', 'in_transit');
+
+-- A patron holds at most one open reservation per book.
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_open_per_user_book_idx ON reservations (user_id, book_id)
+	WHERE return_date IS NULL AND status IN ('booked', 'requested', 'active', 'overdue');
+
+CREATE TABLE IF NOT EXISTS reservation_events (
+	id INTEGER PRIMARY KEY,
+	reservation_id INTEGER NOT NULL REFERENCES reservations (id),
+	event_type TEXT NOT NULL,
+	actor_id INTEGER NOT NULL,
+	source_ip TEXT NOT NULL,
+	before_snapshot BLOB,
+	after_snapshot BLOB,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS reservation_events_reservation_id_idx ON reservation_events (reservation_id, created_at);
+
+-- The history is append-only: updates and deletes are silently discarded.
+CREATE TRIGGER IF NOT EXISTS reservation_events_no_update BEFORE UPDATE ON reservation_events
+BEGIN
+	SELECT RAISE(IGNORE);
+END;
+CREATE TRIGGER IF NOT EXISTS reservation_events_no_delete BEFORE DELETE ON reservation_events
+BEGIN
+	SELECT RAISE(IGNORE);
+END;
+
+CREATE TABLE IF NOT EXISTS charges (
+	id INTEGER PRIMARY KEY,
+	reservation_id INTEGER NOT NULL REFERENCES reservations (id),
+	user_id INTEGER NOT NULL,
+	amount_cents INTEGER NOT NULL CHECK (amount_cents > 0),
+	reason TEXT NOT NULL,
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS charges_user_id_idx ON charges (user_id);
+
+CREATE TABLE IF NOT EXISTS reservation_notifications (
+	reservation_id INTEGER NOT NULL REFERENCES reservations (id),
+	kind TEXT NOT NULL,
+	sent_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (reservation_id, kind)
+);
+
+CREATE TABLE IF NOT EXISTS books_cache (
+	id INTEGER PRIMARY KEY NOT NULL,
+	title TEXT NOT NULL,
+	author TEXT NOT NULL DEFAULT '',
+	isbn TEXT NOT NULL DEFAULT '',
+	publication_year INTEGER NOT NULL DEFAULT 0,
+	available_copies INTEGER NOT NULL,
+	synced_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,19 @@
+-- See the Postgres migration of the same version. SQLite cannot change a
+-- primary key, so the table is rebuilt.
+CREATE TABLE reservation_notifications_new (
+	reservation_id INTEGER NOT NULL REFERENCES reservations (id),
+	kind TEXT NOT NULL,
+	due_date TIMESTAMP NOT NULL,
+	channel TEXT NOT NULL DEFAULT '',
+	sent_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (reservation_id, kind, due_date, channel)
+);
+
+INSERT INTO reservation_notifications_new (reservation_id, kind, due_date, channel, sent_at)
+SELECT reservation_notifications.reservation_id, reservation_notifications.kind,
+	COALESCE(reservations.due_date, reservation_notifications.sent_at), '', reservation_notifications.sent_at
+FROM reservation_notifications
+JOIN reservations ON reservations.id = reservation_notifications.reservation_id;
+
+DROP TABLE reservation_notifications;
+ALTER TABLE reservation_notifications_new RENAME TO reservation_notifications;
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"log"
+	"log/slog"
 
 	_ "github.com/lib/pq"
 )
@@ -14,12 +15,18 @@ func InitDB(driverName, connStr string) (*sql.DB, error) {
 		log.Fatal(err)
 	}
 
+	// SQLite allows one writer at a time, and every connection to
+	// ":memory:" opens a database of its own.
+	if driverName == string(SQLite) {
+		db.SetMaxOpenConns(1)
+	}
+
 	err = db.Ping()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	log.Println("Connected to the PostgreSQL database!")
+	slog.Info("Connected to the database!", "driver", driverName)
 
 	return db, nil
 }
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDSN returns the data source name of the SQLite database at path, or
+// of an in-memory one for ":memory:". Times are stored in a format that sorts
+// as text, so the repositories can compare them in SQL.
+func SQLiteDSN(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Set("_time_format", "sqlite")
+	return "file:" + path + "?" + query.Encode()
+}
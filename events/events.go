@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Type names a reservation domain event. The payload schema of a type is
+// identified by Event.Version; incompatible changes bump the version.
+type Type string
+
+const (
+	ReservationCreated   Type = "ReservationCreated"
+	ReservationStarted   Type = "ReservationStarted"
+	ReservationPickedUp  Type = "ReservationPickedUp"
+	ReservationCompleted Type = "ReservationCompleted"
+	ReservationReceived  Type = "ReservationReceived"
+	ReservationRenewed   Type = "ReservationRenewed"
+	ReservationCancelled Type = "ReservationCancelled"
+	ReservationOverdue   Type = "ReservationOverdue"
+	ReservationLost      Type = "ReservationLost"
+	ReservationDamaged   Type = "ReservationDamaged"
+)
+
+// Version is the current payload version of all reservation events.
+const Version = 1
+
+const source = "reservation-service"
+
+// Event is the JSON envelope published to the broker.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       Type            `json:"type"`
+	Version    int             `json:"version"`
+	Source     string          `json:"source"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Key        string          `json:"key"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// New wraps data into a versioned event. key identifies the aggregate
+// (the reservation ID) and is used for partitioning.
+func New(eventType Type, key string, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:         newEventID(),
+		Type:       eventType,
+		Version:    Version,
+		Source:     source,
+		OccurredAt: time.Now().UTC(),
+		Key:        key,
+		Data:       payload,
+	}, nil
+}
+
+// Publisher sends events to a message broker.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+	Close() error
+}
+
+// NopPublisher drops every event. It is used when no broker is configured.
+type NopPublisher struct{}
+
+func (NopPublisher) Publish(ctx context.Context, event Event) error { return nil }
+func (NopPublisher) Close() error                                   { return nil }
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher writes every event to a single topic keyed by reservation,
+// so all events of one reservation land in the same partition in order.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// kafkaBatchTimeout is how long Publish waits for more events to send along.
+// The writer is synchronous, so every request that publishes waits this long
+// at most; the library's default of a second would show in every response.
+const kafkaBatchTimeout = 10 * time.Millisecond
+
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		BatchTimeout:           kafkaBatchTimeout,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.Key),
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: "Event-Id", Value: []byte(event.ID)},
+			{Key: "Event-Type", Value: []byte(event.Type)},
+			{Key: "Event-Version", Value: []byte(strconv.Itoa(event.Version))},
+		},
+	})
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
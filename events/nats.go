@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher publishes every event on "<subjectPrefix>.<type>", e.g.
+// "reservations.ReservationCreated".
+type NATSPublisher struct {
+	conn          *nats.Conn
+	subjectPrefix string
+}
+
+func NewNATSPublisher(url, subjectPrefix string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name(source))
+	if err != nil {
+		return nil, err
+	}
+	return &NATSPublisher{conn: conn, subjectPrefix: subjectPrefix}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(p.subjectPrefix + "." + string(event.Type))
+	msg.Header.Set("Event-Id", event.ID)
+	msg.Header.Set("Event-Type", string(event.Type))
+	msg.Header.Set("Event-Version", strconv.Itoa(event.Version))
+	msg.Data = payload
+
+	return p.conn.PublishMsg(msg)
+}
+
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,35 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryInterceptor gives every call a request ID, taken from the
+// x-request-id metadata when present, and logs it like logger.AccessLog
+// does for REST requests.
+func UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		id := firstMetadata(ctx, strings.ToLower(logger.RequestIDHeader))
+		if id == "" {
+			id = logger.NewRequestID()
+		}
+		ctx = logger.WithRequestID(ctx, id)
+
+		resp, err := handler(ctx, req)
+
+		logger.FromContext(ctx).Info("request handled",
+			"method", info.FullMethod,
+			"status", status.Code(err).String(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+		return resp, err
+	}
+}
@@ -0,0 +1,272 @@
+// Package grpcapi serves the reservation API over gRPC. It is a thin
+// transport over reservation.Service, so it shares every rule with the REST
+// handler.
+package grpcapi
+
+//go:generate protoc -I ../proto --go_out=../proto --go_opt=paths=source_relative --go-grpc_out=../proto --go-grpc_opt=paths=source_relative reservation/v1/reservation.proto
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	reservationv1 "github.com/shkuran/go-library-microservices/reservation-service/proto/reservation/v1"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Metadata keys identifying the caller. They mirror the UserID and UserRole
+// headers the gateway sets for REST requests.
+const (
+	userIDKey   = "userid"
+	userRoleKey = "userrole"
+)
+
+// Metadata keys of the version precondition, mirroring the If-Match and ETag
+// headers of the REST API: replies carry the reservation's version in the
+// etag header, and changes must send it back in if-match, or "*".
+const (
+	ifMatchKey = "if-match"
+	etagKey    = "etag"
+)
+
+type Server struct {
+	reservationv1.UnimplementedReservationServiceServer
+	service *reservation.Service
+}
+
+func NewServer(service *reservation.Service) *Server {
+	return &Server{service: service}
+}
+
+// Register adds the reservation service to s.
+func (s *Server) Register(server *grpc.Server) {
+	reservationv1.RegisterReservationServiceServer(server, s)
+}
+
+func (s *Server) Create(ctx context.Context, req *reservationv1.CreateReservationRequest) (*reservationv1.Reservation, error) {
+	userId, ctx, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.service.Reserve(ctx, userId, req.GetBookId(), reservation.ReserveOptions{
+		Branch:    req.GetBranch(),
+		StartDate: fromTimestamp(req.GetStartDate()),
+		EndDate:   fromTimestamp(req.GetEndDate()),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return reply(ctx, created)
+}
+
+func (s *Server) Complete(ctx context.Context, req *reservationv1.CompleteReservationRequest) (*reservationv1.Reservation, error) {
+	userId, ctx, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = versioned(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	completed, err := s.service.Return(ctx, userId, req.GetId(), req.GetBranch())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return reply(ctx, completed)
+}
+
+func (s *Server) Get(ctx context.Context, req *reservationv1.GetReservationRequest) (*reservationv1.Reservation, error) {
+	userId, ctx, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.service.Get(ctx, userId, isStaff(ctx), req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return reply(ctx, res)
+}
+
+// List returns the reservations matching the request. Patrons only see
+// their own; staff see everyone's.
+func (s *Server) List(ctx context.Context, req *reservationv1.ListReservationsRequest) (*reservationv1.ListReservationsResponse, error) {
+	userId, ctx, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := reservation.Filter{
+		Branch: req.GetBranch(),
+		UserId: req.GetUserId(),
+		BookId: req.GetBookId(),
+		Status: reservation.Status(req.GetStatus()),
+	}
+	reservations, err := s.service.List(ctx, userId, isStaff(ctx), filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &reservationv1.ListReservationsResponse{}
+	for _, res := range reservations {
+		resp.Reservations = append(resp.Reservations, toProto(res))
+	}
+	return resp, nil
+}
+
+func (s *Server) Renew(ctx context.Context, req *reservationv1.RenewReservationRequest) (*reservationv1.Reservation, error) {
+	userId, ctx, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = versioned(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	renewed, err := s.service.Renew(ctx, userId, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return reply(ctx, renewed)
+}
+
+func (s *Server) Cancel(ctx context.Context, req *reservationv1.CancelReservationRequest) (*reservationv1.Reservation, error) {
+	userId, ctx, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = versioned(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, err := s.service.Cancel(ctx, userId, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return reply(ctx, cancelled)
+}
+
+// caller reads the user ID from the request metadata and adds the peer
+// address to ctx for the reservation history.
+func caller(ctx context.Context) (int64, context.Context, error) {
+	userId, err := strconv.ParseInt(firstMetadata(ctx, userIDKey), 10, 64)
+	if err != nil {
+		return 0, ctx, status.Error(codes.Unauthenticated, "Could not parse UserId!")
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ctx = reservation.WithSourceIP(ctx, p.Addr.String())
+	}
+	return userId, ctx, nil
+}
+
+// versioned requires the if-match metadata on a change to a reservation and
+// returns the service context expecting the version it names, like the REST
+// API does for If-Match. "*" matches any version.
+func versioned(ctx context.Context) (context.Context, error) {
+	value := firstMetadata(ctx, ifMatchKey)
+	switch value {
+	case "":
+		return ctx, status.Error(codes.FailedPrecondition, "The if-match metadata is required!")
+	case "*":
+		return ctx, nil
+	}
+
+	version, err := reservation.ParseETag(value)
+	if err != nil {
+		return ctx, status.Error(codes.InvalidArgument, "Could not parse if-match!")
+	}
+	return reservation.WithExpectedVersion(ctx, version), nil
+}
+
+// reply converts res and sends its version in the etag header metadata.
+func reply(ctx context.Context, res reservation.Reservation) (*reservationv1.Reservation, error) {
+	err := grpc.SetHeader(ctx, metadata.Pairs(etagKey, reservation.ETag(res.Version)))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Internal error!")
+	}
+	return toProto(res), nil
+}
+
+func isStaff(ctx context.Context) bool {
+	return firstMetadata(ctx, userRoleKey) == "staff"
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// toStatus maps Service errors to gRPC status codes, keeping the message the
+// REST API would send.
+func toStatus(err error) error {
+	var serviceErr *reservation.Error
+	if !errors.As(err, &serviceErr) {
+		return status.Error(codes.Internal, "Internal error!")
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, reservation.ErrNotOwner):
+		code = codes.PermissionDenied
+	case errors.Is(err, reservation.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, reservation.ErrUnavailable):
+		code = codes.FailedPrecondition
+	case errors.Is(err, reservation.ErrInvalidTransition), errors.Is(err, reservation.ErrStaleVersion):
+		code = codes.FailedPrecondition
+	case errors.Is(err, reservation.ErrInvalidRequest):
+		code = codes.InvalidArgument
+	case errors.Is(err, reservation.ErrConflict):
+		code = codes.Aborted
+	}
+	return status.Error(code, serviceErr.Message)
+}
+
+func toProto(res reservation.Reservation) *reservationv1.Reservation {
+	return &reservationv1.Reservation{
+		Id:              res.ID,
+		BookId:          res.BookId,
+		UserId:          res.UserId,
+		CheckoutDate:    toTimestamp(&res.CheckoutDate),
+		ReturnDate:      toTimestamp(res.ReturnDate),
+		DueDate:         toTimestamp(res.DueDate),
+		StartDate:       toTimestamp(res.StartDate),
+		EndDate:         toTimestamp(res.EndDate),
+		CopyId:          res.CopyId,
+		Branch:          res.Branch,
+		ReturnBranch:    res.ReturnBranch,
+		Status:          string(res.Status),
+		StatusChangedAt: toTimestamp(&res.StatusChangedAt),
+		StatusNote:      res.StatusNote,
+	}
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
@@ -0,0 +1,214 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	reservationv1 "github.com/shkuran/go-library-microservices/reservation-service/proto/reservation/v1"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func setupClient(t *testing.T, books []reservation.Book, reservations []reservation.Reservation) (reservationv1.ReservationServiceClient, *reservation.MockBookClient) {
+	t.Helper()
+
+	bookClient := reservation.NewMockBookClient(books)
+	service := reservation.NewService(reservation.NewMockReservationRepo(reservations), bookClient,
+		events.NewMemoryPublisher(), reservation.Policy{CancelWindow: time.Hour, LoanPeriod: 14 * 24 * time.Hour})
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryInterceptor()))
+	NewServer(service).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return reservationv1.NewReservationServiceClient(conn), bookClient
+}
+
+func asUser(id string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), userIDKey, id)
+}
+
+// anyVersion adds the precondition matching every version of a reservation.
+func anyVersion(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, ifMatchKey, "*")
+}
+
+func TestCreateAndCancel(t *testing.T) {
+	client, books := setupClient(t,
+		[]reservation.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}, {ID: 2, Title: "Book_2", AvailableCopies: 0}},
+		[]reservation.Reservation{{ID: 1, BookId: 2, UserId: 1, CheckoutDate: time.Now(), Status: reservation.StatusRequested}})
+
+	created, err := client.Create(asUser("1"), &reservationv1.CreateReservationRequest{BookId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetId() != 2 || created.GetStatus() != string(reservation.StatusRequested) {
+		t.Errorf("Expected requested reservation 2; got %v", created)
+	}
+
+	// The last copy is taken, so the same rule as in REST applies
+	_, err = client.Create(asUser("2"), &reservationv1.CreateReservationRequest{BookId: 1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected %s; got %v", codes.FailedPrecondition, err)
+	}
+
+	_, err = client.Cancel(anyVersion(asUser("2")), &reservationv1.CancelReservationRequest{Id: 1})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected %s; got %v", codes.PermissionDenied, err)
+	}
+
+	cancelled, err := client.Cancel(anyVersion(asUser("1")), &reservationv1.CancelReservationRequest{Id: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.GetStatus() != string(reservation.StatusCancelled) {
+		t.Errorf("Expected status %s; got %s", reservation.StatusCancelled, cancelled.GetStatus())
+	}
+	book, err := books.GetBook(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.AvailableCopies != 1 {
+		t.Errorf("Expected AvailableCopies %d; got %d", 1, book.AvailableCopies)
+	}
+
+	_, err = client.Complete(anyVersion(asUser("1")), &reservationv1.CompleteReservationRequest{Id: 1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected %s; got %v", codes.FailedPrecondition, err)
+	}
+}
+
+func TestGetAndList(t *testing.T) {
+	due := time.Now().Add(24 * time.Hour)
+	client, _ := setupClient(t, []reservation.Book{{ID: 1}}, []reservation.Reservation{
+		{ID: 1, BookId: 1, UserId: 1, Branch: "main", Status: reservation.StatusActive, DueDate: &due},
+		{ID: 2, BookId: 2, UserId: 2, Branch: "east", Status: reservation.StatusRequested},
+	})
+
+	got, err := client.Get(asUser("1"), &reservationv1.GetReservationRequest{Id: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.GetDueDate().AsTime().Equal(due.UTC().Truncate(time.Nanosecond)) {
+		t.Errorf("Expected due date %v; got %v", due, got.GetDueDate().AsTime())
+	}
+
+	_, err = client.Get(asUser("1"), &reservationv1.GetReservationRequest{Id: 2})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected %s; got %v", codes.PermissionDenied, err)
+	}
+
+	staff := metadata.AppendToOutgoingContext(asUser("9"), userRoleKey, "staff")
+	_, err = client.Get(staff, &reservationv1.GetReservationRequest{Id: 2})
+	if err != nil {
+		t.Errorf("Expected staff to read any reservation; got %v", err)
+	}
+
+	list, err := client.List(staff, &reservationv1.ListReservationsRequest{Branch: "east"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetReservations()) != 1 || list.GetReservations()[0].GetId() != 2 {
+		t.Errorf("Expected only reservation 2; got %v", list.GetReservations())
+	}
+
+	list, err = client.List(asUser("1"), &reservationv1.ListReservationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetReservations()) != 1 || list.GetReservations()[0].GetId() != 1 {
+		t.Errorf("Expected only the caller's reservation 1; got %v", list.GetReservations())
+	}
+
+	_, err = client.List(asUser("1"), &reservationv1.ListReservationsRequest{UserId: 2})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected %s; got %v", codes.PermissionDenied, err)
+	}
+
+	renewed, err := client.Renew(anyVersion(asUser("1")), &reservationv1.RenewReservationRequest{Id: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renewed.GetDueDate().AsTime().After(due) {
+		t.Errorf("Expected due date after %v; got %v", due, renewed.GetDueDate().AsTime())
+	}
+}
+
+func TestMissingCaller(t *testing.T) {
+	client, _ := setupClient(t, nil, nil)
+
+	_, err := client.Get(context.Background(), &reservationv1.GetReservationRequest{Id: 1})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected %s; got %v", codes.Unauthenticated, err)
+	}
+
+	_, err = client.List(context.Background(), &reservationv1.ListReservationsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected %s; got %v", codes.Unauthenticated, err)
+	}
+}
+
+func TestVersionPrecondition(t *testing.T) {
+	due := time.Now().Add(24 * time.Hour)
+	client, _ := setupClient(t, []reservation.Book{{ID: 1}}, []reservation.Reservation{
+		{ID: 1, BookId: 1, UserId: 1, Status: reservation.StatusActive, DueDate: &due, Version: 2},
+	})
+
+	var header metadata.MD
+	_, err := client.Get(asUser("1"), &reservationv1.GetReservationRequest{Id: 1}, grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if etag := header.Get(etagKey); len(etag) != 1 || etag[0] != `"2"` {
+		t.Errorf("Expected etag %q; got %v", `"2"`, etag)
+	}
+
+	testCases := []struct {
+		testName     string
+		ifMatch      string
+		expectedCode codes.Code
+	}{
+		{"Missing", "", codes.FailedPrecondition},
+		{"Malformed", "2", codes.InvalidArgument},
+		{"Stale", `"1"`, codes.FailedPrecondition},
+		{"Current", `"2"`, codes.OK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			ctx := asUser("1")
+			if tc.ifMatch != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, ifMatchKey, tc.ifMatch)
+			}
+			var header metadata.MD
+			_, err := client.Renew(ctx, &reservationv1.RenewReservationRequest{Id: 1}, grpc.Header(&header))
+			if status.Code(err) != tc.expectedCode {
+				t.Fatalf("Expected %s; got %v", tc.expectedCode, err)
+			}
+			if tc.expectedCode == codes.OK {
+				if etag := header.Get(etagKey); len(etag) != 1 || etag[0] != `"3"` {
+					t.Errorf("Expected etag %q; got %v", `"3"`, etag)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Check reports whether a dependency is usable. A nil error means healthy.
+type Check func(ctx context.Context) error
+
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Optional  bool   `json:"optional,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Handler struct {
+	timeout time.Duration
+	started atomic.Bool
+
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]Check
+	optional map[string]bool
+}
+
+func NewHandler(timeout time.Duration) *Handler {
+	return &Handler{timeout: timeout, checks: make(map[string]Check), optional: make(map[string]bool)}
+}
+
+// AddCheck registers a dependency that /readyz has to verify.
+func (h *Handler) AddCheck(name string, check Check) {
+	h.addCheck(name, check, false)
+}
+
+// AddOptionalCheck registers a dependency that is reported by /readyz but
+// whose failure does not make the service unready.
+func (h *Handler) AddOptionalCheck(name string, check Check) {
+	h.addCheck(name, check, true)
+}
+
+func (h *Handler) addCheck(name string, check Check, optional bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+	h.optional[name] = optional
+}
+
+// MarkStarted flips the startup probe once migrations have been applied.
+func (h *Handler) MarkStarted() {
+	h.started.Store(true)
+}
+
+// Liveness only tells that the process is alive and serving HTTP.
+func (h *Handler) Liveness(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Startup stays unavailable until MarkStarted is called.
+func (h *Handler) Startup(context *gin.Context) {
+	if !h.started.Load() {
+		context.JSON(http.StatusServiceUnavailable, gin.H{"status": "starting"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness runs every registered check and reports per-dependency status.
+func (h *Handler) Readiness(context *gin.Context) {
+	if !h.started.Load() {
+		context.JSON(http.StatusServiceUnavailable, gin.H{"status": "starting"})
+		return
+	}
+
+	dependencies := h.runChecks(context.Request.Context())
+
+	status, code := "ok", http.StatusOK
+	for _, dependency := range dependencies {
+		if dependency.Status != "ok" && !dependency.Optional {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	context.JSON(code, gin.H{"status": status, "dependencies": dependencies})
+}
+
+func (h *Handler) runChecks(ctx context.Context) map[string]DependencyStatus {
+	h.mu.RLock()
+	names := append([]string(nil), h.names...)
+	checks := make(map[string]Check, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	optional := make(map[string]bool, len(h.optional))
+	for name, isOptional := range h.optional {
+		optional[name] = isOptional
+	}
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := make(map[string]DependencyStatus, len(names))
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			dependency := DependencyStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds(), Optional: optional[name]}
+			if err != nil {
+				dependency.Status = "unavailable"
+				dependency.Error = err.Error()
+			}
+
+			mu.Lock()
+			result[name] = dependency
+			mu.Unlock()
+		}(name, checks[name])
+	}
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,136 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func performRequest(h *Handler, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/healthz", h.Liveness)
+	router.GET("/readyz", h.Readiness)
+	router.GET("/startupz", h.Startup)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProbes(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	testCases := []struct {
+		testName     string
+		started      bool
+		checks       map[string]Check
+		optional     map[string]Check
+		path         string
+		expectedCode int
+	}{
+		{
+			testName:     "Liveness before startup",
+			started:      false,
+			path:         "/healthz",
+			expectedCode: http.StatusOK,
+		},
+		{
+			testName:     "Startup before migrations",
+			started:      false,
+			path:         "/startupz",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			testName:     "Startup after migrations",
+			started:      true,
+			path:         "/startupz",
+			expectedCode: http.StatusOK,
+		},
+		{
+			testName:     "Readiness before migrations",
+			started:      false,
+			checks:       map[string]Check{"database": ok},
+			path:         "/readyz",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			testName:     "Readiness with healthy dependencies",
+			started:      true,
+			checks:       map[string]Check{"database": ok, "book_service": ok},
+			path:         "/readyz",
+			expectedCode: http.StatusOK,
+		},
+		{
+			testName:     "Readiness with failing dependency",
+			started:      true,
+			checks:       map[string]Check{"database": ok, "book_service": failing},
+			path:         "/readyz",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			testName:     "Readiness with failing optional dependency",
+			started:      true,
+			checks:       map[string]Check{"database": ok},
+			optional:     map[string]Check{"book_service": failing},
+			path:         "/readyz",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			h := NewHandler(time.Second)
+			for name, check := range tc.checks {
+				h.AddCheck(name, check)
+			}
+			for name, check := range tc.optional {
+				h.AddOptionalCheck(name, check)
+			}
+			if tc.started {
+				h.MarkStarted()
+			}
+
+			w := performRequest(h, tc.path)
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestReadinessReportsDependencies(t *testing.T) {
+	h := NewHandler(time.Second)
+	h.AddCheck("database", func(ctx context.Context) error { return nil })
+	h.AddCheck("book_service", func(ctx context.Context) error { return errors.New("connection refused") })
+	h.MarkStarted()
+
+	w := performRequest(h, "/readyz")
+
+	var response struct {
+		Status       string                      `json:"status"`
+		Dependencies map[string]DependencyStatus `json:"dependencies"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Status != "unavailable" {
+		t.Errorf("Expected status 'unavailable'; got '%s'", response.Status)
+	}
+	if response.Dependencies["database"].Status != "ok" {
+		t.Errorf("Expected database to be ok; got %+v", response.Dependencies["database"])
+	}
+	if response.Dependencies["book_service"].Error != "connection refused" {
+		t.Errorf("Expected book_service error 'connection refused'; got %+v", response.Dependencies["book_service"])
+	}
+}
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is read from incoming requests and forwarded to upstream
+// services so a single request can be followed across them.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// New returns a JSON logger writing to w at the given level
+// ("debug", "info", "warn" or "error"; defaults to info). Records logged
+// with a context, as slog.InfoContext does, carry its request ID.
+func New(w io.Writer, level string) *slog.Logger {
+	var lvl slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "warn":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		lvl = slog.LevelInfo
+	}
+
+	return slog.New(requestIDHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})})
+}
+
+// requestIDHandler adds the request ID of the record's context to each record.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// WithRequestID stores id in ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger annotated with the request ID of ctx.
+func FromContext(ctx context.Context) *slog.Logger {
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		return slog.Default()
+	}
+	return slog.Default().With("request_id", id)
+}
+
+// RequestID reads X-Request-ID from the request or generates a new one,
+// stores it in the request context and echoes it in the response.
+func RequestID() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id := context.GetHeader(RequestIDHeader)
+		if id == "" {
+			id = NewRequestID()
+		}
+
+		context.Request = context.Request.WithContext(WithRequestID(context.Request.Context(), id))
+		context.Header(RequestIDHeader, id)
+
+		context.Next()
+	}
+}
+
+// AccessLog writes one line per request once it has been handled.
+func AccessLog() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		FromContext(context.Request.Context()).Info("request handled",
+			"method", context.Request.Method,
+			"path", context.Request.URL.Path,
+			"route", context.FullPath(),
+			"status", context.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", context.ClientIP(),
+		)
+	}
+}
+
+// PropagateRequestID wraps next so outgoing requests carry the request ID
+// found in their context.
+func PropagateRequestID(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		id := RequestIDFromContext(req.Context())
+		if id != "" && req.Header.Get(RequestIDHeader) == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set(RequestIDHeader, id)
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// NewRequestID returns a random ID for requests that arrive without one.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		testName   string
+		incomingID string
+	}{
+		{testName: "Reuse incoming request ID", incomingID: "abc-123"},
+		{testName: "Generate request ID", incomingID: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(RequestID())
+
+			var seenID string
+			router.GET("/reservations", func(context *gin.Context) {
+				seenID = RequestIDFromContext(context.Request.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/reservations", nil)
+			if tc.incomingID != "" {
+				req.Header.Set(RequestIDHeader, tc.incomingID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if seenID == "" {
+				t.Fatal("Expected request ID in handler context")
+			}
+			if tc.incomingID != "" && seenID != tc.incomingID {
+				t.Errorf("Expected request ID '%s'; got '%s'", tc.incomingID, seenID)
+			}
+			if w.Header().Get(RequestIDHeader) != seenID {
+				t.Errorf("Expected response header '%s'; got '%s'", seenID, w.Header().Get(RequestIDHeader))
+			}
+		})
+	}
+}
+
+func TestPropagateRequestID(t *testing.T) {
+	var forwardedID string
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		forwardedID = req.Header.Get(RequestIDHeader)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://books.local/books/1", nil)
+	req = req.WithContext(WithRequestID(req.Context(), "abc-123"))
+
+	_, err := PropagateRequestID(next).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forwardedID != "abc-123" {
+		t.Errorf("Expected forwarded request ID 'abc-123'; got '%s'", forwardedID)
+	}
+}
+
+func TestNewAddsRequestID(t *testing.T) {
+	testCases := []struct {
+		testName   string
+		ctx        context.Context
+		expectedID string
+	}{
+		{testName: "Context with request ID", ctx: WithRequestID(context.Background(), "abc-123"), expectedID: "abc-123"},
+		{testName: "Context without request ID", ctx: context.Background()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(&buf, "info").InfoContext(tc.ctx, "reservation created")
+
+			var line map[string]any
+			err := json.Unmarshal(buf.Bytes(), &line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, _ := line["request_id"].(string)
+			if id != tc.expectedID {
+				t.Errorf("Expected request ID '%s'; got '%s'", tc.expectedID, id)
+			}
+		})
+	}
+}
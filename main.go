@@ -1,18 +1,59 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"github.com/shkuran/go-library-microservices/reservation-service/catalog"
+	"github.com/shkuran/go-library-microservices/reservation-service/cmd"
 	"github.com/shkuran/go-library-microservices/reservation-service/config"
 	"github.com/shkuran/go-library-microservices/reservation-service/db"
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	"github.com/shkuran/go-library-microservices/reservation-service/grpcapi"
+	"github.com/shkuran/go-library-microservices/reservation-service/health"
+	"github.com/shkuran/go-library-microservices/reservation-service/logger"
+	"github.com/shkuran/go-library-microservices/reservation-service/metrics"
+	"github.com/shkuran/go-library-microservices/reservation-service/notifier"
+	"github.com/shkuran/go-library-microservices/reservation-service/report"
 	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
 	"github.com/shkuran/go-library-microservices/reservation-service/routes"
+	"github.com/shkuran/go-library-microservices/reservation-service/tracing"
+	"google.golang.org/grpc"
 )
 
 func main() {
 	conf := config.LoadConfig()
+	// The import command prints its report on stdout, so it logs to stderr.
+	importing := len(os.Args) > 1 && os.Args[1] == "import"
+	logOutput := os.Stdout
+	if importing {
+		logOutput = os.Stderr
+	}
+	slog.SetDefault(logger.New(logOutput, conf.Log.Level))
+
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Enabled:     conf.Tracing.Enabled,
+		Exporter:    conf.Tracing.Exporter,
+		Endpoint:    conf.Tracing.Endpoint,
+		Insecure:    conf.Tracing.Insecure,
+		ServiceName: conf.Tracing.ServiceName,
+		SampleRatio: conf.Tracing.SampleRatio,
+		Output:      logOutput,
+	})
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	defer shutdownTracing(context.Background())
+
 	host := conf.Database.Host
 	port := conf.Database.Port
 	user := conf.Database.User
@@ -23,20 +64,116 @@ func main() {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		host, port, user, pass, dbName, sslMode)
 
-	varDb, err := db.InitDB(driverName, connStr)
+	dialect, err := db.DialectOf(driverName)
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
+	if dialect == db.SQLite {
+		connStr = db.SQLiteDSN(conf.Database.Path)
+	}
 
-	//db.CreateTables(varDb)
+	varDb, err := db.InitDB(driverName, connStr)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
 
-	server := gin.Default()
+	metrics.RegisterDBStats(varDb, dbName)
 
 	reservationRepo := reservation.NewRepo(varDb)
-	reservationHandler := reservation.NewHandler(reservationRepo)
+	if dialect == db.SQLite {
+		reservationRepo = reservation.NewSQLiteRepo(varDb)
+	}
+	if importing {
+		code := cmd.Import(varDb, dialect, reservationRepo, os.Args[2:])
+		// os.Exit skips deferred calls, so flush the spans first.
+		shutdownTracing(context.Background())
+		os.Exit(code)
+	}
+
+	server := gin.New()
+	server.Use(gin.Recovery())
+
+	bookService := reservation.NewBookServiceClient(conf.BookService.URL, conf.BookService.Timeout)
+	bookStore := catalog.NewSQLStore(varDb)
+	bookClient := catalog.NewCachedBookClient(bookService, bookStore)
+
+	var replicaDb *sql.DB
+	if conf.Database.ReplicaDSN != "" {
+		replicaDb, err = db.InitDB(driverName, conf.Database.ReplicaDSN)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		metrics.RegisterDBStats(replicaDb, dbName+"_replica")
+		reservationRepo.UseReplica(replicaDb)
+	}
+	publisher, err := newPublisher(conf)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	defer publisher.Close()
+
+	branches := make(map[string]reservation.BranchRules)
+	for name, rules := range conf.Reservation.Branches {
+		branches[name] = reservation.BranchRules{LoanPeriod: rules.LoanPeriod, MaxLoans: rules.MaxLoans}
+	}
+	policy := reservation.Policy{
+		CancelWindow:  conf.Reservation.CancelWindow,
+		LoanPeriod:    conf.Reservation.LoanPeriod,
+		MaxRenewals:   conf.Reservation.MaxRenewals,
+		DefaultBranch: conf.Reservation.DefaultBranch,
+		Branches:      branches,
+	}
+	reservationService := reservation.NewService(reservationRepo, bookClient, publisher, policy)
+	reservationHandler := reservation.NewHandler(reservationService)
+	catalogHandler := catalog.NewHandler(reservationService)
+
+	healthHandler := health.NewHandler(2 * time.Second)
+	healthHandler.AddCheck("database", varDb.PingContext)
+	if replicaDb != nil {
+		// Listings fail without the replica, but checkouts keep working.
+		healthHandler.AddOptionalCheck("database_replica", replicaDb.PingContext)
+	}
+	// Availability is answered from the local catalog, so a briefly
+	// unavailable book service does not make this service unready.
+	healthHandler.AddOptionalCheck("book_service", bookService.Ping)
+
+	// Reports only read, so they run on the replica when there is one.
+	reportDb := varDb
+	if replicaDb != nil {
+		reportDb = replicaDb
+	}
+	reportHandler := report.NewHandler(report.NewSQLStore(reportDb, dialect))
+
+	routes.RegisterRoutes(server, conf.Tracing.ServiceName, reservationHandler, catalogHandler, reportHandler, healthHandler)
+
+	if conf.Server.GRPCPort != "" {
+		go serveGRPC(conf.Server.GRPCPort, reservationService)
+	}
 
-	routes.RegisterRoutes(server, reservationHandler)
+	go func() {
+		err := db.Migrate(varDb, dialect)
+		if err != nil {
+			log.Fatal(err)
+		}
+		healthHandler.MarkStarted()
+
+		go catalog.NewSyncer(bookService, bookStore, conf.Catalog.SyncInterval).Start(context.Background())
+		if conf.Events.Broker == "nats" {
+			subscribeToBookEvents(conf, bookStore)
+		}
+		go reservation.NewBookingScheduler(reservationRepo, bookClient, publisher,
+			conf.Reservation.BookingCheckInterval).Start(context.Background())
+
+		if conf.Reminders.Enabled {
+			scheduler := reservation.NewReminderScheduler(reservationRepo, newNotifier(conf), publisher,
+				conf.Reminders.DueSoon, conf.Reminders.RunAt)
+			scheduler.Start(context.Background())
+		}
+	}()
 
 	err = server.Run(":" + conf.Server.Port)
 	if err != nil {
@@ -44,3 +181,74 @@ func main() {
 		return
 	}
 }
+
+// serveGRPC serves the gRPC reservation API on its own port. It shares the
+// repository and rules with the REST routes.
+func serveGRPC(port string, service *reservation.Service) {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcapi.UnaryInterceptor()))
+	grpcapi.NewServer(service).Register(server)
+
+	slog.Info("Serving gRPC", "port", port)
+	err = server.Serve(listener)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newPublisher(conf *config.Config) (events.Publisher, error) {
+	switch conf.Events.Broker {
+	case "nats":
+		return events.NewNATSPublisher(conf.Events.NATS.URL, conf.Events.NATS.SubjectPrefix)
+	case "kafka":
+		return events.NewKafkaPublisher(conf.Events.Kafka.Brokers, conf.Events.Kafka.Topic), nil
+	case "none", "":
+		return events.NopPublisher{}, nil
+	default:
+		return nil, fmt.Errorf("unknown events broker %q", conf.Events.Broker)
+	}
+}
+
+func subscribeToBookEvents(conf *config.Config, store catalog.Store) {
+	conn, err := nats.Connect(conf.Events.NATS.URL)
+	if err != nil {
+		slog.Error("Could not connect to NATS for book events!", "error", err)
+		return
+	}
+
+	_, err = catalog.Subscribe(conn, conf.Catalog.Subject, store)
+	if err != nil {
+		slog.Error("Could not subscribe to book events!", "error", err)
+	}
+}
+
+func newNotifier(conf *config.Config) notifier.Multi {
+	var channels notifier.Multi
+	for _, channel := range conf.Reminders.Channels {
+		var n notifier.Notifier
+		switch channel {
+		case "log":
+			n = notifier.NewLogNotifier()
+		case "smtp":
+			smtpConf := conf.Reminders.SMTP
+			n = notifier.NewSMTPNotifier(notifier.SMTPConfig{
+				Host:     smtpConf.Host,
+				Port:     smtpConf.Port,
+				User:     smtpConf.User,
+				Password: smtpConf.Password,
+				From:     smtpConf.From,
+				Timeout:  smtpConf.Timeout,
+			}, notifier.NewUserServiceRecipients(conf.UserService.URL, conf.UserService.Timeout))
+		case "webhook":
+			n = notifier.NewWebhookNotifier(conf.Reminders.Webhook.URL, conf.Reminders.Webhook.Timeout)
+		default:
+			log.Fatalf("Unknown notification channel %q", channel)
+		}
+		channels = append(channels, notifier.Channel{Name: channel, Notifier: n})
+	}
+	return channels
+}
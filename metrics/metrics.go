@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "reservation_service"
+
+// Reasons a reservation request can be rejected with.
+const (
+	ReasonUnavailable  = "unavailable"
+	ReasonParseError   = "parse_error"
+	ReasonUnauthorized = "unauthorized"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	ReservationsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reservations_created_total",
+		Help:      "Number of reservations created.",
+	})
+
+	ReservationsCompleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reservations_completed_total",
+		Help:      "Number of reservations completed.",
+	})
+
+	ReservationsCancelled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reservations_cancelled_total",
+		Help:      "Number of reservations cancelled before pickup.",
+	})
+
+	ReservationsLost = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reservations_lost_total",
+		Help:      "Number of loans closed as lost or damaged.",
+	}, []string{"condition"})
+
+	ReservationsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reservations_rejected_total",
+		Help:      "Number of rejected reservation requests by reason.",
+	}, []string{"reason"})
+
+	bookServiceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "book_service_request_duration_seconds",
+		Help:      "Latency of calls to the book service by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	bookServiceErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "book_service_errors_total",
+		Help:      "Number of failed calls to the book service by method.",
+	}, []string{"method"})
+)
+
+// Handler serves the default registry on /metrics.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware records a counter and a latency histogram for every request,
+// labelled with the matched route rather than the raw path.
+func Middleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		route := context.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(context.Writer.Status())
+
+		httpRequests.WithLabelValues(context.Request.Method, route, status).Inc()
+		httpDuration.WithLabelValues(context.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDBStats exposes sql.DBStats of db as gauges labelled with dbName.
+func RegisterDBStats(db *sql.DB, dbName string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// InstrumentBookService wraps next so every outgoing call to the book service
+// is timed and failures (transport errors and 5xx responses) are counted.
+func InstrumentBookService(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		response, err := next.RoundTrip(req)
+		bookServiceDuration.WithLabelValues(req.Method).Observe(time.Since(start).Seconds())
+
+		if err != nil || response.StatusCode >= http.StatusInternalServerError {
+			bookServiceErrors.WithLabelValues(req.Method).Inc()
+		}
+
+		return response, err
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
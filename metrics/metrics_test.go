@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/reservations/:id", func(context *gin.Context) {
+		context.Status(http.StatusTeapot)
+	})
+
+	before := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/reservations/:id", "418"))
+
+	req := httptest.NewRequest(http.MethodGet, "/reservations/42", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	after := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/reservations/:id", "418"))
+	if after-before != 1 {
+		t.Errorf("Expected request counter to grow by 1; got %v", after-before)
+	}
+}
+
+func TestInstrumentBookServiceCountsErrors(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		method         string
+		statusCode     int
+		err            error
+		expectedErrors float64
+	}{
+		{testName: "Successful call", method: http.MethodGet, statusCode: http.StatusOK, expectedErrors: 0},
+		{testName: "Server error", method: http.MethodPut, statusCode: http.StatusBadGateway, expectedErrors: 1},
+		{testName: "Transport error", method: http.MethodPost, err: errors.New("connection refused"), expectedErrors: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if tc.err != nil {
+					return nil, tc.err
+				}
+				return &http.Response{StatusCode: tc.statusCode, Body: http.NoBody}, nil
+			})
+
+			before := testutil.ToFloat64(bookServiceErrors.WithLabelValues(tc.method))
+
+			req := httptest.NewRequest(tc.method, "http://books.local/books/1", nil)
+			InstrumentBookService(next).RoundTrip(req)
+
+			after := testutil.ToFloat64(bookServiceErrors.WithLabelValues(tc.method))
+			if after-before != tc.expectedErrors {
+				t.Errorf("Expected %v errors; got %v", tc.expectedErrors, after-before)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package notifier
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogNotifier only writes notifications to the log. Useful for local runs.
+type LogNotifier struct{}
+
+func NewLogNotifier() LogNotifier {
+	return LogNotifier{}
+}
+
+func (LogNotifier) Notify(ctx context.Context, n Notification) error {
+	slog.InfoContext(ctx, n.Subject(),
+		"kind", n.Kind,
+		"reservation_id", n.ReservationId,
+		"user_id", n.UserId,
+		"book_id", n.BookId,
+		"due_date", n.DueDate,
+	)
+	return nil
+}
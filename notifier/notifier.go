@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindDueSoon Kind = "due_soon"
+	KindOverdue Kind = "overdue"
+)
+
+// Notification tells a patron about a loan that is due soon or overdue.
+type Notification struct {
+	Kind          Kind      `json:"kind"`
+	ReservationId int64     `json:"reservation_id"`
+	UserId        int64     `json:"user_id"`
+	BookId        int64     `json:"book_id"`
+	DueDate       time.Time `json:"due_date"`
+}
+
+func (n Notification) Subject() string {
+	if n.Kind == KindOverdue {
+		return "Your library loan is overdue"
+	}
+	return "Your library loan is due soon"
+}
+
+func (n Notification) Body() string {
+	due := n.DueDate.Format("January 2, 2006")
+	if n.Kind == KindOverdue {
+		return fmt.Sprintf("Reservation #%d for book #%d was due on %s. Please return the book as soon as possible.",
+			n.ReservationId, n.BookId, due)
+	}
+	return fmt.Sprintf("Reservation #%d for book #%d is due on %s. Please return or renew it in time.",
+		n.ReservationId, n.BookId, due)
+}
+
+// Notifier delivers a notification through one channel.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Channel is a Notifier with the name its sends are recorded under, so that
+// a channel that failed can be retried without repeating the others.
+type Channel struct {
+	Name string
+	Notifier
+}
+
+// Multi sends every notification through all channels and reports the
+// combined error of those that failed.
+type Multi []Channel
+
+func (m Multi) Notify(ctx context.Context, n Notification) error {
+	var errs []error
+	for _, channel := range m {
+		err := channel.Notify(ctx, n)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,217 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticRecipients map[int64]string
+
+func (r staticRecipients) Email(ctx context.Context, userId int64) (string, error) {
+	return r[userId], nil
+}
+
+// fakeSMTPServer accepts a single message and keeps the envelope and data.
+type fakeSMTPServer struct {
+	listener net.Listener
+
+	mu   sync.Mutex
+	from string
+	to   []string
+	data string
+	done chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{listener: listener, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	defer close(s.done)
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost fake SMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mu.Lock()
+			s.to = append(s.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+
+	n := NewSMTPNotifier(
+		SMTPConfig{Host: host, Port: port, From: "library@example.com"},
+		staticRecipients{7: "reader@example.com"},
+	)
+
+	err := n.Notify(context.Background(), Notification{
+		Kind:          KindOverdue,
+		ReservationId: 3,
+		UserId:        7,
+		BookId:        11,
+		DueDate:       time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-server.done
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.from != "library@example.com" {
+		t.Errorf("Expected sender 'library@example.com'; got '%s'", server.from)
+	}
+	if len(server.to) != 1 || server.to[0] != "reader@example.com" {
+		t.Errorf("Expected recipient 'reader@example.com'; got %v", server.to)
+	}
+	if !strings.Contains(server.data, "Subject: Your library loan is overdue") {
+		t.Errorf("Expected overdue subject in message; got %q", server.data)
+	}
+	if !strings.Contains(server.data, "March 10, 2024") {
+		t.Errorf("Expected due date in message; got %q", server.data)
+	}
+}
+
+func TestSMTPNotifierHonoursContext(t *testing.T) {
+	// The listener accepts connections but never greets, like a hung server.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	n := NewSMTPNotifier(SMTPConfig{Host: host, Port: port, From: "library@example.com", Timeout: 50 * time.Millisecond},
+		staticRecipients{7: "reader@example.com"})
+
+	start := time.Now()
+	err = n.Notify(context.Background(), Notification{Kind: KindOverdue, ReservationId: 3, UserId: 7, BookId: 11})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded; got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Notify to give up after the timeout; took %v", elapsed)
+	}
+}
+
+func TestUserServiceRecipients(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/7":
+			w.Write([]byte(`{"id": 7, "name": "Reader", "email": "reader@example.com"}`))
+		case "/users/8":
+			w.Write([]byte(`{"id": 8, "name": "No Mail"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	recipients := NewUserServiceRecipients(server.URL+"/users/", time.Second)
+
+	testCases := []struct {
+		testName      string
+		userId        int64
+		expectedEmail string
+		expectedErr   bool
+	}{
+		{"Known user", 7, "reader@example.com", false},
+		{"User without e-mail", 8, "", true},
+		{"Unknown user", 9, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			email, err := recipients.Email(context.Background(), tc.userId)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("Expected error %v; got %v", tc.expectedErr, err)
+			}
+			if email != tc.expectedEmail {
+				t.Errorf("Expected e-mail '%s'; got '%s'", tc.expectedEmail, email)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sent := Notification{Kind: KindDueSoon, ReservationId: 3, UserId: 7, BookId: 11}
+	err := NewWebhookNotifier(server.URL, time.Second).Notify(context.Background(), sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Kind != sent.Kind || received.ReservationId != sent.ReservationId {
+		t.Errorf("Expected %+v; got %+v", sent, received)
+	}
+}
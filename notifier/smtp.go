@@ -0,0 +1,168 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recipients resolves the e-mail address of a patron.
+type Recipients interface {
+	Email(ctx context.Context, userId int64) (string, error)
+}
+
+// UserServiceRecipients looks e-mail addresses up in the user service, which
+// owns the patrons. baseURL points at the users collection, e.g.
+// "http://localhost:8083/users/".
+type UserServiceRecipients struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewUserServiceRecipients(baseURL string, timeout time.Duration) *UserServiceRecipients {
+	return &UserServiceRecipients{baseURL: baseURL, client: &http.Client{Timeout: timeout}}
+}
+
+func (r *UserServiceRecipients) Email(ctx context.Context, userId int64) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+strconv.FormatInt(userId, 10), nil)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get user. Status code: %d", response.StatusCode)
+	}
+
+	var user struct {
+		Email string `json:"email"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&user)
+	if err != nil {
+		return "", err
+	}
+	if user.Email == "" {
+		return "", errors.New("user has no e-mail address")
+	}
+	return user.Email, nil
+}
+
+// SMTPConfig configures the SMTPNotifier. Timeout bounds a delivery when the
+// context has no earlier deadline; 0 means no bound.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+// SMTPNotifier e-mails notifications to the patron. Authentication is only
+// used when a user is configured.
+type SMTPNotifier struct {
+	cfg        SMTPConfig
+	recipients Recipients
+}
+
+func NewSMTPNotifier(cfg SMTPConfig, recipients Recipients) *SMTPNotifier {
+	return &SMTPNotifier{cfg: cfg, recipients: recipients}
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	to, err := s.recipients.Email(ctx, n.UserId)
+	if err != nil {
+		return fmt.Errorf("could not resolve e-mail of user %d: %w", n.UserId, err)
+	}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	err = s.send(ctx, to, s.message(to, n))
+	if ctx.Err() != nil {
+		return fmt.Errorf("could not e-mail user %d: %w", n.UserId, ctx.Err())
+	}
+	return err
+}
+
+// send delivers the message like smtp.SendMail, but gives up when ctx is
+// done.
+func (s *SMTPNotifier) send(ctx context.Context, to string, message []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, s.cfg.Port))
+	if err != nil {
+		return err
+	}
+	// The SMTP client knows no contexts; closing the connection unblocks it.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: s.cfg.Host})
+		if err != nil {
+			return err
+		}
+	}
+	if s.cfg.User != "" {
+		err = client.Auth(smtp.PlainAuth("", s.cfg.User, s.cfg.Password, s.cfg.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(s.cfg.From)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(to)
+	if err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(message)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *SMTPNotifier) message(to string, n Notification) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + s.cfg.From + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + n.Subject() + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(n.Body() + "\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,52 @@
+// Package openapi holds the OpenAPI 3 description of the REST API. The
+// document is embedded, served at /openapi.json and used by the route tests
+// to check that handlers and spec do not drift apart.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/utils"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+// Load parses and validates the embedded document.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = doc.Validate(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+var specJSON = sync.OnceValues(func() ([]byte, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+})
+
+// Handler serves the document as JSON.
+func Handler(context *gin.Context) {
+	body, err := specJSON()
+	if err != nil {
+		utils.HandleInternalServerError(context, "Could not load the API description!", err)
+		return
+	}
+
+	context.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
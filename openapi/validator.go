@@ -0,0 +1,110 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// Exports are NDJSON, which the spec describes as a plain string.
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", openapi3filter.PlainBodyDecoder)
+}
+
+// Validator checks requests and responses against the document. It is meant
+// for tests: the middleware reports every mismatch instead of rejecting the
+// request, so the handler's own response is what the test sees.
+type Validator struct {
+	router routers.Router
+}
+
+func NewValidator(doc *openapi3.T) (*Validator, error) {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{router: router}, nil
+}
+
+// Middleware validates each request and the response the handler wrote, and
+// passes mismatches to report. Requests the spec rejects are only reported
+// when the handler accepted them, so tests can still send invalid input on
+// purpose. Responses are always validated, including their status code.
+func (v *Validator) Middleware(report func(error)) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var body []byte
+		if context.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(context.Request.Body)
+			if err != nil {
+				report(fmt.Errorf("%s %s: reading body: %w", context.Request.Method, context.Request.URL.Path, err))
+			}
+			context.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		writer := &recordingWriter{ResponseWriter: context.Writer}
+		context.Writer = writer
+		context.Next()
+
+		req := context.Request.Clone(context.Request.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		err := v.validate(req, writer.Status(), writer.Header(), writer.body.Bytes())
+		if err != nil {
+			report(fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err))
+		}
+	}
+}
+
+func (v *Validator) validate(req *http.Request, status int, header http.Header, body []byte) error {
+	route, pathParams, err := v.router.FindRoute(req)
+	if err != nil {
+		return fmt.Errorf("route is not in the spec: %w", err)
+	}
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    &openapi3filter.Options{MultiError: true},
+	}
+	err = openapi3filter.ValidateRequest(req.Context(), input)
+	if err != nil && status < http.StatusBadRequest {
+		return fmt.Errorf("accepted a request the spec rejects: %w", err)
+	}
+
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 status,
+		Header:                 header,
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true, MultiError: true},
+	}
+	responseInput.SetBodyBytes(body)
+	err = openapi3filter.ValidateResponse(req.Context(), responseInput)
+	if err != nil {
+		return fmt.Errorf("response %d does not match the spec: %w", status, err)
+	}
+	return nil
+}
+
+// recordingWriter keeps a copy of the response body for validation.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
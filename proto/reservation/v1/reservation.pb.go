@@ -0,0 +1,673 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: reservation/v1/reservation.proto
+
+package reservationv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Reservation struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	BookId          int64                  `protobuf:"varint,2,opt,name=book_id,json=bookId,proto3" json:"book_id,omitempty"`
+	UserId          int64                  `protobuf:"varint,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	CheckoutDate    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=checkout_date,json=checkoutDate,proto3" json:"checkout_date,omitempty"`
+	ReturnDate      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=return_date,json=returnDate,proto3" json:"return_date,omitempty"`
+	DueDate         *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=due_date,json=dueDate,proto3" json:"due_date,omitempty"`
+	StartDate       *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	EndDate         *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=end_date,json=endDate,proto3" json:"end_date,omitempty"`
+	CopyId          *int64                 `protobuf:"varint,9,opt,name=copy_id,json=copyId,proto3,oneof" json:"copy_id,omitempty"`
+	Branch          string                 `protobuf:"bytes,10,opt,name=branch,proto3" json:"branch,omitempty"`
+	ReturnBranch    string                 `protobuf:"bytes,11,opt,name=return_branch,json=returnBranch,proto3" json:"return_branch,omitempty"`
+	Status          string                 `protobuf:"bytes,12,opt,name=status,proto3" json:"status,omitempty"`
+	StatusChangedAt *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=status_changed_at,json=statusChangedAt,proto3" json:"status_changed_at,omitempty"`
+	StatusNote      string                 `protobuf:"bytes,14,opt,name=status_note,json=statusNote,proto3" json:"status_note,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Reservation) Reset() {
+	*x = Reservation{}
+	mi := &file_reservation_v1_reservation_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reservation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reservation) ProtoMessage() {}
+
+func (x *Reservation) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_v1_reservation_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reservation.ProtoReflect.Descriptor instead.
+func (*Reservation) Descriptor() ([]byte, []int) {
+	return file_reservation_v1_reservation_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Reservation) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Reservation) GetBookId() int64 {
+	if x != nil {
+		return x.BookId
+	}
+	return 0
+}
+
+func (x *Reservation) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Reservation) GetCheckoutDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CheckoutDate
+	}
+	return nil
+}
+
+func (x *Reservation) GetReturnDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReturnDate
+	}
+	return nil
+}
+
+func (x *Reservation) GetDueDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DueDate
+	}
+	return nil
+}
+
+func (x *Reservation) GetStartDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartDate
+	}
+	return nil
+}
+
+func (x *Reservation) GetEndDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndDate
+	}
+	return nil
+}
+
+func (x *Reservation) GetCopyId() int64 {
+	if x != nil && x.CopyId != nil {
+		return *x.CopyId
+	}
+	return 0
+}
+
+func (x *Reservation) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *Reservation) GetReturnBranch() string {
+	if x != nil {
+		return x.ReturnBranch
+	}
+	return ""
+}
+
+func (x *Reservation) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Reservation) GetStatusChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StatusChangedAt
+	}
+	return nil
+}
+
+func (x *Reservation) GetStatusNote() string {
+	if x != nil {
+		return x.StatusNote
+	}
+	return ""
+}
+
+type CreateReservationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BookId        int64                  `protobuf:"varint,1,opt,name=book_id,json=bookId,proto3" json:"book_id,omitempty"`
+	Branch        string                 `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	StartDate     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	EndDate       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end_date,json=endDate,proto3" json:"end_date,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateReservationRequest) Reset() {
+	*x = CreateReservationRequest{}
+	mi := &file_reservation_v1_reservation_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateReservationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateReservationRequest) ProtoMessage() {}
+
+func (x *CreateReservationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_v1_reservation_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateReservationRequest.ProtoReflect.Descriptor instead.
+func (*CreateReservationRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_v1_reservation_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateReservationRequest) GetBookId() int64 {
+	if x != nil {
+		return x.BookId
+	}
+	return 0
+}
+
+func (x *CreateReservationRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *CreateReservationRequest) GetStartDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartDate
+	}
+	return nil
+}
+
+func (x *CreateReservationRequest) GetEndDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndDate
+	}
+	return nil
+}
+
+type CompleteReservationRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// branch is where the copy is handed back; empty means the pickup branch.
+	Branch        string `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompleteReservationRequest) Reset() {
+	*x = CompleteReservationRequest{}
+	mi := &file_reservation_v1_reservation_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompleteReservationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompleteReservationRequest) ProtoMessage() {}
+
+func (x *CompleteReservationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_v1_reservation_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompleteReservationRequest.ProtoReflect.Descriptor instead.
+func (*CompleteReservationRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_v1_reservation_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CompleteReservationRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *CompleteReservationRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+type GetReservationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetReservationRequest) Reset() {
+	*x = GetReservationRequest{}
+	mi := &file_reservation_v1_reservation_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetReservationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetReservationRequest) ProtoMessage() {}
+
+func (x *GetReservationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_v1_reservation_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetReservationRequest.ProtoReflect.Descriptor instead.
+func (*GetReservationRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_v1_reservation_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetReservationRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+// ListReservationsRequest filters like the query parameters of
+// GET /reservations. Empty fields do not filter. Callers without the staff
+// role only see their own reservations.
+type ListReservationsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Branch        string                 `protobuf:"bytes,1,opt,name=branch,proto3" json:"branch,omitempty"`
+	UserId        int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	BookId        int64                  `protobuf:"varint,3,opt,name=book_id,json=bookId,proto3" json:"book_id,omitempty"`
+	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReservationsRequest) Reset() {
+	*x = ListReservationsRequest{}
+	mi := &file_reservation_v1_reservation_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReservationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReservationsRequest) ProtoMessage() {}
+
+func (x *ListReservationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_v1_reservation_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReservationsRequest.ProtoReflect.Descriptor instead.
+func (*ListReservationsRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_v1_reservation_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListReservationsRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *ListReservationsRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *ListReservationsRequest) GetBookId() int64 {
+	if x != nil {
+		return x.BookId
+	}
+	return 0
+}
+
+func (x *ListReservationsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type ListReservationsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reservations  []*Reservation         `protobuf:"bytes,1,rep,name=reservations,proto3" json:"reservations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReservationsResponse) Reset() {
+	*x = ListReservationsResponse{}
+	mi := &file_reservation_v1_reservation_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReservationsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReservationsResponse) ProtoMessage() {}
+
+func (x *ListReservationsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_v1_reservation_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReservationsResponse.ProtoReflect.Descriptor instead.
+func (*ListReservationsResponse) Descriptor() ([]byte, []int) {
+	return file_reservation_v1_reservation_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListReservationsResponse) GetReservations() []*Reservation {
+	if x != nil {
+		return x.Reservations
+	}
+	return nil
+}
+
+type RenewReservationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenewReservationRequest) Reset() {
+	*x = RenewReservationRequest{}
+	mi := &file_reservation_v1_reservation_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenewReservationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenewReservationRequest) ProtoMessage() {}
+
+func (x *RenewReservationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_v1_reservation_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenewReservationRequest.ProtoReflect.Descriptor instead.
+func (*RenewReservationRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_v1_reservation_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RenewReservationRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type CancelReservationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelReservationRequest) Reset() {
+	*x = CancelReservationRequest{}
+	mi := &file_reservation_v1_reservation_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelReservationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelReservationRequest) ProtoMessage() {}
+
+func (x *CancelReservationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reservation_v1_reservation_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelReservationRequest.ProtoReflect.Descriptor instead.
+func (*CancelReservationRequest) Descriptor() ([]byte, []int) {
+	return file_reservation_v1_reservation_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CancelReservationRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+var File_reservation_v1_reservation_proto protoreflect.FileDescriptor
+
+const file_reservation_v1_reservation_proto_rawDesc = "" +
+	"\n" +
+	" reservation/v1/reservation.proto\x12\x0ereservation.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xde\x04\n" +
+	"\vReservation\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x17\n" +
+	"\abook_id\x18\x02 \x01(\x03R\x06bookId\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\x03R\x06userId\x12?\n" +
+	"\rcheckout_date\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\fcheckoutDate\x12;\n" +
+	"\vreturn_date\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"returnDate\x125\n" +
+	"\bdue_date\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\adueDate\x129\n" +
+	"\n" +
+	"start_date\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tstartDate\x125\n" +
+	"\bend_date\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\aendDate\x12\x1c\n" +
+	"\acopy_id\x18\t \x01(\x03H\x00R\x06copyId\x88\x01\x01\x12\x16\n" +
+	"\x06branch\x18\n" +
+	" \x01(\tR\x06branch\x12#\n" +
+	"\rreturn_branch\x18\v \x01(\tR\freturnBranch\x12\x16\n" +
+	"\x06status\x18\f \x01(\tR\x06status\x12F\n" +
+	"\x11status_changed_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\x0fstatusChangedAt\x12\x1f\n" +
+	"\vstatus_note\x18\x0e \x01(\tR\n" +
+	"statusNoteB\n" +
+	"\n" +
+	"\b_copy_id\"\xbd\x01\n" +
+	"\x18CreateReservationRequest\x12\x17\n" +
+	"\abook_id\x18\x01 \x01(\x03R\x06bookId\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\x129\n" +
+	"\n" +
+	"start_date\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tstartDate\x125\n" +
+	"\bend_date\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\aendDate\"D\n" +
+	"\x1aCompleteReservationRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x16\n" +
+	"\x06branch\x18\x02 \x01(\tR\x06branch\"'\n" +
+	"\x15GetReservationRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"{\n" +
+	"\x17ListReservationsRequest\x12\x16\n" +
+	"\x06branch\x18\x01 \x01(\tR\x06branch\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\x12\x17\n" +
+	"\abook_id\x18\x03 \x01(\x03R\x06bookId\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\"[\n" +
+	"\x18ListReservationsResponse\x12?\n" +
+	"\freservations\x18\x01 \x03(\v2\x1b.reservation.v1.ReservationR\freservations\")\n" +
+	"\x17RenewReservationRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"*\n" +
+	"\x18CancelReservationRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id2\x80\x04\n" +
+	"\x12ReservationService\x12O\n" +
+	"\x06Create\x12(.reservation.v1.CreateReservationRequest\x1a\x1b.reservation.v1.Reservation\x12S\n" +
+	"\bComplete\x12*.reservation.v1.CompleteReservationRequest\x1a\x1b.reservation.v1.Reservation\x12I\n" +
+	"\x03Get\x12%.reservation.v1.GetReservationRequest\x1a\x1b.reservation.v1.Reservation\x12Y\n" +
+	"\x04List\x12'.reservation.v1.ListReservationsRequest\x1a(.reservation.v1.ListReservationsResponse\x12M\n" +
+	"\x05Renew\x12'.reservation.v1.RenewReservationRequest\x1a\x1b.reservation.v1.Reservation\x12O\n" +
+	"\x06Cancel\x12(.reservation.v1.CancelReservationRequest\x1a\x1b.reservation.v1.ReservationBdZbgithub.com/shkuran/go-library-microservices/reservation-service/proto/reservation/v1;reservationv1b\x06proto3"
+
+var (
+	file_reservation_v1_reservation_proto_rawDescOnce sync.Once
+	file_reservation_v1_reservation_proto_rawDescData []byte
+)
+
+func file_reservation_v1_reservation_proto_rawDescGZIP() []byte {
+	file_reservation_v1_reservation_proto_rawDescOnce.Do(func() {
+		file_reservation_v1_reservation_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_reservation_v1_reservation_proto_rawDesc), len(file_reservation_v1_reservation_proto_rawDesc)))
+	})
+	return file_reservation_v1_reservation_proto_rawDescData
+}
+
+var file_reservation_v1_reservation_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_reservation_v1_reservation_proto_goTypes = []any{
+	(*Reservation)(nil),                // 0: reservation.v1.Reservation
+	(*CreateReservationRequest)(nil),   // 1: reservation.v1.CreateReservationRequest
+	(*CompleteReservationRequest)(nil), // 2: reservation.v1.CompleteReservationRequest
+	(*GetReservationRequest)(nil),      // 3: reservation.v1.GetReservationRequest
+	(*ListReservationsRequest)(nil),    // 4: reservation.v1.ListReservationsRequest
+	(*ListReservationsResponse)(nil),   // 5: reservation.v1.ListReservationsResponse
+	(*RenewReservationRequest)(nil),    // 6: reservation.v1.RenewReservationRequest
+	(*CancelReservationRequest)(nil),   // 7: reservation.v1.CancelReservationRequest
+	(*timestamppb.Timestamp)(nil),      // 8: google.protobuf.Timestamp
+}
+var file_reservation_v1_reservation_proto_depIdxs = []int32{
+	8,  // 0: reservation.v1.Reservation.checkout_date:type_name -> google.protobuf.Timestamp
+	8,  // 1: reservation.v1.Reservation.return_date:type_name -> google.protobuf.Timestamp
+	8,  // 2: reservation.v1.Reservation.due_date:type_name -> google.protobuf.Timestamp
+	8,  // 3: reservation.v1.Reservation.start_date:type_name -> google.protobuf.Timestamp
+	8,  // 4: reservation.v1.Reservation.end_date:type_name -> google.protobuf.Timestamp
+	8,  // 5: reservation.v1.Reservation.status_changed_at:type_name -> google.protobuf.Timestamp
+	8,  // 6: reservation.v1.CreateReservationRequest.start_date:type_name -> google.protobuf.Timestamp
+	8,  // 7: reservation.v1.CreateReservationRequest.end_date:type_name -> google.protobuf.Timestamp
+	0,  // 8: reservation.v1.ListReservationsResponse.reservations:type_name -> reservation.v1.Reservation
+	1,  // 9: reservation.v1.ReservationService.Create:input_type -> reservation.v1.CreateReservationRequest
+	2,  // 10: reservation.v1.ReservationService.Complete:input_type -> reservation.v1.CompleteReservationRequest
+	3,  // 11: reservation.v1.ReservationService.Get:input_type -> reservation.v1.GetReservationRequest
+	4,  // 12: reservation.v1.ReservationService.List:input_type -> reservation.v1.ListReservationsRequest
+	6,  // 13: reservation.v1.ReservationService.Renew:input_type -> reservation.v1.RenewReservationRequest
+	7,  // 14: reservation.v1.ReservationService.Cancel:input_type -> reservation.v1.CancelReservationRequest
+	0,  // 15: reservation.v1.ReservationService.Create:output_type -> reservation.v1.Reservation
+	0,  // 16: reservation.v1.ReservationService.Complete:output_type -> reservation.v1.Reservation
+	0,  // 17: reservation.v1.ReservationService.Get:output_type -> reservation.v1.Reservation
+	5,  // 18: reservation.v1.ReservationService.List:output_type -> reservation.v1.ListReservationsResponse
+	0,  // 19: reservation.v1.ReservationService.Renew:output_type -> reservation.v1.Reservation
+	0,  // 20: reservation.v1.ReservationService.Cancel:output_type -> reservation.v1.Reservation
+	15, // [15:21] is the sub-list for method output_type
+	9,  // [9:15] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_reservation_v1_reservation_proto_init() }
+func file_reservation_v1_reservation_proto_init() {
+	if File_reservation_v1_reservation_proto != nil {
+		return
+	}
+	file_reservation_v1_reservation_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_reservation_v1_reservation_proto_rawDesc), len(file_reservation_v1_reservation_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_reservation_v1_reservation_proto_goTypes,
+		DependencyIndexes: file_reservation_v1_reservation_proto_depIdxs,
+		MessageInfos:      file_reservation_v1_reservation_proto_msgTypes,
+	}.Build()
+	File_reservation_v1_reservation_proto = out.File
+	file_reservation_v1_reservation_proto_goTypes = nil
+	file_reservation_v1_reservation_proto_depIdxs = nil
+}
@@ -0,0 +1,192 @@
+package report
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/logger"
+	"github.com/shkuran/go-library-microservices/reservation-service/utils"
+)
+
+// groupings lists the groupings each metric supports; the first one is the
+// default.
+var groupings = map[Metric][]GroupBy{
+	MetricLoans:           {GroupByDay, GroupByNone, GroupByWeek, GroupByBook, GroupByUser},
+	MetricPopularBooks:    {GroupByBook},
+	MetricLoanDuration:    {GroupByNone, GroupByWeek, GroupByBook, GroupByUser},
+	MetricOverdueRate:     {GroupByNone, GroupByWeek, GroupByBook, GroupByUser},
+	MetricActiveBorrowers: {GroupByNone, GroupByDay, GroupByWeek, GroupByBook},
+}
+
+// csvKeyColumns names the key column of a grouped report in CSV.
+var csvKeyColumns = map[GroupBy]string{
+	GroupByDay:  "day",
+	GroupByWeek: "week",
+	GroupByBook: "book_id",
+	GroupByUser: "user_id",
+}
+
+const (
+	// defaultDays is how many days are reported when the request names no
+	// start.
+	defaultDays = 30
+	// defaultPopularBooks is how many books the popular books report lists
+	// without a limit.
+	defaultPopularBooks = 10
+)
+
+// Handler serves the /reports endpoints to library staff.
+type Handler struct {
+	store Store
+}
+
+func NewHandler(store Store) Handler {
+	return Handler{store: store}
+}
+
+func (h Handler) Loans(context *gin.Context) {
+	h.serve(context, MetricLoans)
+}
+
+func (h Handler) PopularBooks(context *gin.Context) {
+	h.serve(context, MetricPopularBooks)
+}
+
+func (h Handler) LoanDuration(context *gin.Context) {
+	h.serve(context, MetricLoanDuration)
+}
+
+func (h Handler) OverdueRate(context *gin.Context) {
+	h.serve(context, MetricOverdueRate)
+}
+
+func (h Handler) ActiveBorrowers(context *gin.Context) {
+	h.serve(context, MetricActiveBorrowers)
+}
+
+// serve renders a report as JSON, or as CSV with format=csv.
+func (h Handler) serve(context *gin.Context, metric Metric) {
+	if !utils.IsStaff(context) {
+		utils.HandleStatusForbidden(context, "Only staff can view reports!", nil)
+		return
+	}
+
+	query, err := parseQuery(context, metric)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse report parameters!", err)
+		return
+	}
+
+	format := context.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		utils.HandleBadRequest(context, "Could not parse report parameters!", fmt.Errorf("unknown format %q", format))
+		return
+	}
+
+	rows, err := h.store.Run(context.Request.Context(), query)
+	if err != nil {
+		utils.HandleInternalServerError(context, "Could not compute report!", err)
+		return
+	}
+
+	report := Report{
+		Metric:  metric,
+		GroupBy: query.GroupBy,
+		From:    query.From,
+		To:      query.To,
+		Rows:    rows,
+	}
+	if format == "csv" {
+		writeCSV(context, report)
+		return
+	}
+	context.JSON(http.StatusOK, report)
+}
+
+// parseQuery reads the from and to dates (both inclusive, YYYY-MM-DD),
+// group_by and limit query parameters. The report covers the last
+// defaultDays days up to today by default.
+func parseQuery(context *gin.Context, metric Metric) (Query, error) {
+	query := Query{Metric: metric, GroupBy: groupings[metric][0]}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	if value := context.Query("to"); value != "" {
+		var err error
+		to, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return Query{}, fmt.Errorf("to: %w", err)
+		}
+	}
+	query.To = to.AddDate(0, 0, 1)
+
+	query.From = to.AddDate(0, 0, 1-defaultDays)
+	if value := context.Query("from"); value != "" {
+		var err error
+		query.From, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return Query{}, fmt.Errorf("from: %w", err)
+		}
+	}
+	if !query.From.Before(query.To) {
+		return Query{}, errors.New("from is after to")
+	}
+
+	if value, ok := context.GetQuery("group_by"); ok {
+		query.GroupBy = GroupBy(value)
+		if value == "none" {
+			query.GroupBy = GroupByNone
+		}
+		if !slices.Contains(groupings[metric], query.GroupBy) {
+			return Query{}, fmt.Errorf("%s cannot be grouped by %q", metric, value)
+		}
+	}
+
+	if metric == MetricPopularBooks {
+		query.Limit = defaultPopularBooks
+	}
+	if value := context.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return Query{}, fmt.Errorf("limit: invalid value %q", value)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+func writeCSV(context *gin.Context, report Report) {
+	context.Header("Content-Type", "text/csv")
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, report.Metric))
+	context.Status(http.StatusOK)
+
+	grouped := report.GroupBy != GroupByNone
+	w := csv.NewWriter(context.Writer)
+	header := []string{string(report.Metric)}
+	if grouped {
+		header = []string{csvKeyColumns[report.GroupBy], string(report.Metric)}
+	}
+	w.Write(header)
+	for _, row := range report.Rows {
+		value := strconv.FormatFloat(row.Value, 'f', -1, 64)
+		if grouped {
+			w.Write([]string{row.Key, value})
+		} else {
+			w.Write([]string{value})
+		}
+	}
+	w.Flush()
+
+	// The writer keeps the first error. The status is sent already, so the
+	// client only sees the report end early.
+	err := w.Error()
+	if err != nil {
+		logger.FromContext(context.Request.Context()).Error("Could not write report!", "error", err, "metric", report.Metric)
+	}
+}
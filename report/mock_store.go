@@ -0,0 +1,127 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+// MockStore computes reports over reservations held in memory.
+type MockStore struct {
+	reservations []reservation.Reservation
+	now          time.Time
+}
+
+func NewMockStore(reservations []reservation.Reservation, now time.Time) *MockStore {
+	return &MockStore{reservations: reservations, now: now}
+}
+
+func (s *MockStore) Run(ctx context.Context, query Query) ([]Row, error) {
+	type group struct {
+		key    string
+		order  int64
+		loans  int64
+		sum    float64
+		values int64
+		users  map[int64]bool
+	}
+	groups := make(map[string]*group)
+
+	for _, res := range s.reservations {
+		if res.PickedUpAt == nil || res.PickedUpAt.Before(query.From) || !res.PickedUpAt.Before(query.To) {
+			continue
+		}
+		pickedUp := *res.PickedUpAt
+
+		g := &group{}
+		switch query.GroupBy {
+		case GroupByNone:
+		case GroupByDay:
+			g.key = pickedUp.Format(time.DateOnly)
+		case GroupByWeek:
+			daysSinceMonday := (int(pickedUp.Weekday()) + 6) % 7
+			g.key = pickedUp.AddDate(0, 0, -daysSinceMonday).Format(time.DateOnly)
+		case GroupByBook:
+			g.key, g.order = strconv.FormatInt(res.BookId, 10), res.BookId
+		case GroupByUser:
+			g.key, g.order = strconv.FormatInt(res.UserId, 10), res.UserId
+		default:
+			return nil, fmt.Errorf("unknown grouping %q", query.GroupBy)
+		}
+		if existing, ok := groups[g.key]; ok {
+			g = existing
+		} else {
+			g.users = make(map[int64]bool)
+			groups[g.key] = g
+		}
+
+		g.loans++
+		g.users[res.UserId] = true
+		switch query.Metric {
+		case MetricLoanDuration:
+			if res.ReturnDate != nil {
+				g.sum += res.ReturnDate.Sub(pickedUp).Hours() / 24
+				g.values++
+			}
+		case MetricOverdueRate:
+			if res.Status == reservation.StatusLost || res.Status == reservation.StatusDamaged {
+				break
+			}
+			end := s.now
+			if res.ReturnDate != nil {
+				end = *res.ReturnDate
+			}
+			if res.DueDate != nil && end.After(*res.DueDate) {
+				g.sum++
+			}
+			g.values++
+		}
+	}
+
+	rows := []Row{}
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].order != sorted[j].order {
+			return sorted[i].order < sorted[j].order
+		}
+		return sorted[i].key < sorted[j].key
+	})
+
+	for _, g := range sorted {
+		row := Row{Key: g.key}
+		switch query.Metric {
+		case MetricLoans, MetricPopularBooks:
+			row.Value = float64(g.loans)
+		case MetricLoanDuration, MetricOverdueRate:
+			if g.values == 0 {
+				continue
+			}
+			row.Value = g.sum / float64(g.values)
+		case MetricActiveBorrowers:
+			row.Value = float64(len(g.users))
+		default:
+			return nil, fmt.Errorf("unknown metric %q", query.Metric)
+		}
+		rows = append(rows, row)
+	}
+
+	// Like an SQL aggregate without GROUP BY, an ungrouped report has a row
+	// even without loans.
+	if query.GroupBy == GroupByNone && len(rows) == 0 {
+		rows = append(rows, Row{})
+	}
+	if query.Metric == MetricPopularBooks {
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Value > rows[j].Value })
+	}
+	if query.Limit > 0 && len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+	}
+	return rows, nil
+}
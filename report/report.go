@@ -0,0 +1,67 @@
+package report
+
+import (
+	"context"
+	"time"
+)
+
+// Metric is a circulation statistic. All metrics count loans: reservations
+// that were picked up, dated by their pickup time.
+type Metric string
+
+const (
+	// MetricLoans is the number of loans.
+	MetricLoans Metric = "loans"
+	// MetricPopularBooks is the number of loans per book, most borrowed first.
+	MetricPopularBooks Metric = "popular_books"
+	// MetricLoanDuration is the average number of days returned loans lasted.
+	MetricLoanDuration Metric = "loan_duration_days"
+	// MetricOverdueRate is the share of loans returned late or still out
+	// past their due date, between 0 and 1. Lost and damaged loans are left
+	// out.
+	MetricOverdueRate Metric = "overdue_rate"
+	// MetricActiveBorrowers is the number of distinct patrons with a loan.
+	MetricActiveBorrowers Metric = "active_borrowers"
+)
+
+// GroupBy splits a report into rows.
+type GroupBy string
+
+const (
+	GroupByNone GroupBy = ""
+	GroupByDay  GroupBy = "day"
+	// GroupByWeek keys rows by the Monday the week starts on.
+	GroupByWeek GroupBy = "week"
+	GroupByBook GroupBy = "book"
+	GroupByUser GroupBy = "user"
+)
+
+// Query selects a report. From is inclusive and To exclusive. Limit caps the
+// number of rows; 0 means no limit.
+type Query struct {
+	Metric  Metric
+	GroupBy GroupBy
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+// Row is one group of a report. Key is the day, week, book ID or user ID of
+// the group, and empty for an ungrouped report.
+type Row struct {
+	Key   string  `json:"key"`
+	Value float64 `json:"value"`
+}
+
+type Report struct {
+	Metric  Metric    `json:"metric"`
+	GroupBy GroupBy   `json:"group_by,omitempty"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Rows    []Row     `json:"rows"`
+}
+
+// Store computes reports from the reservations.
+type Store interface {
+	Run(ctx context.Context, query Query) ([]Row, error)
+}
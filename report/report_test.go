@@ -0,0 +1,222 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/db"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+func date(day string, hour int) time.Time {
+	t, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		panic(err)
+	}
+	return t.Add(time.Duration(hour) * time.Hour)
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+// loans holds four loans started in January 2026, one reservation that was
+// never picked up and two loans started in February, one of them reserved in
+// January.
+var loans = []reservation.Reservation{
+	{ID: 1, BookId: 1, UserId: 1, CheckoutDate: date("2026-01-04", 10), PickedUpAt: ptr(date("2026-01-05", 10)), DueDate: ptr(date("2026-01-19", 10)), ReturnDate: ptr(date("2026-01-09", 10)), Status: reservation.StatusReturned},
+	{ID: 2, BookId: 1, UserId: 2, CheckoutDate: date("2026-01-06", 12), PickedUpAt: ptr(date("2026-01-06", 12)), DueDate: ptr(date("2026-01-20", 12)), ReturnDate: ptr(date("2026-01-26", 12)), Status: reservation.StatusReturned},
+	{ID: 3, BookId: 2, UserId: 1, CheckoutDate: date("2026-01-12", 9), PickedUpAt: ptr(date("2026-01-12", 9)), DueDate: ptr(date("2026-01-26", 9)), Status: reservation.StatusOverdue},
+	{ID: 4, BookId: 1, UserId: 3, CheckoutDate: date("2026-01-13", 9), PickedUpAt: ptr(date("2026-01-13", 9)), DueDate: ptr(date("2026-01-27", 9)), ReturnDate: ptr(date("2026-01-15", 9)), Status: reservation.StatusReturned},
+	{ID: 5, BookId: 3, UserId: 1, CheckoutDate: date("2026-01-13", 9), Status: reservation.StatusRequested},
+	{ID: 6, BookId: 2, UserId: 2, CheckoutDate: date("2026-02-10", 9), PickedUpAt: ptr(date("2026-02-10", 9)), DueDate: ptr(date("2026-02-24", 9)), Status: reservation.StatusActive},
+	{ID: 7, BookId: 3, UserId: 2, CheckoutDate: date("2026-01-30", 9), PickedUpAt: ptr(date("2026-02-02", 9)), DueDate: ptr(date("2026-02-16", 9)), Status: reservation.StatusActive},
+}
+
+// newSQLiteStore returns an SQLStore on an in-memory SQLite database holding
+// reservations.
+func newSQLiteStore(t *testing.T, reservations []reservation.Reservation) *SQLStore {
+	t.Helper()
+	conn, err := sql.Open("sqlite", db.SQLiteDSN(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+
+	err = db.Migrate(conn, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range reservations {
+		_, err := conn.Exec(`INSERT INTO reservations (id, book_id, user_id, checkout_date, picked_up_at, due_date, return_date, status, status_changed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $4)`,
+			res.ID, res.BookId, res.UserId, res.CheckoutDate, res.PickedUpAt, res.DueDate, res.ReturnDate, res.Status)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewSQLStore(conn, db.SQLite)
+}
+
+func TestStores(t *testing.T) {
+	january := func(metric Metric, groupBy GroupBy) Query {
+		return Query{Metric: metric, GroupBy: groupBy, From: date("2026-01-01", 0), To: date("2026-02-01", 0)}
+	}
+	limited := january(MetricPopularBooks, GroupByBook)
+	limited.Limit = 1
+	empty := january(MetricLoanDuration, GroupByNone)
+	empty.From, empty.To = date("2025-01-01", 0), date("2025-02-01", 0)
+
+	testCases := []struct {
+		testName string
+		query    Query
+		expected []Row
+	}{
+		{"Loans", january(MetricLoans, GroupByNone), []Row{{"", 4}}},
+		{"Loans per day", january(MetricLoans, GroupByDay), []Row{{"2026-01-05", 1}, {"2026-01-06", 1}, {"2026-01-12", 1}, {"2026-01-13", 1}}},
+		{"Loans per week", january(MetricLoans, GroupByWeek), []Row{{"2026-01-05", 2}, {"2026-01-12", 2}}},
+		{"Loans per user", january(MetricLoans, GroupByUser), []Row{{"1", 2}, {"2", 1}, {"3", 1}}},
+		{"Popular books", january(MetricPopularBooks, GroupByBook), []Row{{"1", 3}, {"2", 1}}},
+		{"Most popular book", limited, []Row{{"1", 3}}},
+		{"Loan duration", january(MetricLoanDuration, GroupByNone), []Row{{"", 26.0 / 3}}},
+		{"Loan duration per book", january(MetricLoanDuration, GroupByBook), []Row{{"1", 26.0 / 3}}},
+		{"Loan duration without loans", empty, []Row{{"", 0}}},
+		{"Overdue rate", january(MetricOverdueRate, GroupByNone), []Row{{"", 0.5}}},
+		{"Overdue rate per user", january(MetricOverdueRate, GroupByUser), []Row{{"1", 0.5}, {"2", 1}, {"3", 0}}},
+		{"Active borrowers", january(MetricActiveBorrowers, GroupByNone), []Row{{"", 3}}},
+		{"Active borrowers per week", january(MetricActiveBorrowers, GroupByWeek), []Row{{"2026-01-05", 2}, {"2026-01-12", 2}}},
+	}
+
+	stores := map[string]Store{
+		"SQLite": newSQLiteStore(t, loans),
+		"Mock":   NewMockStore(loans, time.Now()),
+	}
+	for name, store := range stores {
+		for _, tc := range testCases {
+			t.Run(name+"/"+tc.testName, func(t *testing.T) {
+				rows, err := store.Run(context.Background(), tc.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(rows) != len(tc.expected) {
+					t.Fatalf("Expected %v; got %v", tc.expected, rows)
+				}
+				for i, row := range rows {
+					if row.Key != tc.expected[i].Key || math.Abs(row.Value-tc.expected[i].Value) > 1e-6 {
+						t.Errorf("Expected %v; got %v", tc.expected, rows)
+						break
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestOverdueRateLeavesOutLostLoans(t *testing.T) {
+	closed := []reservation.Reservation{
+		{ID: 1, BookId: 1, UserId: 1, CheckoutDate: date("2026-01-05", 10), PickedUpAt: ptr(date("2026-01-05", 10)), DueDate: ptr(date("2026-01-19", 10)), ReturnDate: ptr(date("2026-01-09", 10)), Status: reservation.StatusReturned},
+		{ID: 2, BookId: 2, UserId: 1, CheckoutDate: date("2026-01-06", 10), PickedUpAt: ptr(date("2026-01-06", 10)), DueDate: ptr(date("2026-01-20", 10)), Status: reservation.StatusLost},
+		{ID: 3, BookId: 3, UserId: 2, CheckoutDate: date("2026-01-07", 10), PickedUpAt: ptr(date("2026-01-07", 10)), DueDate: ptr(date("2026-01-21", 10)), Status: reservation.StatusDamaged},
+	}
+	query := Query{Metric: MetricOverdueRate, GroupBy: GroupByUser, From: date("2026-01-01", 0), To: date("2026-02-01", 0)}
+
+	stores := map[string]Store{
+		"SQLite": newSQLiteStore(t, closed),
+		"Mock":   NewMockStore(closed, time.Now()),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			rows, err := store.Run(context.Background(), query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 || rows[0] != (Row{"1", 0}) {
+				t.Errorf("Expected only the returned loan of user 1; got %v", rows)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(NewMockStore(loans, time.Now()))
+	server := gin.New()
+	server.GET("/reports/loans", handler.Loans)
+	server.GET("/reports/popular-books", handler.PopularBooks)
+
+	testCases := []struct {
+		testName     string
+		path         string
+		staff        bool
+		expectedCode int
+		expectedBody string
+	}{
+		{"Patron", "/reports/loans", false, http.StatusForbidden, ""},
+		{"Per week as CSV", "/reports/loans?from=2026-01-01&to=2026-01-31&group_by=week&format=csv", true, http.StatusOK,
+			"week,loans\n2026-01-05,2\n2026-01-12,2\n"},
+		{"Ungrouped as CSV", "/reports/loans?from=2026-01-01&to=2026-01-31&group_by=none&format=csv", true, http.StatusOK,
+			"loans\n4\n"},
+		{"Last day included", "/reports/loans?from=2026-01-13&to=2026-01-13&format=csv", true, http.StatusOK,
+			"day,loans\n2026-01-13,1\n"},
+		{"Popular books by default", "/reports/popular-books?from=2026-01-01&to=2026-01-31&format=csv", true, http.StatusOK,
+			"book_id,popular_books\n1,3\n2,1\n"},
+		{"Unsupported grouping", "/reports/popular-books?group_by=user", true, http.StatusBadRequest, ""},
+		{"Malformed date", "/reports/loans?from=01.01.2026", true, http.StatusBadRequest, ""},
+		{"Reversed dates", "/reports/loans?from=2026-02-01&to=2026-01-01", true, http.StatusBadRequest, ""},
+		{"Invalid limit", "/reports/loans?limit=0", true, http.StatusBadRequest, ""},
+		{"Unknown format", "/reports/loans?format=xml", true, http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.staff {
+				req.Header.Set("UserRole", "staff")
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Fatalf("Expected status code %d, but got %d: %s", tc.expectedCode, w.Code, w.Body.String())
+			}
+			if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %q; got %q", tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewHandler(NewMockStore(loans, time.Now()))
+	server := gin.New()
+	server.GET("/reports/overdue-rate", handler.OverdueRate)
+
+	req := httptest.NewRequest("GET", "/reports/overdue-rate?from=2026-01-01&to=2026-01-31&group_by=user", nil)
+	req.Header.Set("UserRole", "staff")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var report Report
+	err := json.Unmarshal(w.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Metric != MetricOverdueRate || report.GroupBy != GroupByUser || !report.To.Equal(date("2026-02-01", 0)) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(report.Rows) != 3 || report.Rows[1] != (Row{"2", 1}) {
+		t.Errorf("Unexpected rows %+v", report.Rows)
+	}
+}
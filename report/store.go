@@ -0,0 +1,134 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/db"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+	"github.com/shkuran/go-library-microservices/reservation-service/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otel.Tracer("github.com/shkuran/go-library-microservices/reservation-service/report")
+
+// dateKeys turn picked_up_at into the text key of its day or week. Weeks
+// start on Monday.
+var dateKeys = map[db.Dialect]map[GroupBy]string{
+	db.Postgres: {
+		GroupByDay:  "to_char(picked_up_at, 'YYYY-MM-DD')",
+		GroupByWeek: "to_char(date_trunc('week', picked_up_at), 'YYYY-MM-DD')",
+	},
+	db.SQLite: {
+		GroupByDay:  "strftime('%Y-%m-%d', picked_up_at)",
+		GroupByWeek: "strftime('%Y-%m-%d', picked_up_at, 'weekday 0', '-6 days')",
+	},
+}
+
+// loanDays is the length of a returned loan in days.
+var loanDays = map[db.Dialect]string{
+	db.Postgres: "EXTRACT(EPOCH FROM return_date - picked_up_at) / 86400",
+	db.SQLite:   "julianday(return_date) - julianday(picked_up_at)",
+}
+
+// SQLStore computes reports with aggregates over the reservations table. It
+// only reads, so it can run on a replica.
+type SQLStore struct {
+	db      *sql.DB
+	dialect db.Dialect
+}
+
+func NewSQLStore(conn *sql.DB, dialect db.Dialect) *SQLStore {
+	return &SQLStore{db: conn, dialect: dialect}
+}
+
+func (s *SQLStore) Run(ctx context.Context, query Query) ([]Row, error) {
+	ctx, span := tracer.Start(ctx, "SQLStore.Run")
+	defer span.End()
+	span.SetAttributes(attribute.String("report.metric", string(query.Metric)), attribute.String("report.group_by", string(query.GroupBy)))
+
+	statement, args, err := s.build(query)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	result := []Row{}
+	for rows.Next() {
+		var row Row
+		var value sql.NullFloat64
+		err := rows.Scan(&row.Key, &value)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		row.Value = value.Float64
+		result = append(result, row)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	return result, nil
+}
+
+// build returns the statement computing query. Loans are reservations whose
+// copy was picked up, and they count from then on: a reservation may wait on
+// the shelf for days before the loan starts.
+func (s *SQLStore) build(query Query) (string, []any, error) {
+	args := []any{query.From, query.To}
+	where := "picked_up_at >= $1 AND picked_up_at < $2"
+
+	var value string
+	switch query.Metric {
+	case MetricLoans, MetricPopularBooks:
+		value = "COUNT(*)"
+	case MetricLoanDuration:
+		value = "AVG(" + loanDays[s.dialect] + ")"
+		where += " AND return_date IS NOT NULL"
+	case MetricOverdueRate:
+		value = "AVG(CASE WHEN COALESCE(return_date, $3) > due_date THEN 1.0 ELSE 0.0 END)"
+		// Lost and damaged loans are closed without a return date.
+		where += " AND status NOT IN ($4, $5)"
+		args = append(args, time.Now(), reservation.StatusLost, reservation.StatusDamaged)
+	case MetricActiveBorrowers:
+		value = "COUNT(DISTINCT user_id)"
+	default:
+		return "", nil, fmt.Errorf("unknown metric %q", query.Metric)
+	}
+
+	key, order := "''", ""
+	switch query.GroupBy {
+	case GroupByNone:
+	case GroupByDay, GroupByWeek:
+		key = dateKeys[s.dialect][query.GroupBy]
+		order = "group_key"
+	case GroupByBook:
+		key, order = "book_id", "book_id"
+	case GroupByUser:
+		key, order = "user_id", "user_id"
+	default:
+		return "", nil, fmt.Errorf("unknown grouping %q", query.GroupBy)
+	}
+	if query.Metric == MetricPopularBooks {
+		order = "value DESC, " + order
+	}
+
+	statement := "SELECT " + key + " AS group_key, " + value + " AS value FROM reservations WHERE " + where
+	if order != "" {
+		statement += " GROUP BY " + key + " ORDER BY " + order
+	}
+	if query.Limit > 0 {
+		statement += " LIMIT " + strconv.Itoa(query.Limit)
+	}
+	return statement, args, nil
+}
@@ -0,0 +1,399 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/metrics"
+	"github.com/shkuran/go-library-microservices/reservation-service/utils"
+)
+
+// Per-item outcomes of a batch request.
+const (
+	ItemOK       = "ok"
+	ItemRejected = "rejected"
+	ItemSkipped  = "skipped"
+	ItemFailed   = "failed"
+)
+
+type BatchItemResult struct {
+	BookId        int64  `json:"book_id"`
+	ReservationId int64  `json:"reservation_id,omitempty"`
+	Status        string `json:"status"`
+	Message       string `json:"message,omitempty"`
+}
+
+type batchCheckoutRequest struct {
+	BookIds []int64 `json:"book_ids" binding:"required,min=1,max=20"`
+	Branch  string  `json:"branch"`
+	Atomic  bool    `json:"atomic"`
+}
+
+// batchCompleteRequest names the version each reservation was last read at,
+// in the order of ReservationIds. Versions may only be left out with
+// "If-Match: *".
+type batchCompleteRequest struct {
+	ReservationIds []int64 `json:"reservation_ids" binding:"required,min=1,max=20"`
+	Versions       []int64 `json:"versions"`
+	Atomic         bool    `json:"atomic"`
+}
+
+// BatchResult holds the per-item outcomes of a batch. Applied is false when
+// an atomic batch was refused as a whole.
+type BatchResult struct {
+	Applied bool              `json:"applied"`
+	Results []BatchItemResult `json:"results"`
+}
+
+// AddReservations checks out several books for one patron; see
+// Service.ReserveBatch.
+func (h Handler) AddReservations(context *gin.Context) {
+	var request batchCheckoutRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse request data!", err)
+		return
+	}
+
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
+		return
+	}
+
+	batch, err := h.service.ReserveBatch(serviceContext(context), userId, request.BookIds, request.Branch, request.Atomic)
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
+
+	context.JSON(batchStatus(batch, http.StatusCreated), batch)
+}
+
+// CompleteReservations returns several reservations of one patron; see
+// Service.ReturnBatch.
+func (h Handler) CompleteReservations(context *gin.Context) {
+	var request batchCompleteRequest
+	err := context.ShouldBindJSON(&request)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse request data!", err)
+		return
+	}
+
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
+		return
+	}
+
+	switch {
+	case request.Versions == nil && context.Request.Header.Get("If-Match") != "*":
+		utils.HandlePreconditionRequired(context, "The versions of the reservations are required!", nil)
+		return
+	case request.Versions != nil && len(request.Versions) != len(request.ReservationIds):
+		utils.HandleBadRequest(context, "Could not parse request data!", errors.New("versions do not match reservation_ids"))
+		return
+	}
+
+	batch, err := h.service.ReturnBatch(serviceContext(context), userId, request.ReservationIds, request.Versions, request.Atomic)
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
+
+	context.JSON(batchStatus(batch, http.StatusOK), batch)
+}
+
+// ReserveBatch checks out several books for one patron. All items are
+// validated first. In atomic mode nothing is applied unless every item is
+// valid; otherwise valid items are applied and rejected ones reported.
+// The book service is updated once per distinct book. The whole batch counts
+// against the branch's borrowing limit, and like Reserve, no item may take a
+// copy promised to a booking during its loan period.
+func (s *Service) ReserveBatch(ctx context.Context, userId int64, bookIds []int64, branch string, atomic bool) (BatchResult, error) {
+	err := s.checkBranch(ctx, userId, &branch, int64(len(bookIds)))
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	// A copy on the shelf may still be promised to a booking that starts
+	// before the loans would end.
+	now := time.Now()
+	loanEnd := now.Add(s.policy.loanPeriod(branch))
+
+	books := make(map[int64]Book)
+	remaining := make(map[int64]int64)
+	free := make(map[int64]int64)
+	results := make([]BatchItemResult, len(bookIds))
+	seen := make(map[int64]bool)
+	for i, bookId := range bookIds {
+		results[i] = BatchItemResult{BookId: bookId, Status: ItemOK}
+
+		// A patron holds at most one open reservation per book.
+		if seen[bookId] {
+			results[i].Status, results[i].Message = ItemRejected, "The book is already in the batch!"
+			continue
+		}
+		seen[bookId] = true
+
+		if _, ok := books[bookId]; !ok {
+			book, err := s.books.GetBook(ctx, bookId)
+			if err != nil {
+				results[i].Status, results[i].Message = ItemRejected, "Could not fetch book!"
+				continue
+			}
+			available, err := s.availableCopies(ctx, book)
+			if err != nil {
+				results[i].Status, results[i].Message = ItemRejected, "Could not check the book availability!"
+				continue
+			}
+			unbooked, err := s.freeCopies(ctx, book, now, loanEnd)
+			if err != nil {
+				results[i].Status, results[i].Message = ItemRejected, "Could not check the book availability!"
+				continue
+			}
+			books[bookId] = book
+			remaining[bookId] = available
+			free[bookId] = unbooked
+		}
+
+		// Copies taken by earlier items of the batch are no longer free.
+		switch {
+		case remaining[bookId] < 1:
+			metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnavailable).Inc()
+			results[i].Status, results[i].Message = ItemRejected, "The book is not available!"
+			continue
+		case free[bookId] < 1:
+			metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnavailable).Inc()
+			results[i].Status, results[i].Message = ItemRejected, "The book is booked by someone else during the loan period!"
+			continue
+		}
+		remaining[bookId]--
+		free[bookId]--
+	}
+
+	if atomic && hasRejected(results) {
+		skipValid(results)
+		return BatchResult{Applied: false, Results: results}, nil
+	}
+
+	// The copies are taken first: the book service refuses them when a
+	// cached count was stale.
+	wanted := make(map[int64]int64)
+	for _, result := range results {
+		if result.Status == ItemOK {
+			wanted[result.BookId]++
+		}
+	}
+	taken := make(map[int64]int64)
+	giveBack := func() {
+		for bookId, count := range taken {
+			if count > 0 {
+				s.giveBackCopies(ctx, bookId, count)
+			}
+		}
+	}
+	for bookId, count := range wanted {
+		err := s.takeCopies(ctx, bookId, count)
+		if errors.Is(err, ErrUnavailable) {
+			for i := range results {
+				if results[i].BookId == bookId && results[i].Status == ItemOK {
+					results[i].Status, results[i].Message = ItemRejected, "The book is not available!"
+				}
+			}
+			continue
+		}
+		if err != nil {
+			giveBack()
+			return BatchResult{}, err
+		}
+		taken[bookId] = count
+	}
+	if atomic && hasRejected(results) {
+		giveBack()
+		skipValid(results)
+		return BatchResult{Applied: false, Results: results}, nil
+	}
+
+	created, err := s.applyItems(ctx, results, atomic, EventCreated, func(tx Repository, i int) (Reservation, error) {
+		reservationId, err := tx.Save(ctx, Reservation{BookId: results[i].BookId, UserId: userId, Branch: branch})
+		if err != nil {
+			return Reservation{}, saveFailed(err)
+		}
+		results[i].ReservationId = reservationId
+		return recordEvent(ctx, tx, EventCreated, userId, nil, reservationId)
+	})
+	if err != nil {
+		giveBack()
+		return BatchResult{}, err
+	}
+
+	// The copies of items that could not be stored go back.
+	for _, res := range created {
+		metrics.ReservationsCreated.Inc()
+		taken[res.BookId]--
+	}
+	giveBack()
+
+	return BatchResult{Applied: true, Results: results}, nil
+}
+
+// ReturnBatch returns several reservations of one patron with the same
+// validation and atomic semantics as ReserveBatch. Unless versions is nil it
+// holds the version each reservation must still be at; if one was changed
+// since, nothing is returned and the error is ErrStaleVersion.
+func (s *Service) ReturnBatch(ctx context.Context, userId int64, reservationIds, versions []int64, atomic bool) (BatchResult, error) {
+	reservations := make([]Reservation, len(reservationIds))
+	results := make([]BatchItemResult, len(reservationIds))
+	seen := make(map[int64]bool)
+	stale := false
+	for i, reservationId := range reservationIds {
+		results[i] = BatchItemResult{ReservationId: reservationId, Status: ItemOK}
+
+		reservation, err := s.repo.GetById(ctx, reservationId)
+		if err != nil {
+			results[i].Status, results[i].Message = ItemRejected, "Could not fetch reservation!"
+			continue
+		}
+		reservations[i] = reservation
+		results[i].BookId = reservation.BookId
+		if versions != nil && reservation.Version != versions[i] {
+			stale = true
+		}
+
+		switch {
+		case reservation.UserId != userId:
+			metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnauthorized).Inc()
+			results[i].Status, results[i].Message = ItemRejected, "Not access to copmlete reservation!"
+		case seen[reservationId] || reservation.ReturnDate != nil || reservation.Status == StatusReturned:
+			results[i].Status, results[i].Message = ItemRejected, "The reservation is copleted already!"
+		case !reservation.Status.returnable():
+			results[i].Status, results[i].Message = ItemRejected, "Could not complete "+string(reservation.Status)+" reservation!"
+		}
+		seen[reservationId] = true
+	}
+	if stale {
+		return BatchResult{}, staleVersion()
+	}
+
+	if atomic && hasRejected(results) {
+		skipValid(results)
+		return BatchResult{Applied: false, Results: results}, nil
+	}
+
+	completed, err := s.applyItems(ctx, results, atomic, EventCompleted, func(tx Repository, i int) (Reservation, error) {
+		if err := returnFailed(tx.UpdateReturnDate(ctx, results[i].ReservationId)); err != nil {
+			return Reservation{}, err
+		}
+		return recordEvent(ctx, tx, EventCompleted, userId, &reservations[i], results[i].ReservationId)
+	})
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	returned := make(map[int64]int64)
+	for _, res := range completed {
+		metrics.ReservationsCompleted.Inc()
+		returned[res.BookId]++
+	}
+
+	for bookId, count := range returned {
+		err := s.restoreCopies(ctx, bookId, count)
+		if err != nil {
+			return BatchResult{}, err
+		}
+	}
+
+	return BatchResult{Applied: true, Results: results}, nil
+}
+
+// applyItems applies the valid items of a batch, each together with its
+// history entry. An atomic batch is applied in one transaction and fails as a
+// whole; otherwise every item gets its own transaction and a failure is
+// reported in the item's result. The stored reservations are returned and
+// their domain events published once committed.
+func (s *Service) applyItems(ctx context.Context, results []BatchItemResult, atomic bool, eventType EventType,
+	apply func(tx Repository, i int) (Reservation, error)) ([]Reservation, error) {
+	var applied []Reservation
+	if atomic {
+		err := s.repo.WithTx(ctx, func(tx Repository) error {
+			for i := range results {
+				if results[i].Status != ItemOK {
+					continue
+				}
+				after, err := apply(tx, i)
+				if err != nil {
+					return err
+				}
+				applied = append(applied, after)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, asServiceError(err)
+		}
+	} else {
+		for i := range results {
+			if results[i].Status != ItemOK {
+				continue
+			}
+			var after Reservation
+			err := s.repo.WithTx(ctx, func(tx Repository) error {
+				var err error
+				after, err = apply(tx, i)
+				return err
+			})
+			if err != nil {
+				var serviceErr *Error
+				errors.As(asServiceError(err), &serviceErr)
+				results[i].Status, results[i].Message = ItemFailed, serviceErr.Message
+				continue
+			}
+			applied = append(applied, after)
+		}
+	}
+
+	for _, after := range applied {
+		publish(ctx, s.publisher, eventType, after)
+	}
+	return applied, nil
+}
+
+func hasRejected(results []BatchItemResult) bool {
+	for _, result := range results {
+		if result.Status != ItemOK {
+			return true
+		}
+	}
+	return false
+}
+
+// skipValid marks items that passed validation but were not applied because
+// another item of an atomic batch was rejected.
+func skipValid(results []BatchItemResult) {
+	for i := range results {
+		if results[i].Status == ItemOK {
+			results[i].Status = ItemSkipped
+		}
+	}
+}
+
+// batchStatus is success when every item was applied, 207 Multi-Status when
+// only some were and 400 when an atomic batch was refused.
+func batchStatus(batch BatchResult, success int) int {
+	switch {
+	case !batch.Applied:
+		return http.StatusBadRequest
+	case hasRejected(batch.Results):
+		return http.StatusMultiStatus
+	}
+	return success
+}
@@ -0,0 +1,176 @@
+package reservation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/logger"
+	"github.com/shkuran/go-library-microservices/reservation-service/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+type BookClient interface {
+	GetBook(ctx context.Context, bookID int64) (Book, error)
+	// AdjustAvailableCopies changes the book's available copies by delta,
+	// starting from the book service's current count rather than a cached
+	// one, and returns the new count. It fails with ErrNoCopiesLeft instead
+	// of going below zero.
+	AdjustAvailableCopies(ctx context.Context, bookID, delta int64) (int64, error)
+}
+
+// ErrNoCopiesLeft is returned by AdjustAvailableCopies when the book service
+// has fewer copies than are taken.
+var ErrNoCopiesLeft = errors.New("no copies of the book left")
+
+// BookServiceClient talks to the book service over HTTP. baseURL points at
+// the books collection, e.g. "http://localhost:8081/books/".
+type BookServiceClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewBookServiceClient(baseURL string, timeout time.Duration) *BookServiceClient {
+	return &BookServiceClient{
+		baseURL: baseURL,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: otelhttp.NewTransport(
+				metrics.InstrumentBookService(logger.PropagateRequestID(http.DefaultTransport)),
+				otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+					return "BookService " + req.Method
+				}),
+			),
+		},
+	}
+}
+
+func (c *BookServiceClient) GetBook(ctx context.Context, bookID int64) (Book, error) {
+	url := c.baseURL + fmt.Sprint(bookID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Book{}, err
+	}
+
+	response, err := c.client.Do(req)
+	if err != nil {
+		return Book{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return Book{}, fmt.Errorf("failed to get book. Status code: %d", response.StatusCode)
+	}
+
+	var bookInfo Book
+	err = json.NewDecoder(response.Body).Decode(&bookInfo)
+	if err != nil {
+		return Book{}, err
+	}
+
+	return bookInfo, nil
+}
+
+// AdjustAvailableCopies reads the count and writes it back changed, as the
+// book service only accepts absolute counts.
+func (c *BookServiceClient) AdjustAvailableCopies(ctx context.Context, bookID, delta int64) (int64, error) {
+	book, err := c.GetBook(ctx, bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	availableCopies := book.AvailableCopies + delta
+	if availableCopies < 0 {
+		return 0, fmt.Errorf("book %d has %d copies: %w", bookID, book.AvailableCopies, ErrNoCopiesLeft)
+	}
+	err = c.UpdateAvailableCopies(ctx, bookID, availableCopies)
+	if err != nil {
+		return 0, err
+	}
+	return availableCopies, nil
+}
+
+func (c *BookServiceClient) UpdateAvailableCopies(ctx context.Context, bookID, availableCopies int64) error {
+	updateInfo := struct {
+		BookID          int64 `json:"book_id"`
+		AvailableCopies int64 `json:"available_copies"`
+	}{
+		BookID:          bookID,
+		AvailableCopies: availableCopies,
+	}
+
+	updateInfoJSON, err := json.Marshal(updateInfo)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.baseURL, bytes.NewBuffer(updateInfoJSON))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update availableCopies. Status code: %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// ListBooks fetches the whole catalog from the book service.
+func (c *BookServiceClient) ListBooks(ctx context.Context) ([]Book, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list books. Status code: %d", response.StatusCode)
+	}
+
+	var books []Book
+	err = json.NewDecoder(response.Body).Decode(&books)
+	if err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// Ping checks that the book service answers at all. Any response below 500
+// counts as reachable.
+func (c *BookServiceClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("book service is unavailable. Status code: %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+)
+
+// BookingScheduler starts advance bookings once their window begins: the
+// booking becomes a requested reservation and a copy is taken from the book
+// service. Bookings that find no copy left stay booked and are retried on
+// the next run.
+type BookingScheduler struct {
+	repo      Repository
+	books     BookClient
+	publisher events.Publisher
+	interval  time.Duration
+}
+
+// DefaultBookingCheckInterval is used when no booking check interval is
+// configured.
+const DefaultBookingCheckInterval = time.Minute
+
+// NewBookingScheduler creates a scheduler that checks for due bookings every
+// interval, or every DefaultBookingCheckInterval if interval is not positive.
+func NewBookingScheduler(repo Repository, books BookClient, publisher events.Publisher, interval time.Duration) *BookingScheduler {
+	if interval <= 0 {
+		interval = DefaultBookingCheckInterval
+	}
+	return &BookingScheduler{repo: repo, books: books, publisher: publisher, interval: interval}
+}
+
+// Start blocks and runs a check every interval until ctx is cancelled.
+func (s *BookingScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			err := s.Run(ctx, now)
+			if err != nil {
+				slog.Error("Booking run finished with errors!", "error", err)
+			}
+		}
+	}
+}
+
+// Run starts every booking due as of now. Failures for one booking do not
+// stop the others; they are returned joined together.
+func (s *BookingScheduler) Run(ctx context.Context, now time.Time) error {
+	bookings, err := s.repo.GetDueBookings(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, booking := range bookings {
+		err := s.start(ctx, booking)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reservation %d: %w", booking.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *BookingScheduler) start(ctx context.Context, booking Reservation) error {
+	book, err := s.books.GetBook(ctx, booking.BookId)
+	if err != nil {
+		return err
+	}
+	if book.AvailableCopies < 1 {
+		slog.Warn("No copy available to start booking", "reservation_id", booking.ID, "book_id", book.ID)
+		return nil
+	}
+
+	// The cached count may be stale; the book service has the last word.
+	_, err = s.books.AdjustAvailableCopies(ctx, book.ID, -1)
+	if errors.Is(err, ErrNoCopiesLeft) {
+		slog.Warn("No copy available to start booking", "reservation_id", booking.ID, "book_id", book.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var after Reservation
+	err = s.repo.WithTx(ctx, func(tx Repository) error {
+		err := tx.StartBooking(ctx, booking.ID)
+		if err != nil {
+			return err
+		}
+		after, err = recordEvent(ctx, tx, EventStarted, 0, &booking, booking.ID)
+		return err
+	})
+	if err != nil {
+		_, giveBackErr := s.books.AdjustAvailableCopies(ctx, book.ID, 1)
+		return errors.Join(err, giveBackErr)
+	}
+
+	publish(ctx, s.publisher, EventStarted, after)
+	return nil
+}
@@ -0,0 +1,68 @@
+package reservation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+)
+
+func TestBookingScheduler(t *testing.T) {
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	started := now.Add(-time.Hour)
+	future := now.Add(24 * time.Hour)
+	end := now.Add(7 * 24 * time.Hour)
+
+	repo := NewMockReservationRepo([]Reservation{
+		{ID: 1, BookId: 1, UserId: 1, Status: StatusBooked, StartDate: &started, EndDate: &end},
+		{ID: 2, BookId: 1, UserId: 2, Status: StatusBooked, StartDate: &future, EndDate: &end},
+		{ID: 3, BookId: 2, UserId: 3, Status: StatusBooked, StartDate: &started, EndDate: &end},
+	})
+	books := NewMockBookClient([]Book{{ID: 1, AvailableCopies: 1}, {ID: 2, AvailableCopies: 0}})
+	publisher := events.NewMemoryPublisher()
+	scheduler := NewBookingScheduler(repo, books, publisher, time.Minute)
+
+	err := scheduler.Run(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int64]Status{1: StatusRequested, 2: StatusBooked, 3: StatusBooked}
+	for id, status := range expected {
+		res, err := repo.GetById(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != status {
+			t.Errorf("Reservation %d: expected status %s; got %s", id, status, res.Status)
+		}
+	}
+
+	book, err := books.GetBook(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.AvailableCopies != 0 {
+		t.Errorf("Expected AvailableCopies %d; got %d", 0, book.AvailableCopies)
+	}
+
+	history, err := repo.GetEvents(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Type != EventStarted {
+		t.Errorf("Expected one %s event; got %+v", EventStarted, history)
+	}
+	published := publisher.Events()
+	if len(published) != 1 || published[0].Type != events.ReservationStarted {
+		t.Errorf("Expected one ReservationStarted event; got %+v", published)
+	}
+}
+
+func TestBookingSchedulerDefaultInterval(t *testing.T) {
+	scheduler := NewBookingScheduler(nil, nil, nil, 0)
+	if scheduler.interval != DefaultBookingCheckInterval {
+		t.Errorf("Expected interval %v; got %v", DefaultBookingCheckInterval, scheduler.interval)
+	}
+}
@@ -0,0 +1,64 @@
+package reservation
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/metrics"
+	"github.com/shkuran/go-library-microservices/reservation-service/utils"
+)
+
+type returnRequest struct {
+	Branch string `json:"branch"`
+}
+
+// returnBranch reads the optional branch a copy is handed back at.
+func (h Handler) returnBranch(context *gin.Context) (string, error) {
+	var request returnRequest
+	if context.Request.ContentLength > 0 {
+		err := context.ShouldBindJSON(&request)
+		if err != nil {
+			return "", err
+		}
+	}
+	return request.Branch, nil
+}
+
+// ReceiveReservation lets staff confirm that a copy returned at another
+// branch has arrived back home; see Service.Receive.
+func (h Handler) ReceiveReservation(context *gin.Context) {
+	if !utils.IsStaff(context) {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnauthorized).Inc()
+		utils.HandleStatusForbidden(context, "Only staff can receive copies in transit!", nil)
+		return
+	}
+
+	reservationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse reservationId!", err)
+		return
+	}
+
+	staffId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
+		return
+	}
+
+	ctx, ok := versionedContext(context)
+	if !ok {
+		return
+	}
+
+	received, err := h.service.Receive(ctx, staffId, reservationId)
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
+
+	context.Header("ETag", ETag(received.Version))
+	context.JSON(http.StatusOK, gin.H{"message": "Reservation received!"})
+}
@@ -0,0 +1,234 @@
+package reservation
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var branchPolicy = Policy{
+	CancelWindow:  time.Hour,
+	LoanPeriod:    14 * 24 * time.Hour,
+	DefaultBranch: "main",
+	Branches: map[string]BranchRules{
+		"main": {},
+		"east": {LoanPeriod: 7 * 24 * time.Hour, MaxLoans: 1},
+	},
+}
+
+func TestAddReservationAtBranch(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		reservationsInDB []Reservation
+		requestBody      string
+		expectedCode     int
+		expectedBranch   string
+		expectedErrorMsg string
+	}{
+		// Case 1: Reservations without a branch go to the default branch
+		{
+			testName:         "Default branch",
+			reservationsInDB: []Reservation{},
+			requestBody:      `{"book_id": 1}`,
+			expectedCode:     http.StatusCreated,
+			expectedBranch:   "main",
+		},
+		// Case 2: A patron below the branch limit can reserve there
+		{
+			testName:         "Below limit",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 2, UserId: 1, Branch: "main", Status: StatusActive}},
+			requestBody:      `{"book_id": 1, "branch": "east"}`,
+			expectedCode:     http.StatusCreated,
+			expectedBranch:   "east",
+		},
+		// Case 3: The branch limit counts open reservations at that branch
+		{
+			testName:         "Limit reached",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 2, UserId: 1, Branch: "east", Status: StatusActive}},
+			requestBody:      `{"book_id": 1, "branch": "east"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The borrowing limit of 1 at branch east is reached!",
+		},
+		// Case 4: Advance bookings count against the limit
+		{
+			testName:         "Limit reached by a booking",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 2, UserId: 1, Branch: "east", Status: StatusBooked}},
+			requestBody:      `{"book_id": 1, "branch": "east"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The borrowing limit of 1 at branch east is reached!",
+		},
+		// Case 5: So do copies still travelling back from a return elsewhere
+		{
+			testName:         "Limit reached by a copy in transit",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 2, UserId: 1, Branch: "east", Status: StatusInTransit}},
+			requestBody:      `{"book_id": 1, "branch": "east"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The borrowing limit of 1 at branch east is reached!",
+		},
+		// Case 6: Only configured branches are accepted
+		{
+			testName:         "Unknown branch",
+			reservationsInDB: []Reservation{},
+			requestBody:      `{"book_id": 1, "branch": "west"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "Unknown branch west!",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv([]Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}}, tc.reservationsInDB)
+			handler := NewHandler(NewService(env.ReservationRepo, env.BookClient, env.Publisher, branchPolicy))
+
+			// HTTP request
+			req := httptest.NewRequest(http.MethodPost, "/reservations", strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("UserID", "1")
+			w := httptest.NewRecorder()
+
+			// Gin context
+			gin.SetMode(gin.TestMode)
+			context, _ := gin.CreateTestContext(w)
+			context.Request = req
+
+			// Perform the request
+			handler.AddReservation(context)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			if tc.expectedErrorMsg == "" {
+				id := int64(len(tc.reservationsInDB)) + 1
+				gotRes, err := env.ReservationRepo.GetById(context.Request.Context(), id)
+				if err != nil {
+					t.Fatalf("Could not fetch reservation! error: %v", err)
+				}
+				if gotRes.Branch != tc.expectedBranch {
+					t.Errorf("Expected branch %q; got %q", tc.expectedBranch, gotRes.Branch)
+				}
+			} else {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response["message"] != tc.expectedErrorMsg {
+					t.Errorf("Expected error message '%s'; got '%s'", tc.expectedErrorMsg, response["message"])
+				}
+			}
+		})
+	}
+}
+
+func TestGetReservationsByBranch(t *testing.T) {
+	env := setupTestEnv(nil, []Reservation{
+		{ID: 1, BookId: 1, UserId: 1, Branch: "main"},
+		{ID: 2, BookId: 2, UserId: 2, Branch: "east"},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/reservations?branch=east", nil)
+	req.Header.Set("UserID", "3")
+	req.Header.Set("UserRole", "staff")
+	w := httptest.NewRecorder()
+
+	gin.SetMode(gin.TestMode)
+	context, _ := gin.CreateTestContext(w)
+	context.Request = req
+
+	env.ReservationHandler.GetReservations(context)
+
+	var reservations []Reservation
+	err := json.Unmarshal(w.Body.Bytes(), &reservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 1 || reservations[0].ID != 2 {
+		t.Errorf("Expected only reservation 2; got %+v", reservations)
+	}
+}
+
+func TestReturnAtOtherBranch(t *testing.T) {
+	env := setupTestEnv([]Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+		[]Reservation{{ID: 1, BookId: 1, UserId: 1, Branch: "main", Status: StatusActive}})
+	handler := NewHandler(NewService(env.ReservationRepo, env.BookClient, env.Publisher, branchPolicy))
+	gin.SetMode(gin.TestMode)
+
+	// The patron returns the copy at the east branch
+	req := httptest.NewRequest(http.MethodPost, "/reservations/1", strings.NewReader(`{"branch": "east"}`))
+	req.Header.Set("If-Match", "*")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("UserID", "1")
+	w := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(w)
+	context.Request = req
+	context.AddParam("id", "1")
+
+	handler.CompleteReservation(context)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d; got %d", http.StatusOK, w.Code)
+	}
+	assertBranchState(t, env, StatusInTransit, 0)
+
+	// Only staff receive copies in transit
+	req = httptest.NewRequest(http.MethodPost, "/reservations/1/receive", nil)
+	req.Header.Set("If-Match", "*")
+	req.Header.Set("UserID", "1")
+	w = httptest.NewRecorder()
+	context, _ = gin.CreateTestContext(w)
+	context.Request = req
+	context.AddParam("id", "1")
+
+	handler.ReceiveReservation(context)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d; got %d", http.StatusForbidden, w.Code)
+	}
+
+	// The copy arrives back at main
+	req = httptest.NewRequest(http.MethodPost, "/reservations/1/receive", nil)
+	req.Header.Set("If-Match", "*")
+	req.Header.Set("UserID", "9")
+	req.Header.Set("UserRole", "staff")
+	w = httptest.NewRecorder()
+	context, _ = gin.CreateTestContext(w)
+	context.Request = req
+	context.AddParam("id", "1")
+
+	handler.ReceiveReservation(context)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d; got %d", http.StatusOK, w.Code)
+	}
+	assertBranchState(t, env, StatusReturned, 1)
+}
+
+func assertBranchState(t *testing.T, env TestEnv, status Status, copies int64) {
+	t.Helper()
+
+	res, err := env.ReservationRepo.GetById(gocontext.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != status {
+		t.Errorf("Expected status %s; got %s", status, res.Status)
+	}
+	if res.ReturnBranch != "east" || res.ReturnDate == nil {
+		t.Errorf("Expected return at east to be recorded; got %+v", res)
+	}
+
+	book, err := env.BookClient.GetBook(gocontext.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.AvailableCopies != copies {
+		t.Errorf("Expected AvailableCopies %d; got %d", copies, book.AvailableCopies)
+	}
+}
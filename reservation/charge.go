@@ -0,0 +1,15 @@
+package reservation
+
+import "time"
+
+// Charge is money a patron owes for a reservation, e.g. the replacement
+// cost of a lost or damaged copy.
+type Charge struct {
+	ID            int64     `json:"id" db:"id"`
+	ReservationId int64     `json:"reservation_id" db:"reservation_id"`
+	UserId        int64     `json:"user_id" db:"user_id"`
+	AmountCents   int64     `json:"amount_cents" db:"amount_cents"`
+	Reason        Status    `json:"reason" db:"reason"`
+	Note          string    `json:"note" db:"note"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,30 @@
+package reservation
+
+import "time"
+
+// CopyCondition describes the physical state of a copy.
+type CopyCondition string
+
+const (
+	CopyGood    CopyCondition = "good"
+	CopyWorn    CopyCondition = "worn"
+	CopyDamaged CopyCondition = "damaged"
+	CopyLost    CopyCondition = "lost"
+)
+
+// IsLendable reports whether a copy in this condition can go out on loan.
+func (c CopyCondition) IsLendable() bool {
+	return c == CopyGood || c == CopyWorn
+}
+
+// Copy is one physical item of a book, identified by its barcode.
+// OnLoan is derived from the reservations pointing at the copy.
+type Copy struct {
+	ID        int64         `json:"id" db:"id"`
+	BookId    int64         `json:"book_id" db:"book_id"`
+	Barcode   string        `json:"barcode" db:"barcode"`
+	Branch    string        `json:"branch" db:"branch"`
+	Condition CopyCondition `json:"condition" db:"condition"`
+	OnLoan    bool          `json:"on_loan"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,80 @@
+package reservation
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/utils"
+)
+
+type addCopyRequest struct {
+	Barcode   string        `json:"barcode" binding:"required"`
+	Branch    string        `json:"branch"`
+	Condition CopyCondition `json:"condition" binding:"omitempty,oneof=good worn damaged lost"`
+}
+
+type pickUpRequest struct {
+	Barcode string `json:"barcode"`
+}
+
+// AddCopy lets staff register a physical copy of a book.
+func (h Handler) AddCopy(context *gin.Context) {
+	if !utils.IsStaff(context) {
+		utils.HandleStatusForbidden(context, "Only staff can add copies!", nil)
+		return
+	}
+
+	bookId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse bookId!", err)
+		return
+	}
+
+	var request addCopyRequest
+	err = context.ShouldBindJSON(&request)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse request data!", err)
+		return
+	}
+	copyId, err := h.service.AddCopy(context.Request.Context(), Copy{
+		BookId:    bookId,
+		Barcode:   request.Barcode,
+		Branch:    request.Branch,
+		Condition: request.Condition,
+	})
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Copy added!", "id": copyId})
+}
+
+// GetCopies lists the copies of a book and whether each is out on loan.
+func (h Handler) GetCopies(context *gin.Context) {
+	bookId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse bookId!", err)
+		return
+	}
+
+	copies, err := h.service.Copies(context.Request.Context(), bookId)
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, copies)
+}
+
+// GetCopy looks a copy up by its barcode, e.g. after a scan at the desk.
+func (h Handler) GetCopy(context *gin.Context) {
+	c, err := h.service.CopyByBarcode(context.Request.Context(), context.Param("barcode"))
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, c)
+}
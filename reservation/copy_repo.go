@@ -0,0 +1,122 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrCopyNotFound is returned when no copy has the requested barcode.
+var ErrCopyNotFound = errors.New("copy not found")
+
+const copyColumns = `c.id, c.book_id, c.barcode, c.branch, c.condition, c.created_at,
+	EXISTS (SELECT 1 FROM reservations r WHERE r.copy_id = c.id AND r.status IN ('active', 'overdue', 'in_transit'))`
+
+func scanCopy(row rowScanner) (Copy, error) {
+	var c Copy
+	err := row.Scan(&c.ID, &c.BookId, &c.Barcode, &c.Branch, &c.Condition, &c.CreatedAt, &c.OnLoan)
+	return c, err
+}
+
+func (r *Repo) AddCopy(ctx context.Context, c Copy) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.AddCopy")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("book.id", c.BookId))
+
+	query := `
+	INSERT INTO copies (book_id, barcode, branch, condition, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id
+	`
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, c.BookId, c.Barcode, c.Branch, c.Condition, time.Now()).Scan(&id)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	return id, nil
+}
+
+func (r *Repo) GetCopies(ctx context.Context, bookId int64) ([]Copy, error) {
+	ctx, span := tracer.Start(ctx, "Repo.GetCopies")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("book.id", bookId))
+
+	query := "SELECT " + copyColumns + " FROM copies c WHERE c.book_id = $1 ORDER BY c.id"
+	rows, err := r.db.QueryContext(ctx, query, bookId)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	var copies []Copy
+	for rows.Next() {
+		c, err := scanCopy(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		copies = append(copies, c)
+	}
+
+	return copies, rows.Err()
+}
+
+func (r *Repo) GetCopyByBarcode(ctx context.Context, barcode string) (Copy, error) {
+	ctx, span := tracer.Start(ctx, "Repo.GetCopyByBarcode")
+	defer span.End()
+
+	query := "SELECT " + copyColumns + " FROM copies c WHERE c.barcode = $1"
+	c, err := scanCopy(r.db.QueryRowContext(ctx, query, barcode))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Copy{}, tracing.Fail(span, fmt.Errorf("%w: %s", ErrCopyNotFound, barcode))
+	}
+	if err != nil {
+		return Copy{}, tracing.Fail(span, err)
+	}
+
+	return c, nil
+}
+
+func (r *Repo) SetCopyCondition(ctx context.Context, id int64, condition CopyCondition) error {
+	ctx, span := tracer.Start(ctx, "Repo.SetCopyCondition")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("copy.id", id), attribute.String("copy.condition", string(condition)))
+
+	query := "UPDATE copies SET condition = $1 WHERE id = $2"
+	_, err := r.db.ExecContext(ctx, query, condition, id)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	return nil
+}
+
+// CountAvailableCopies returns how many lendable copies of the book are not
+// claimed by an open reservation. tracked is false when the book has no
+// copies registered yet, in which case callers fall back to the book
+// service's count.
+func (r *Repo) CountAvailableCopies(ctx context.Context, bookId int64) (available int64, tracked bool, err error) {
+	ctx, span := tracer.Start(ctx, "Repo.CountAvailableCopies")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("book.id", bookId))
+
+	query := `
+	SELECT
+		(SELECT COUNT(*) FROM copies WHERE book_id = $1),
+		(SELECT COUNT(*) FROM copies WHERE book_id = $1 AND condition IN ($2, $3)),
+		(SELECT COUNT(*) FROM reservations WHERE book_id = $1 AND status IN ($4, $5, $6, $7))
+	`
+	var total, lendable, held int64
+	err = r.db.QueryRowContext(ctx, query, bookId, CopyGood, CopyWorn, StatusRequested, StatusActive, StatusOverdue, StatusInTransit).
+		Scan(&total, &lendable, &held)
+	if err != nil {
+		return 0, false, tracing.Fail(span, err)
+	}
+
+	return max(lendable-held, 0), total > 0, nil
+}
@@ -0,0 +1,78 @@
+package reservation
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Kinds of rule violations reported by the Service. Transports map them to
+// their own status codes; the Error message is meant for the client.
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrNotOwner       = errors.New("reservation belongs to another user")
+	ErrUnavailable    = errors.New("book not available")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrConflict       = errors.New("conflicting change")
+	ErrStaleVersion   = errors.New("reservation changed since it was read")
+)
+
+// Error is returned by the Service. Kind is one of the Err* values above
+// (nil for internal failures) and Err is the underlying cause, if any.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+func reject(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func internal(message string, err error) *Error {
+	return &Error{Message: message, Err: err}
+}
+
+// lookupFailed reports a failed reservation lookup, telling a missing
+// reservation apart from a broken repository.
+func lookupFailed(err error) *Error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Message: "Reservation not found!", Err: err}
+	}
+	return internal("Could not fetch reservation!", err)
+}
+
+func staleVersion() *Error {
+	return reject(ErrStaleVersion, "The reservation was changed by another request!")
+}
+
+// saveFailed reports a failed insert, telling a second open reservation of
+// the same book apart from a broken repository.
+func saveFailed(err error) *Error {
+	if errors.Is(err, ErrAlreadyReserved) {
+		return &Error{Kind: ErrConflict, Message: "You already have an open reservation for this book!", Err: err}
+	}
+	return internal("Could not add reservation!", err)
+}
+
+// returnFailed reports a failed UpdateReturnDate. affected is 0 when another
+// request returned the reservation first.
+func returnFailed(affected int64, err error) *Error {
+	if err != nil {
+		return internal("Could not copmlete reservation!", err)
+	}
+	if affected == 0 {
+		return reject(ErrConflict, "The reservation is copleted already!")
+	}
+	return nil
+}
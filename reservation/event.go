@@ -0,0 +1,33 @@
+package reservation
+
+import "time"
+
+type EventType string
+
+const (
+	EventCreated    EventType = "created"
+	EventStarted    EventType = "booking_started"
+	EventPickedUp   EventType = "picked_up"
+	EventCompleted  EventType = "completed"
+	EventInTransit  EventType = "in_transit"
+	EventReceived   EventType = "received"
+	EventRenewed    EventType = "renewed"
+	EventCancelled  EventType = "cancelled"
+	EventOverdue    EventType = "overdue"
+	EventOverridden EventType = "overridden"
+	EventImported   EventType = "imported"
+)
+
+// Event is one entry of the append-only reservation history. Before and After
+// are snapshots of the reservation around the change; Before is nil for
+// EventCreated and for an EventImported that did not replace a reservation.
+type Event struct {
+	ID            int64        `json:"id" db:"id"`
+	ReservationId int64        `json:"reservation_id" db:"reservation_id"`
+	Type          EventType    `json:"type" db:"event_type"`
+	ActorId       int64        `json:"actor_id" db:"actor_id"`
+	SourceIP      string       `json:"source_ip" db:"source_ip"`
+	Before        *Reservation `json:"before" db:"before_snapshot"`
+	After         *Reservation `json:"after" db:"after_snapshot"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+}
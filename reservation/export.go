@@ -0,0 +1,284 @@
+package reservation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Format is a file format for exporting and importing reservations.
+type Format string
+
+const (
+	// FormatCSV has a header row naming the columns after the JSON fields.
+	FormatCSV Format = "csv"
+	// FormatNDJSON has one reservation per line, encoded like the API does.
+	FormatNDJSON Format = "ndjson"
+)
+
+// ContentType is the media type of files in the format.
+func (f Format) ContentType() string {
+	if f == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// ErrMalformedRecord is returned for a record that cannot be decoded. The
+// records after it can still be read.
+var ErrMalformedRecord = errors.New("malformed record")
+
+// csvColumns are the columns of a CSV export.
+var csvColumns = []string{"id", "book_id", "user_id", "checkout_date", "return_date", "due_date", "picked_up_at", "start_date", "end_date",
+	"copy_id", "branch", "return_branch", "status", "status_changed_at", "status_note", "version"}
+
+// requiredCSVColumns must be present in an imported CSV file.
+var requiredCSVColumns = []string{"id", "book_id", "user_id", "checkout_date", "status"}
+
+// recordWriter encodes reservations one at a time.
+type recordWriter interface {
+	Write(res Reservation) error
+	// Flush writes out what Write buffered.
+	Flush() error
+}
+
+func newRecordWriter(w io.Writer, format Format) (recordWriter, error) {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		err := writer.Write(csvColumns)
+		if err != nil {
+			return nil, err
+		}
+		return csvRecordWriter{writer}, nil
+	case FormatNDJSON:
+		return ndjsonRecordWriter{json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type csvRecordWriter struct {
+	writer *csv.Writer
+}
+
+func (w csvRecordWriter) Write(res Reservation) error {
+	optionalTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339Nano)
+	}
+	copyId := ""
+	if res.CopyId != nil {
+		copyId = strconv.FormatInt(*res.CopyId, 10)
+	}
+
+	return w.writer.Write([]string{
+		strconv.FormatInt(res.ID, 10),
+		strconv.FormatInt(res.BookId, 10),
+		strconv.FormatInt(res.UserId, 10),
+		res.CheckoutDate.Format(time.RFC3339Nano),
+		optionalTime(res.ReturnDate),
+		optionalTime(res.DueDate),
+		optionalTime(res.PickedUpAt),
+		optionalTime(res.StartDate),
+		optionalTime(res.EndDate),
+		copyId,
+		res.Branch,
+		res.ReturnBranch,
+		string(res.Status),
+		res.StatusChangedAt.Format(time.RFC3339Nano),
+		res.StatusNote,
+		strconv.FormatInt(res.Version, 10),
+	})
+}
+
+func (w csvRecordWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonRecordWriter struct {
+	encoder *json.Encoder
+}
+
+func (w ndjsonRecordWriter) Write(res Reservation) error {
+	return w.encoder.Encode(res)
+}
+
+// Flush does nothing: Write does not buffer.
+func (w ndjsonRecordWriter) Flush() error {
+	return nil
+}
+
+// recordReader decodes reservations one at a time. Read returns the
+// reservation with the line it starts on, an error wrapping
+// ErrMalformedRecord for a record that cannot be decoded, and io.EOF after
+// the last record.
+type recordReader interface {
+	Read() (Reservation, int, error)
+}
+
+func newRecordReader(r io.Reader, format Format) (recordReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVRecordReader(r)
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxNDJSONLine)
+		return &ndjsonRecordReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type csvRecordReader struct {
+	reader *csv.Reader
+	// columns maps the column names to their index in a record.
+	columns map[string]int
+}
+
+// newCSVRecordReader reads the header row. The columns may come in any
+// order, and all but requiredCSVColumns may be left out.
+func newCSVRecordReader(r io.Reader) (*csvRecordReader, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range requiredCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	return &csvRecordReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvRecordReader) Read() (Reservation, int, error) {
+	record, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Reservation{}, parseErr.StartLine, fmt.Errorf("%w: %v", ErrMalformedRecord, parseErr.Err)
+	}
+	if err != nil {
+		return Reservation{}, 0, err
+	}
+	line, _ := r.reader.FieldPos(0)
+
+	res, err := r.decode(record)
+	if err != nil {
+		return Reservation{}, line, fmt.Errorf("%w: %v", ErrMalformedRecord, err)
+	}
+	return res, line, nil
+}
+
+func (r *csvRecordReader) decode(record []string) (Reservation, error) {
+	var res Reservation
+	var errs []error
+	field := func(name string) string {
+		i, ok := r.columns[name]
+		if !ok {
+			return ""
+		}
+		return record[i]
+	}
+	integer := func(name string) int64 {
+		value := field(name)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid number %q", name, value))
+		}
+		return n
+	}
+	timestamp := func(name string) *time.Time {
+		value := field(name)
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid time %q", name, value))
+			return nil
+		}
+		return &t
+	}
+
+	res.ID = integer("id")
+	res.BookId = integer("book_id")
+	res.UserId = integer("user_id")
+	if checkoutDate := timestamp("checkout_date"); checkoutDate != nil {
+		res.CheckoutDate = *checkoutDate
+	}
+	res.ReturnDate = timestamp("return_date")
+	res.DueDate = timestamp("due_date")
+	res.PickedUpAt = timestamp("picked_up_at")
+	res.StartDate = timestamp("start_date")
+	res.EndDate = timestamp("end_date")
+	if field("copy_id") != "" {
+		copyId := integer("copy_id")
+		res.CopyId = &copyId
+	}
+	res.Branch = field("branch")
+	res.ReturnBranch = field("return_branch")
+	res.Status = Status(field("status"))
+	if statusChangedAt := timestamp("status_changed_at"); statusChangedAt != nil {
+		res.StatusChangedAt = *statusChangedAt
+	}
+	res.StatusNote = field("status_note")
+	res.Version = integer("version")
+
+	return res, errors.Join(errs...)
+}
+
+// maxNDJSONLine is the longest line an NDJSON import may have.
+const maxNDJSONLine = 1 << 20
+
+type ndjsonRecordReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// Read skips blank lines and rejects unknown fields.
+func (r *ndjsonRecordReader) Read() (Reservation, int, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var res Reservation
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&res)
+		if err != nil {
+			return Reservation{}, r.line, fmt.Errorf("%w: %v", ErrMalformedRecord, err)
+		}
+		return res, r.line, nil
+	}
+
+	err := r.scanner.Err()
+	if err != nil {
+		return Reservation{}, 0, err
+	}
+	return Reservation{}, 0, io.EOF
+}
@@ -0,0 +1,51 @@
+package reservation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter narrows down the reservations returned by Repository.GetAll.
+// Zero fields do not filter.
+type Filter struct {
+	Branch string
+	UserId int64
+	BookId int64
+	Status Status
+}
+
+// Matches reports whether res passes the filter.
+func (f Filter) Matches(res Reservation) bool {
+	return (f.Branch == "" || res.Branch == f.Branch) &&
+		(f.UserId == 0 || res.UserId == f.UserId) &&
+		(f.BookId == 0 || res.BookId == f.BookId) &&
+		(f.Status == "" || res.Status == f.Status)
+}
+
+// where renders the filter as a SQL condition with numbered placeholders.
+func (f Filter) where() (string, []any) {
+	var conditions []string
+	var args []any
+	add := func(column string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if f.Branch != "" {
+		add("branch", f.Branch)
+	}
+	if f.UserId != 0 {
+		add("user_id", f.UserId)
+	}
+	if f.BookId != 0 {
+		add("book_id", f.BookId)
+	}
+	if f.Status != "" {
+		add("status", f.Status)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
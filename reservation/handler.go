@@ -1,185 +1,433 @@
 package reservation
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/logger"
+	"github.com/shkuran/go-library-microservices/reservation-service/metrics"
 	"github.com/shkuran/go-library-microservices/reservation-service/utils"
 )
 
+// Handler is the REST transport over Service. It parses requests, checks the
+// staff role set by the gateway and renders the Service's results.
 type Handler struct {
-	repo Repository
+	service *Service
 }
 
-func NewHandler(repo Repository) Handler {
-	return Handler{repo: repo}
+func NewHandler(service *Service) Handler {
+	return Handler{service: service}
 }
 
+// GetReservations lists reservations, optionally filtered by the branch,
+// user_id, book_id and status query parameters.
 func (h Handler) GetReservations(context *gin.Context) {
-	reservations, err := h.repo.GetAll()
+	filter, err := parseFilter(context)
 	if err != nil {
-		utils.HandleInternalServerError(context, "Could not fetch reservations!", err)
+		utils.HandleBadRequest(context, "Could not parse filter!", err)
+		return
+	}
+
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
+		return
+	}
+
+	reservations, err := h.service.List(context.Request.Context(), userId, utils.IsStaff(context), filter)
+	if err != nil {
+		handleServiceError(context, err)
 		return
 	}
 
 	context.JSON(http.StatusOK, reservations)
 }
 
+// exportFlushRows is how many exported reservations are sent at a time.
+const exportFlushRows = 500
+
+// ExportReservations streams the reservations to staff as CSV or, with
+// format=ndjson, as NDJSON. It takes the filters of GetReservations.
+func (h Handler) ExportReservations(context *gin.Context) {
+	if !utils.IsStaff(context) {
+		utils.HandleStatusForbidden(context, "Only staff can export reservations!", nil)
+		return
+	}
+
+	filter, err := parseFilter(context)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse filter!", err)
+		return
+	}
+
+	format := Format(context.DefaultQuery("format", string(FormatCSV)))
+	writer, err := newRecordWriter(context.Writer, format)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse export format!", err)
+		return
+	}
+
+	context.Header("Content-Type", format.ContentType())
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="reservations.%s"`, format))
+	context.Status(http.StatusOK)
+
+	rows := 0
+	err = h.service.Export(context.Request.Context(), filter, func(res Reservation) error {
+		err := writer.Write(res)
+		if err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			err = writer.Flush()
+			context.Writer.Flush()
+		}
+		return err
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		// The status is sent already, so the client only sees the export
+		// end early.
+		logger.FromContext(context.Request.Context()).Error("Could not export reservations!", "error", err, "rows", rows)
+		return
+	}
+	context.Writer.Flush()
+}
+
+// GetReservation returns one reservation to its owner or to staff.
+func (h Handler) GetReservation(context *gin.Context) {
+	reservationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse reservationId!", err)
+		return
+	}
+
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
+		return
+	}
+
+	reservation, err := h.service.Get(context.Request.Context(), userId, utils.IsStaff(context), reservationId)
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
+
+	context.Header("ETag", ETag(reservation.Version))
+	context.JSON(http.StatusOK, reservation)
+}
+
 func (h Handler) AddReservation(context *gin.Context) {
 	var reservation Reservation
 	err := context.ShouldBindJSON(&reservation)
 	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
 		utils.HandleBadRequest(context, "Could not parse request data!", err)
 		return
 	}
 
-	url := "http://localhost:8081/books/"
-	book, err := h.getBookById(url, reservation.BookId)
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64) // int64(1) //context.GetInt64("userId")
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
+		return
+	}
+
+	created, err := h.service.Reserve(serviceContext(context), userId, reservation.BookId, ReserveOptions{
+		Branch:    reservation.Branch,
+		StartDate: reservation.StartDate,
+		EndDate:   reservation.EndDate,
+	})
 	if err != nil {
-		utils.HandleInternalServerError(context, "Could not fetch book!", err)
+		handleServiceError(context, err)
 		return
 	}
 
-	numberOfBookCopies := book.AvailableCopies
-	if numberOfBookCopies < 1 {
-		utils.HandleBadRequest(context, "The book is not available!", nil)
+	if created.Status == StatusBooked {
+		utils.HandleStatusCreated(context, "Booking added!")
+		return
+	}
+	utils.HandleStatusCreated(context, "Reservation added!")
+}
+
+func (h Handler) CompleteReservation(context *gin.Context) {
+	reservationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse reservationId!", err)
 		return
 	}
 
 	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64) // int64(1) //context.GetInt64("userId")
 	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
 		utils.HandleBadRequest(context, "Could not parse UserId!", err)
 		return
 	}
-	reservation.UserId = userId
 
-	err = h.repo.Save(reservation)
+	returnBranch, err := h.returnBranch(context)
 	if err != nil {
-		utils.HandleInternalServerError(context, "Could not add reservation!", err)
+		utils.HandleBadRequest(context, "Could not parse request data!", err)
 		return
 	}
 
-	err = h.updateAvailableCopies(url, book.ID, book.AvailableCopies-1)
-    if err != nil {
-        utils.HandleInternalServerError(context, "Failed to update the number of book copies in book service", err)
-        return
-    }
+	ctx, ok := versionedContext(context)
+	if !ok {
+		return
+	}
 
-	// err = h.bookRepo.UpdateAvailableCopies(b.ID, b.AvailableCopies-1)
-	// if err != nil {
-	// 	utils.HandleInternalServerError(context, "Could not update the number of book copies!", err)
-	// 	return
-	// }
+	completed, err := h.service.Return(ctx, userId, reservationId, returnBranch)
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
 
-	utils.HandleStatusCreated(context, "Reservation added!")
+	context.Header("ETag", ETag(completed.Version))
+	if completed.Status == StatusInTransit {
+		context.JSON(http.StatusOK, gin.H{"message": "Reservation copmleted! The copy is in transit."})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": "Reservation copmleted!"})
 }
 
-func (h Handler) CompleteReservation(context *gin.Context) {
+// RenewReservation extends the due date of the patron's active loan.
+func (h Handler) RenewReservation(context *gin.Context) {
 	reservationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
 		utils.HandleBadRequest(context, "Could not parse reservationId!", err)
 		return
 	}
 
-	reservation, err := h.repo.GetById(reservationId)
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
 	if err != nil {
-		utils.HandleInternalServerError(context, "Could not fetch reservation!", err)
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
 		return
 	}
 
-	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64) // int64(1) //context.GetInt64("userId")
+	ctx, ok := versionedContext(context)
+	if !ok {
+		return
+	}
+
+	renewed, err := h.service.Renew(ctx, userId, reservationId)
+	if err != nil {
+		handleServiceError(context, err)
+		return
+	}
+
+	context.Header("ETag", ETag(renewed.Version))
+	context.JSON(http.StatusOK, gin.H{"message": "Reservation renewed!", "due_date": renewed.DueDate})
+}
+
+func (h Handler) PickUpReservation(context *gin.Context) {
+	reservationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse reservationId!", err)
+		return
+	}
+
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
 		utils.HandleBadRequest(context, "Could not parse UserId!", err)
 		return
 	}
-	if reservation.UserId != userId {
-		utils.HandleStatusUnauthorized(context, "Not access to copmlete reservation!", nil)
+
+	var request pickUpRequest
+	if context.Request.ContentLength > 0 {
+		err = context.ShouldBindJSON(&request)
+		if err != nil {
+			utils.HandleBadRequest(context, "Could not parse request data!", err)
+			return
+		}
+	}
+
+	ctx, ok := versionedContext(context)
+	if !ok {
+		return
+	}
+
+	pickedUp, err := h.service.PickUp(ctx, userId, reservationId, request.Barcode)
+	if err != nil {
+		handleServiceError(context, err)
 		return
 	}
 
-	if reservation.ReturnDate != nil {
-		utils.HandleBadRequest(context, "The reservation is copleted already!", nil)
+	context.Header("ETag", ETag(pickedUp.Version))
+	context.JSON(http.StatusOK, gin.H{"message": "Reservation picked up!"})
+}
+
+func (h Handler) CancelReservation(context *gin.Context) {
+	reservationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse reservationId!", err)
 		return
 	}
 
-	err = h.repo.UpdateReturnDate(reservationId)
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
 	if err != nil {
-		utils.HandleInternalServerError(context, "Could not copmlete reservation!", err)
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
 		return
 	}
 
-	url := "http://localhost:8081/books/"
-	book, err := h.getBookById(url, reservation.BookId)
+	ctx, ok := versionedContext(context)
+	if !ok {
+		return
+	}
+
+	cancelled, err := h.service.Cancel(ctx, userId, reservationId)
 	if err != nil {
-		utils.HandleInternalServerError(context, "Could not fetch book!", err)
+		handleServiceError(context, err)
 		return
 	}
 
-	err = h.updateAvailableCopies(url, book.ID, book.AvailableCopies+1)
-    if err != nil {
-        utils.HandleInternalServerError(context, "Failed to update the number of book copies in book service", err)
-        return
-    }
+	context.Header("ETag", ETag(cancelled.Version))
+	context.JSON(http.StatusOK, gin.H{"message": "Reservation cancelled!"})
+}
 
-	context.JSON(http.StatusOK, gin.H{"message": "Reservation copmleted!"})
+type markLostRequest struct {
+	Condition            Status `json:"condition" binding:"required,oneof=lost damaged"`
+	Note                 string `json:"note"`
+	ReplacementCostCents int64  `json:"replacement_cost_cents" binding:"min=0"`
 }
 
-func (h Handler) getBookById(bookServiceURL string, bookID int64) (Book, error) {
-	url := bookServiceURL + fmt.Sprint(bookID)
+// MarkReservationLost lets staff close an active or overdue loan as lost or
+// damaged; see Service.MarkLost.
+func (h Handler) MarkReservationLost(context *gin.Context) {
+	if !utils.IsStaff(context) {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnauthorized).Inc()
+		utils.HandleStatusForbidden(context, "Only staff can mark reservations as lost!", nil)
+		return
+	}
+
+	reservationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse reservationId!", err)
+		return
+	}
+
+	staffId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
+	if err != nil {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
+		return
+	}
 
-	response, err := http.Get(url)
+	var request markLostRequest
+	err = context.ShouldBindJSON(&request)
 	if err != nil {
-		return Book{}, err
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		utils.HandleBadRequest(context, "Could not parse request data!", err)
+		return
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != http.StatusOK {
-		return Book{}, fmt.Errorf("failed to get book. Status code: %d", response.StatusCode)
+	ctx, ok := versionedContext(context)
+	if !ok {
+		return
 	}
 
-	var bookInfo Book
-	err = json.NewDecoder(response.Body).Decode(&bookInfo)
+	closed, chargeId, err := h.service.MarkLost(ctx, staffId, reservationId, LossReport{
+		Condition:            request.Condition,
+		Note:                 request.Note,
+		ReplacementCostCents: request.ReplacementCostCents,
+	})
 	if err != nil {
-		return Book{}, err
+		handleServiceError(context, err)
+		return
 	}
 
-	return bookInfo, nil
+	context.Header("ETag", ETag(closed.Version))
+	response := gin.H{"message": fmt.Sprintf("Reservation marked as %s!", request.Condition)}
+	if chargeId != 0 {
+		response["charge_id"] = chargeId
+	}
+	context.JSON(http.StatusOK, response)
 }
 
-func (h Handler) updateAvailableCopies(bookServiceURL string, bookID, availableCopies int64) error {
-	updateInfo := struct {
-		BookID          int64 `json:"book_id"`
-		AvailableCopies int64 `json:"available_copies"`
-	}{
-		BookID:          bookID,
-		AvailableCopies: availableCopies,
+func (h Handler) GetReservationHistory(context *gin.Context) {
+	reservationId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse reservationId!", err)
+		return
 	}
 
-	updateInfoJSON, err := json.Marshal(updateInfo)
+	userId, err := strconv.ParseInt(context.Request.Header.Get("UserID"), 10, 64)
 	if err != nil {
-		return err
+		utils.HandleBadRequest(context, "Could not parse UserId!", err)
+		return
 	}
 
-	req, err := http.NewRequest(http.MethodPut, bookServiceURL, bytes.NewBuffer(updateInfoJSON))
+	events, err := h.service.History(context.Request.Context(), userId, utils.IsStaff(context), reservationId)
 	if err != nil {
-		return err
+		handleServiceError(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, events)
+}
+
+// serviceContext carries the caller's address along with the request context.
+func serviceContext(context *gin.Context) context.Context {
+	return WithSourceIP(context.Request.Context(), context.ClientIP())
+}
+
+// handleServiceError renders an error returned by the Service. Rule
+// violations keep their message; internal failures are logged.
+func handleServiceError(context *gin.Context, err error) {
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) {
+		utils.HandleInternalServerError(context, "Internal error!", err)
+		return
 	}
-	req.Header.Set("Content-Type", "application/json")
-	
-	client := http.Client{}
-    response, err := client.Do(req)
-    if err != nil {
-        return err
-    }
-    defer response.Body.Close()
 
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to update availableCopies. Status code: %d", response.StatusCode)
+	switch {
+	case errors.Is(err, ErrNotOwner):
+		utils.HandleStatusUnauthorized(context, serviceErr.Message, nil)
+	case errors.Is(err, ErrNotFound):
+		utils.HandleNotFound(context, serviceErr.Message, nil)
+	case errors.Is(err, ErrConflict):
+		utils.HandleConflict(context, serviceErr.Message, nil)
+	case errors.Is(err, ErrStaleVersion):
+		utils.HandlePreconditionFailed(context, serviceErr.Message, nil)
+	case errors.Is(err, ErrUnavailable), errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrInvalidTransition):
+		utils.HandleBadRequest(context, serviceErr.Message, nil)
+	default:
+		utils.HandleInternalServerError(context, serviceErr.Message, serviceErr.Err)
 	}
+}
 
-	return nil
+// parseFilter reads the GET /reservations query parameters.
+func parseFilter(context *gin.Context) (Filter, error) {
+	filter := Filter{
+		Branch: context.Query("branch"),
+		Status: Status(context.Query("status")),
+	}
+
+	var err error
+	if value := context.Query("user_id"); value != "" {
+		filter.UserId, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Filter{}, fmt.Errorf("user_id: %w", err)
+		}
+	}
+	if value := context.Query("book_id"); value != "" {
+		filter.BookId, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Filter{}, fmt.Errorf("book_id: %w", err)
+		}
+	}
+	return filter, nil
 }
@@ -1,7 +1,9 @@
 package reservation
 
 import (
+	gocontext "context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -10,20 +12,27 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
 )
 
-func setupTestEnv(reservationsInDB []Reservation) TestEnv {
+func setupTestEnv(booksInDB []Book, reservationsInDB []Reservation) TestEnv {
+	bookClient := NewMockBookClient(booksInDB)
 	resRepo := NewMockReservationRepo(reservationsInDB)
-	resHandler := NewHandler(resRepo)
+	publisher := events.NewMemoryPublisher()
+	resHandler := NewHandler(NewService(resRepo, bookClient, publisher, Policy{CancelWindow: time.Hour, LoanPeriod: 14 * 24 * time.Hour}))
 
 	return TestEnv{
+		BookClient:         bookClient,
 		ReservationRepo:    resRepo,
+		Publisher:          publisher,
 		ReservationHandler: resHandler,
 	}
 }
 
 type TestEnv struct {
+	BookClient         *MockBookClient
 	ReservationRepo    *MockReservationRepo
+	Publisher          *events.MemoryPublisher
 	ReservationHandler Handler
 }
 
@@ -31,26 +40,63 @@ func TestGetReservations(t *testing.T) {
 
 	testCases := []struct {
 		testName             string
-		// booksInDB            []book.Book
+		booksInDB            []Book
 		reservationsInDB     []Reservation
+		userId               string
+		staff                bool
+		query                string
 		expectedCode         int
 		expectedReservations []Reservation
 		expectedErrorMsg     string
 	}{
-		// Case 1: GetReservation returns []Reservation
+		// Case 1: Staff get every patron's reservations
 		{
 			testName:             "Return reservations",
-			// booksInDB:            []book.Book{},
+			booksInDB:            []Book{},
 			reservationsInDB:     []Reservation{{ID: 1, BookId: 1, UserId: 1}, {ID: 2, BookId: 2, UserId: 2}},
+			userId:               "3",
+			staff:                true,
 			expectedCode:         http.StatusOK,
 			expectedReservations: []Reservation{{ID: 1, BookId: 1, UserId: 1}, {ID: 2, BookId: 2, UserId: 2}},
 			expectedErrorMsg:     "",
 		},
-		// Case 2: GetReservation returns an error
+		// Case 2: Patrons get only their own reservations
+		{
+			testName:             "Return the patron's reservations",
+			booksInDB:            []Book{},
+			reservationsInDB:     []Reservation{{ID: 1, BookId: 1, UserId: 1}, {ID: 2, BookId: 2, UserId: 2}},
+			userId:               "2",
+			expectedCode:         http.StatusOK,
+			expectedReservations: []Reservation{{ID: 2, BookId: 2, UserId: 2}},
+			expectedErrorMsg:     "",
+		},
+		// Case 3: Patrons cannot list other patrons' reservations
+		{
+			testName:             "Refuse other patrons' reservations",
+			booksInDB:            []Book{},
+			reservationsInDB:     []Reservation{{ID: 1, BookId: 1, UserId: 1}, {ID: 2, BookId: 2, UserId: 2}},
+			userId:               "2",
+			query:                "?user_id=1",
+			expectedCode:         http.StatusUnauthorized,
+			expectedReservations: nil,
+			expectedErrorMsg:     "Not access to reservations of other users!",
+		},
+		// Case 4: Missing UserID header
+		{
+			testName:             "Missing user",
+			booksInDB:            []Book{},
+			reservationsInDB:     []Reservation{{ID: 1, BookId: 1, UserId: 1}},
+			expectedCode:         http.StatusBadRequest,
+			expectedReservations: nil,
+			expectedErrorMsg:     "Could not parse UserId!",
+		},
+		// Case 5: GetReservation returns an error
 		{
 			testName:             "Return an error",
-			// booksInDB:            []book.Book{},
+			booksInDB:            []Book{},
 			reservationsInDB:     []Reservation{},
+			userId:               "3",
+			staff:                true,
 			expectedCode:         http.StatusInternalServerError,
 			expectedReservations: nil,
 			expectedErrorMsg:     "Could not fetch reservations!",
@@ -60,17 +106,21 @@ func TestGetReservations(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.testName, func(t *testing.T) {
 			// Set up the test environment
-			env := setupTestEnv(tc.reservationsInDB)
+			env := setupTestEnv(tc.booksInDB, tc.reservationsInDB)
 
 			router := gin.Default()
 
 			router.GET("/reservations", env.ReservationHandler.GetReservations)
 
 			// Perform a test request
-			req, err := http.NewRequest("GET", "/reservations", nil)
+			req, err := http.NewRequest("GET", "/reservations"+tc.query, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
+			req.Header.Set("UserID", tc.userId)
+			if tc.staff {
+				req.Header.Set("UserRole", "staff")
+			}
 
 			// Create a response recorder to record the response
 			w := httptest.NewRecorder()
@@ -108,10 +158,102 @@ func TestGetReservations(t *testing.T) {
 
 }
 
+func TestExportReservations(t *testing.T) {
+	testCases := []struct {
+		testName        string
+		query           string
+		staff           bool
+		expectedCode    int
+		expectedType    string
+		expectedBody    string
+		expectedMessage string
+	}{
+		{
+			testName:     "Export as CSV",
+			query:        "?branch=main",
+			staff:        true,
+			expectedCode: http.StatusOK,
+			expectedType: "text/csv",
+			expectedBody: export(t, exportFixture()[:2], FormatCSV),
+		},
+		{
+			testName:     "Export as NDJSON",
+			query:        "?user_id=2&format=ndjson",
+			staff:        true,
+			expectedCode: http.StatusOK,
+			expectedType: "application/x-ndjson",
+			expectedBody: export(t, exportFixture()[2:], FormatNDJSON),
+		},
+		{
+			testName:     "Export nothing",
+			query:        "?status=overdue",
+			staff:        true,
+			expectedCode: http.StatusOK,
+			expectedType: "text/csv",
+			expectedBody: strings.Join(csvColumns, ",") + "\n",
+		},
+		{
+			testName:        "Export as patron",
+			expectedCode:    http.StatusForbidden,
+			expectedMessage: "Only staff can export reservations!",
+		},
+		{
+			testName:        "Unknown format",
+			query:           "?format=xml",
+			staff:           true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "Could not parse export format!",
+		},
+		{
+			testName:        "Invalid filter",
+			query:           "?book_id=x",
+			staff:           true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "Could not parse filter!",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			env := setupTestEnv(nil, exportFixture())
+			router := gin.Default()
+			router.GET("/reservations/export", env.ReservationHandler.ExportReservations)
+
+			req := httptest.NewRequest("GET", "/reservations/export"+tc.query, nil)
+			if tc.staff {
+				req.Header.Set("UserRole", "staff")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d; got %d: %s", tc.expectedCode, w.Code, w.Body.String())
+			}
+			if tc.expectedMessage != "" {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response["message"] != tc.expectedMessage {
+					t.Errorf("Expected error message '%s'; got '%s'", tc.expectedMessage, response["message"])
+				}
+				return
+			}
+			if w.Header().Get("Content-Type") != tc.expectedType {
+				t.Errorf("Expected content type %s; got %s", tc.expectedType, w.Header().Get("Content-Type"))
+			}
+			if w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %q; got %q", tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestAddReservation(t *testing.T) {
 	testCases := []struct {
 		testName         string
-		// booksInDB        []book.Book
+		booksInDB        []Book
 		reservationsInDB []Reservation
 		requestBody      string
 		expectedCode     int
@@ -120,7 +262,7 @@ func TestAddReservation(t *testing.T) {
 		// Case 1: AddReservation adds new reservation and update AvailableCopies
 		{
 			testName:         "Successfully added reservation",
-			// booksInDB:        []book.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
 			reservationsInDB: []Reservation{},
 			requestBody:      `{"book_id": 1}`,
 			expectedCode:     http.StatusCreated,
@@ -129,7 +271,7 @@ func TestAddReservation(t *testing.T) {
 		// Case 2: AddReservation returns a bad request
 		{
 			testName:         "Bad request",
-			// booksInDB:        []book.Book{},
+			booksInDB:        []Book{},
 			reservationsInDB: []Reservation{},
 			requestBody:      `{"book_id": 1a}`,
 			expectedCode:     http.StatusBadRequest,
@@ -138,7 +280,7 @@ func TestAddReservation(t *testing.T) {
 		// Case 3: AddReservation could not fetch book! Returns InternalServerError
 		{
 			testName:         "No books",
-			// booksInDB:        []book.Book{},
+			booksInDB:        []Book{},
 			reservationsInDB: []Reservation{},
 			requestBody:      `{"book_id": 18}`,
 			expectedCode:     http.StatusInternalServerError,
@@ -147,21 +289,32 @@ func TestAddReservation(t *testing.T) {
 		// Case 4: AddReservation returns a bad request. The book is not available!
 		{
 			testName:         "AvailableCopies is 0",
-			// booksInDB:        []book.Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
 			reservationsInDB: []Reservation{},
 			requestBody:      `{"book_id": 1}`,
 			expectedCode:     http.StatusBadRequest,
 			expectedErrorMsg: "The book is not available!",
 		},
+		// Case 5: AddReservation returns a bad request. The copy is booked before the loan would end
+		{
+			testName:  "Booked during the loan period",
+			booksInDB: []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusBooked,
+				StartDate: ptr(time.Now().Add(48 * time.Hour)), EndDate: ptr(time.Now().Add(96 * time.Hour))}},
+			requestBody:      `{"book_id": 1}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The book is booked by someone else during the loan period!",
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.testName, func(t *testing.T) {
 			// Set up the test environment
-			env := setupTestEnv(tc.reservationsInDB)
+			env := setupTestEnv(tc.booksInDB, tc.reservationsInDB)
 
 			// HTTP request
 			req := httptest.NewRequest(http.MethodPost, "/reservations", strings.NewReader(tc.requestBody))
 			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("UserID", "1")
 			w := httptest.NewRecorder()
 
 			// Gin context
@@ -179,17 +332,17 @@ func TestAddReservation(t *testing.T) {
 
 			if tc.expectedErrorMsg == "" {
 				// Check if AvailableCopies of book with id:1 was updated(was: 1, should be: 0)
-				// reservedBook, err := env.BookRepo.GetById(1)
-				// if err != nil {
-				// 	t.Errorf("Could not fetch book! error: %d", err)
-				// }
-				// if reservedBook.AvailableCopies != 0 {
-				// 	t.Errorf("Expected AvailableCopies %d; got %d", 0, reservedBook.AvailableCopies)
-				// }
+				reservedBook, err := env.BookClient.GetBook(context.Request.Context(), 1)
+				if err != nil {
+					t.Errorf("Could not fetch book! error: %v", err)
+				}
+				if reservedBook.AvailableCopies != 0 {
+					t.Errorf("Expected AvailableCopies %d; got %d", 0, reservedBook.AvailableCopies)
+				}
 
 				// Check if reservation was added
-				expRes := Reservation{ID: 1, BookId: 1, UserId: 1}
-				gotedRes, err := env.ReservationRepo.GetById(1)
+				expRes := Reservation{ID: 1, BookId: 1, UserId: 1, Status: StatusRequested, Version: 1}
+				gotedRes, err := env.ReservationRepo.GetById(context.Request.Context(), 1)
 				if err != nil {
 					t.Errorf("Could not fetch book! error: %d", err)
 				}
@@ -197,6 +350,12 @@ func TestAddReservation(t *testing.T) {
 					t.Errorf("Expected new rservation id:%d, book_id:%d, user_id:%d; got id:%d, book_id:%d, user_id:%d",
 						expRes.ID, expRes.BookId, expRes.UserId, gotedRes.ID, gotedRes.BookId, gotedRes.UserId)
 				}
+
+				// Check if ReservationCreated was published
+				published := env.Publisher.Events()
+				if len(published) != 1 || published[0].Type != events.ReservationCreated || published[0].Version != events.Version {
+					t.Errorf("Expected one ReservationCreated event; got %+v", published)
+				}
 			} else {
 				// Check if the response contains the expected error message
 				var response map[string]string
@@ -214,10 +373,151 @@ func TestAddReservation(t *testing.T) {
 
 }
 
+func TestAddBooking(t *testing.T) {
+	day := 24 * time.Hour
+	start := time.Now().Add(7 * day).Truncate(time.Second)
+	end := start.Add(7 * day)
+	request := func(start, end time.Time) string {
+		return fmt.Sprintf(`{"book_id": 1, "start_date": %q, "end_date": %q}`, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	loanDue := time.Now().Add(3 * day)
+	loanOverdue := time.Now().Add(-day)
+
+	testCases := []struct {
+		testName         string
+		booksInDB        []Book
+		reservationsInDB []Reservation
+		requestBody      string
+		expectedCode     int
+		expectedErrorMsg string
+	}{
+		// Case 1: A future booking is stored as booked
+		{
+			testName:         "Successfully added booking",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			reservationsInDB: []Reservation{},
+			requestBody:      request(start, end),
+			expectedCode:     http.StatusCreated,
+		},
+		// Case 2: A copy lent out now but due before the window can be booked
+		{
+			testName:         "Loan returned before booking starts",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusActive, DueDate: &loanDue}},
+			requestBody:      request(start, end),
+			expectedCode:     http.StatusCreated,
+		},
+		// Case 3: An overdue loan keeps its copy
+		{
+			testName:         "Overdue loan",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusOverdue, DueDate: &loanOverdue}},
+			requestBody:      request(start, end),
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The book is not available for the requested dates!",
+		},
+		// Case 4: Another booking of the only copy overlaps the window
+		{
+			testName:  "Overlapping booking",
+			booksInDB: []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusBooked,
+				StartDate: ptr(start.Add(3 * day)), EndDate: ptr(end.Add(3 * day))}},
+			requestBody:      request(start, end),
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The book is not available for the requested dates!",
+		},
+		// Case 5: Back-to-back bookings do not overlap
+		{
+			testName:  "Adjacent booking",
+			booksInDB: []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusBooked,
+				StartDate: ptr(end), EndDate: ptr(end.Add(7 * day))}},
+			requestBody:  request(start, end),
+			expectedCode: http.StatusCreated,
+		},
+		// Case 6: The end date must follow the start date
+		{
+			testName:         "End before start",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			reservationsInDB: []Reservation{},
+			requestBody:      request(end, start),
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The booking must end after it starts!",
+		},
+		// Case 7: A booking cannot be longer than a loan
+		{
+			testName:         "Longer than loan period",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			reservationsInDB: []Reservation{},
+			requestBody:      request(start, start.Add(30*day)),
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The booking is longer than the loan period!",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv(tc.booksInDB, tc.reservationsInDB)
+			copies := tc.booksInDB[0].AvailableCopies
+
+			// HTTP request
+			req := httptest.NewRequest(http.MethodPost, "/reservations", strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("UserID", "1")
+			w := httptest.NewRecorder()
+
+			// Gin context
+			gin.SetMode(gin.TestMode)
+			context, _ := gin.CreateTestContext(w)
+			context.Request = req
+
+			// Perform the request
+			env.ReservationHandler.AddReservation(context)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			// Bookings never take a copy up front
+			book, err := env.BookClient.GetBook(context.Request.Context(), 1)
+			if err != nil {
+				t.Fatalf("Could not fetch book! error: %v", err)
+			}
+			if book.AvailableCopies != copies {
+				t.Errorf("Expected AvailableCopies %d; got %d", copies, book.AvailableCopies)
+			}
+
+			if tc.expectedErrorMsg == "" {
+				id := int64(len(tc.reservationsInDB)) + 1
+				gotRes, err := env.ReservationRepo.GetById(context.Request.Context(), id)
+				if err != nil {
+					t.Fatalf("Could not fetch reservation! error: %v", err)
+				}
+				if gotRes.Status != StatusBooked || gotRes.StartDate == nil || !gotRes.StartDate.Equal(start) {
+					t.Errorf("Expected booking starting %v; got %+v", start, gotRes)
+				}
+			} else {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response["message"] != tc.expectedErrorMsg {
+					t.Errorf("Expected error message '%s'; got '%s'", tc.expectedErrorMsg, response["message"])
+				}
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
 func TestCompleteReservation(t *testing.T) {
 	testCases := []struct {
 		testName         string
-		// booksInDB        []book.Book
+		booksInDB        []Book
 		reservationsInDB []Reservation
 		reservationId    string
 		expectedCode     int
@@ -226,16 +526,25 @@ func TestCompleteReservation(t *testing.T) {
 		// Case 1: CopleteReservation add return date for reservation and update AvailableCopies for book
 		{
 			testName:         "Successfully completed reservation",
-			// booksInDB:        []book.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
 			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, ReturnDate: nil}},
 			reservationId:    "1",
 			expectedCode:     http.StatusOK,
 			expectedErrorMsg: "",
 		},
+		// A reservation that was never picked up can be completed too
+		{
+			testName:         "Completed requested reservation",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusRequested}},
+			reservationId:    "1",
+			expectedCode:     http.StatusOK,
+			expectedErrorMsg: "",
+		},
 		// Case 2: CopleteReservation returns a bad request
 		{
 			testName:         "Bad request",
-			// booksInDB:        []book.Book{},
+			booksInDB:        []Book{},
 			reservationsInDB: []Reservation{},
 			reservationId:    "a",
 			expectedCode:     http.StatusBadRequest,
@@ -244,7 +553,7 @@ func TestCompleteReservation(t *testing.T) {
 		// Case 3: CopleteReservation could not fetch reservation! Returns InternalServerError
 		{
 			testName:         "No resrvation with this id",
-			// booksInDB:        []book.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
 			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, ReturnDate: nil}},
 			reservationId:    "2",
 			expectedCode:     http.StatusInternalServerError,
@@ -253,7 +562,7 @@ func TestCompleteReservation(t *testing.T) {
 		// Case 4: CopleteReservation returns a StatusUnauthorized. User1 cannot complete reservation of user2
 		{
 			testName:         "No access to reservation",
-			// booksInDB:        []book.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
 			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, ReturnDate: nil}},
 			reservationId:    "1",
 			expectedCode:     http.StatusUnauthorized,
@@ -262,21 +571,32 @@ func TestCompleteReservation(t *testing.T) {
 		// Case 5: Cannot complete reservation if returnDate is not nil
 		{
 			testName:         "Rreservation is completed already",
-			// booksInDB:        []book.Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
 			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, ReturnDate: &time.Time{}}},
 			reservationId:    "1",
 			expectedCode:     http.StatusBadRequest,
 			expectedErrorMsg: "The reservation is copleted already!",
 		},
+		// Case 6: Cannot complete a cancelled reservation
+		{
+			testName:         "Reservation is cancelled",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusCancelled}},
+			reservationId:    "1",
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "Could not complete cancelled reservation!",
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.testName, func(t *testing.T) {
 			// Set up the test environment
-			env := setupTestEnv(tc.reservationsInDB)
+			env := setupTestEnv(tc.booksInDB, tc.reservationsInDB)
 
 			// HTTP request
 			req := httptest.NewRequest(http.MethodPost, "/reservations", nil)
 			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("UserID", "1")
+			req.Header.Set("If-Match", "*")
 			w := httptest.NewRecorder()
 
 			// Gin context
@@ -295,16 +615,16 @@ func TestCompleteReservation(t *testing.T) {
 
 			if tc.expectedErrorMsg == "" {
 				// Check if AvailableCopies of book with id:1 was updated(was: 1, should be: 2)
-				// reservedBook, err := env.BookRepo.GetById(1)
-				// if err != nil {
-				// 	t.Errorf("Could not fetch book! error: %v", err)
-				// }
-				// if reservedBook.AvailableCopies != 2 {
-				// 	t.Errorf("Expected AvailableCopies %d; got %d", 2, reservedBook.AvailableCopies)
-				// }
-				
+				reservedBook, err := env.BookClient.GetBook(context.Request.Context(), 1)
+				if err != nil {
+					t.Errorf("Could not fetch book! error: %v", err)
+				}
+				if reservedBook.AvailableCopies != 2 {
+					t.Errorf("Expected AvailableCopies %d; got %d", 2, reservedBook.AvailableCopies)
+				}
+
 				// Check if reservation was completed
-				gotRes, err := env.ReservationRepo.GetById(1)
+				gotRes, err := env.ReservationRepo.GetById(context.Request.Context(), 1)
 				if err != nil {
 					t.Errorf("Could not fetch reservation! error: %v", err)
 				}
@@ -327,3 +647,977 @@ func TestCompleteReservation(t *testing.T) {
 	}
 
 }
+
+func TestPickUpReservation(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		reservationsInDB []Reservation
+		reservationId    string
+		expectedCode     int
+		expectedStatus   Status
+		expectedErrorMsg string
+	}{
+		// Case 1: PickUpReservation activates a requested reservation
+		{
+			testName:         "Successfully picked up reservation",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusRequested}},
+			reservationId:    "1",
+			expectedCode:     http.StatusOK,
+			expectedStatus:   StatusActive,
+			expectedErrorMsg: "",
+		},
+		// Case 2: PickUpReservation returns a StatusUnauthorized for reservation of another user
+		{
+			testName:         "No access to reservation",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusRequested}},
+			reservationId:    "1",
+			expectedCode:     http.StatusUnauthorized,
+			expectedStatus:   StatusRequested,
+			expectedErrorMsg: "Not access to pick up reservation!",
+		},
+		// Case 3: Cannot pick up a reservation that is active already
+		{
+			testName:         "Reservation is active already",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusActive}},
+			reservationId:    "1",
+			expectedCode:     http.StatusBadRequest,
+			expectedStatus:   StatusActive,
+			expectedErrorMsg: "Could not pick up active reservation!",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv(nil, tc.reservationsInDB)
+
+			// HTTP request
+			req := httptest.NewRequest(http.MethodPost, "/reservations/"+tc.reservationId+"/pickup", nil)
+			req.Header.Set("If-Match", "*")
+			req.Header.Set("UserID", "1")
+			w := httptest.NewRecorder()
+
+			// Gin context
+			gin.SetMode(gin.TestMode)
+			context, _ := gin.CreateTestContext(w)
+			context.Request = req
+			context.AddParam("id", tc.reservationId)
+
+			// Perform the request
+			env.ReservationHandler.PickUpReservation(context)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			gotRes, err := env.ReservationRepo.GetById(context.Request.Context(), 1)
+			if err != nil {
+				t.Fatalf("Could not fetch reservation! error: %v", err)
+			}
+			if gotRes.Status != tc.expectedStatus {
+				t.Errorf("Expected status %s; got %s", tc.expectedStatus, gotRes.Status)
+			}
+			if gotRes.Status == StatusActive && tc.expectedErrorMsg == "" && (gotRes.DueDate == nil || gotRes.PickedUpAt == nil) {
+				t.Errorf("Expected due date and pickup time to be set on pickup")
+			}
+
+			if tc.expectedErrorMsg != "" {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response["message"] != tc.expectedErrorMsg {
+					t.Errorf("Expected error message '%s'; got '%s'", tc.expectedErrorMsg, response["message"])
+				}
+			}
+		})
+	}
+}
+
+func TestPickUpByBarcode(t *testing.T) {
+	copies := []Copy{
+		{BookId: 1, Barcode: "B-001", Branch: "main", Condition: CopyGood},
+		{BookId: 1, Barcode: "B-002", Branch: "main", Condition: CopyDamaged},
+		{BookId: 2, Barcode: "B-003", Branch: "main", Condition: CopyGood},
+		{BookId: 1, Barcode: "B-004", Branch: "main", Condition: CopyGood},
+	}
+	onLoan := int64(4)
+
+	testCases := []struct {
+		testName         string
+		requestBody      string
+		expectedCode     int
+		expectedCopyId   int64
+		expectedErrorMsg string
+	}{
+		// Case 1: The scanned copy is recorded on the loan
+		{
+			testName:       "Successfully picked up copy",
+			requestBody:    `{"barcode": "B-001"}`,
+			expectedCode:   http.StatusOK,
+			expectedCopyId: 1,
+		},
+		// Case 2: Tracked books need a scan
+		{
+			testName:         "Missing barcode",
+			requestBody:      "",
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "Scan the barcode of the copy to pick up!",
+		},
+		// Case 3: Unknown barcode
+		{
+			testName:         "Unknown barcode",
+			requestBody:      `{"barcode": "B-999"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "Unknown barcode!",
+		},
+		// Case 4: The copy must be lendable
+		{
+			testName:         "Damaged copy",
+			requestBody:      `{"barcode": "B-002"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The copy is damaged!",
+		},
+		// Case 5: The copy must belong to the reserved book
+		{
+			testName:         "Copy of another book",
+			requestBody:      `{"barcode": "B-003"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The copy does not belong to the reserved book!",
+		},
+		// Case 6: The copy must not be out on another loan
+		{
+			testName:         "Copy on loan",
+			requestBody:      `{"barcode": "B-004"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedErrorMsg: "The copy is already on loan!",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv(nil, []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusRequested},
+				{ID: 2, BookId: 1, UserId: 2, Status: StatusActive, CopyId: &onLoan},
+			})
+			for _, c := range copies {
+				_, err := env.ReservationRepo.AddCopy(gocontext.Background(), c)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// HTTP request
+			req := httptest.NewRequest(http.MethodPost, "/reservations/1/pickup", strings.NewReader(tc.requestBody))
+			req.Header.Set("If-Match", "*")
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("UserID", "1")
+			w := httptest.NewRecorder()
+
+			// Gin context
+			gin.SetMode(gin.TestMode)
+			context, _ := gin.CreateTestContext(w)
+			context.Request = req
+			context.AddParam("id", "1")
+
+			// Perform the request
+			env.ReservationHandler.PickUpReservation(context)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			gotRes, err := env.ReservationRepo.GetById(context.Request.Context(), 1)
+			if err != nil {
+				t.Fatalf("Could not fetch reservation! error: %v", err)
+			}
+			if tc.expectedErrorMsg == "" {
+				if gotRes.CopyId == nil || *gotRes.CopyId != tc.expectedCopyId {
+					t.Errorf("Expected copy %d on the loan; got %v", tc.expectedCopyId, gotRes.CopyId)
+				}
+			} else {
+				if gotRes.Status != StatusRequested {
+					t.Errorf("Expected status %s; got %s", StatusRequested, gotRes.Status)
+				}
+
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response["message"] != tc.expectedErrorMsg {
+					t.Errorf("Expected error message '%s'; got '%s'", tc.expectedErrorMsg, response["message"])
+				}
+			}
+		})
+	}
+}
+
+func TestAvailabilityFromCopies(t *testing.T) {
+	// The book service still reports a copy, but the only lendable copy is
+	// claimed by a requested reservation.
+	env := setupTestEnv([]Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+		[]Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusRequested}})
+	for _, c := range []Copy{
+		{BookId: 1, Barcode: "B-001", Condition: CopyGood},
+		{BookId: 1, Barcode: "B-002", Condition: CopyLost},
+	} {
+		_, err := env.ReservationRepo.AddCopy(gocontext.Background(), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/reservations", strings.NewReader(`{"book_id": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("UserID", "1")
+	w := httptest.NewRecorder()
+
+	gin.SetMode(gin.TestMode)
+	context, _ := gin.CreateTestContext(w)
+	context.Request = req
+
+	env.ReservationHandler.AddReservation(context)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d; got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetReservation(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		userId       string
+		role         string
+		expectedCode int
+	}{
+		{testName: "Owner", userId: "1", expectedCode: http.StatusOK},
+		{testName: "Another user", userId: "2", expectedCode: http.StatusUnauthorized},
+		{testName: "Staff", userId: "9", role: "staff", expectedCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv(nil, []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusActive}})
+
+			// HTTP request
+			req := httptest.NewRequest(http.MethodGet, "/reservations/1", nil)
+			req.Header.Set("UserID", tc.userId)
+			req.Header.Set("UserRole", tc.role)
+			w := httptest.NewRecorder()
+
+			// Gin context
+			gin.SetMode(gin.TestMode)
+			context, _ := gin.CreateTestContext(w)
+			context.Request = req
+			context.AddParam("id", "1")
+
+			// Perform the request
+			env.ReservationHandler.GetReservation(context)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestRenewReservation(t *testing.T) {
+	due := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	testCases := []struct {
+		testName         string
+		reservationsInDB []Reservation
+		renewalsInDB     int
+		expectedCode     int
+		expectedDueDate  time.Time
+		expectedErrorMsg string
+	}{
+		// Case 1: RenewReservation extends the due date by a loan period
+		{
+			testName:         "Successfully renewed reservation",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusActive, DueDate: &due}},
+			expectedCode:     http.StatusOK,
+			expectedDueDate:  due.Add(14 * 24 * time.Hour),
+		},
+		// Case 2: Only active loans can be renewed
+		{
+			testName:         "Reservation is overdue",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusOverdue, DueDate: &due}},
+			expectedCode:     http.StatusBadRequest,
+			expectedDueDate:  due,
+			expectedErrorMsg: "Could not renew overdue reservation!",
+		},
+		// Case 3: The renewal limit applies
+		{
+			testName:         "Renewal limit reached",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusActive, DueDate: &due}},
+			renewalsInDB:     2,
+			expectedCode:     http.StatusBadRequest,
+			expectedDueDate:  due,
+			expectedErrorMsg: "The reservation was renewed 2 times already!",
+		},
+		// Case 4: The copy is booked for after the due date
+		{
+			testName: "Booked after the due date",
+			reservationsInDB: []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusActive, DueDate: &due},
+				{ID: 2, BookId: 1, UserId: 2, Status: StatusBooked, StartDate: ptr(due.Add(24 * time.Hour)), EndDate: ptr(due.Add(48 * time.Hour))},
+			},
+			expectedCode:     http.StatusBadRequest,
+			expectedDueDate:  due,
+			expectedErrorMsg: "The book is booked by someone else after the due date!",
+		},
+		// Case 5: RenewReservation returns a StatusUnauthorized for reservation of another user
+		{
+			testName:         "No access to reservation",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusActive, DueDate: &due}},
+			expectedCode:     http.StatusUnauthorized,
+			expectedDueDate:  due,
+			expectedErrorMsg: "Not access to renew reservation!",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv([]Book{{ID: 1}}, tc.reservationsInDB)
+			handler := NewHandler(NewService(env.ReservationRepo, env.BookClient, env.Publisher,
+				Policy{LoanPeriod: 14 * 24 * time.Hour, MaxRenewals: 2}))
+			for i := 0; i < tc.renewalsInDB; i++ {
+				err := env.ReservationRepo.AddEvent(gocontext.Background(), Event{ReservationId: 1, Type: EventRenewed})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// HTTP request
+			req := httptest.NewRequest(http.MethodPost, "/reservations/1/renew", nil)
+			req.Header.Set("If-Match", "*")
+			req.Header.Set("UserID", "1")
+			w := httptest.NewRecorder()
+
+			// Gin context
+			gin.SetMode(gin.TestMode)
+			context, _ := gin.CreateTestContext(w)
+			context.Request = req
+			context.AddParam("id", "1")
+
+			// Perform the request
+			handler.RenewReservation(context)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			gotRes, err := env.ReservationRepo.GetById(context.Request.Context(), 1)
+			if err != nil {
+				t.Fatalf("Could not fetch reservation! error: %v", err)
+			}
+			if !gotRes.DueDate.Equal(tc.expectedDueDate) {
+				t.Errorf("Expected due date %v; got %v", tc.expectedDueDate, gotRes.DueDate)
+			}
+
+			if tc.expectedErrorMsg != "" {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response["message"] != tc.expectedErrorMsg {
+					t.Errorf("Expected error message '%s'; got '%s'", tc.expectedErrorMsg, response["message"])
+				}
+			}
+		})
+	}
+}
+
+func TestGetReservationHistory(t *testing.T) {
+	// Set up the test environment
+	env := setupTestEnv(
+		[]Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+		[]Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusActive}, {ID: 2, BookId: 1, UserId: 2, Status: StatusActive}},
+	)
+
+	router := gin.New()
+	router.POST("/reservations/:id", env.ReservationHandler.CompleteReservation)
+	router.GET("/reservations/:id/history", env.ReservationHandler.GetReservationHistory)
+
+	// Complete the reservation to produce a history entry
+	req := httptest.NewRequest(http.MethodPost, "/reservations/1", nil)
+	req.Header.Set("If-Match", "*")
+	req.Header.Set("UserID", "1")
+	req.RemoteAddr = "10.0.0.7:51234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// History of the own reservation
+	req = httptest.NewRequest(http.MethodGet, "/reservations/1/history", nil)
+	req.Header.Set("UserID", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d; got %d", http.StatusOK, w.Code)
+	}
+
+	var events []Event
+	err := json.Unmarshal(w.Body.Bytes(), &events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event; got %d", len(events))
+	}
+
+	event := events[0]
+	if event.Type != EventCompleted || event.ActorId != 1 || event.SourceIP != "10.0.0.7" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event.Before == nil || event.Before.Status != StatusActive {
+		t.Errorf("Expected before snapshot with status %s; got %+v", StatusActive, event.Before)
+	}
+	if event.After == nil || event.After.Status != StatusReturned {
+		t.Errorf("Expected after snapshot with status %s; got %+v", StatusReturned, event.After)
+	}
+
+	// History of another user's reservation
+	req = httptest.NewRequest(http.MethodGet, "/reservations/2/history", nil)
+	req.Header.Set("UserID", "1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d; got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestReservationVersions(t *testing.T) {
+	due := time.Now().Add(24 * time.Hour)
+	env := setupTestEnv(
+		[]Book{{ID: 1, Title: "Book_1", AvailableCopies: 1}},
+		[]Reservation{{ID: 1, BookId: 1, UserId: 1, DueDate: &due, Status: StatusActive, Version: 3}},
+	)
+
+	router := gin.New()
+	router.GET("/reservations/:id", env.ReservationHandler.GetReservation)
+	router.POST("/reservations/:id/renew", env.ReservationHandler.RenewReservation)
+
+	// The steps share one repository, so later steps see the effects of earlier ones.
+	testCases := []struct {
+		testName     string
+		method       string
+		ifMatch      string
+		expectedCode int
+		expectedETag string
+	}{
+		{"Read the version", http.MethodGet, "", http.StatusOK, `"3"`},
+		{"Change without If-Match", http.MethodPost, "", http.StatusPreconditionRequired, ""},
+		{"Change with a malformed If-Match", http.MethodPost, "3", http.StatusBadRequest, ""},
+		{"Change a stale version", http.MethodPost, `"2"`, http.StatusPreconditionFailed, ""},
+		{"Change the current version", http.MethodPost, `"3"`, http.StatusOK, `"4"`},
+		{"Change the version read before", http.MethodPost, `"3"`, http.StatusPreconditionFailed, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			path := "/reservations/1"
+			if tc.method == http.MethodPost {
+				path += "/renew"
+			}
+			req := httptest.NewRequest(tc.method, path, nil)
+			req.Header.Set("UserID", "1")
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d: %s", tc.expectedCode, w.Code, w.Body.String())
+			}
+			if etag := w.Header().Get("ETag"); etag != tc.expectedETag {
+				t.Errorf("Expected ETag %s; got %s", tc.expectedETag, etag)
+			}
+		})
+	}
+
+	history, _ := env.ReservationRepo.GetEvents(gocontext.Background(), 1)
+	if len(history) != 1 {
+		t.Errorf("Expected the loan to be renewed once; got %+v", history)
+	}
+}
+
+func TestCancelReservation(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		booksInDB        []Book
+		reservationsInDB []Reservation
+		reservationId    string
+		expectedCode     int
+		expectedStatus   Status
+		expectedCopies   int64
+		expectedErrorMsg string
+	}{
+		// Case 1: CancelReservation cancels a requested reservation and restores the copy
+		{
+			testName:         "Successfully cancelled reservation",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, CheckoutDate: time.Now(), Status: StatusRequested}},
+			reservationId:    "1",
+			expectedCode:     http.StatusOK,
+			expectedStatus:   StatusCancelled,
+			expectedCopies:   1,
+			expectedErrorMsg: "",
+		},
+		// Case 2: Cannot cancel a reservation that was picked up already
+		{
+			testName:         "Reservation is picked up",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, CheckoutDate: time.Now(), Status: StatusActive}},
+			reservationId:    "1",
+			expectedCode:     http.StatusBadRequest,
+			expectedStatus:   StatusActive,
+			expectedCopies:   0,
+			expectedErrorMsg: "Could not cancel active reservation!",
+		},
+		// Case 3: Cannot cancel after the cancellation window
+		{
+			testName:         "Cancellation window expired",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, CheckoutDate: time.Now().Add(-2 * time.Hour), Status: StatusRequested}},
+			reservationId:    "1",
+			expectedCode:     http.StatusBadRequest,
+			expectedStatus:   StatusRequested,
+			expectedCopies:   0,
+			expectedErrorMsg: "The cancellation window has expired!",
+		},
+		// Case 4: CancelReservation returns a StatusUnauthorized for reservation of another user
+		{
+			testName:         "No access to reservation",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, CheckoutDate: time.Now(), Status: StatusRequested}},
+			reservationId:    "1",
+			expectedCode:     http.StatusUnauthorized,
+			expectedStatus:   StatusRequested,
+			expectedCopies:   0,
+			expectedErrorMsg: "Not access to cancel reservation!",
+		},
+		// Case 5: A booking can be cancelled outside the window and holds no copy to restore
+		{
+			testName:         "Successfully cancelled booking",
+			booksInDB:        []Book{{ID: 1, Title: "Book_1", AvailableCopies: 0}},
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, CheckoutDate: time.Now().Add(-48 * time.Hour), Status: StatusBooked}},
+			reservationId:    "1",
+			expectedCode:     http.StatusOK,
+			expectedStatus:   StatusCancelled,
+			expectedCopies:   0,
+			expectedErrorMsg: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv(tc.booksInDB, tc.reservationsInDB)
+
+			// HTTP request
+			req := httptest.NewRequest(http.MethodDelete, "/reservations/"+tc.reservationId, nil)
+			req.Header.Set("If-Match", "*")
+			req.Header.Set("UserID", "1")
+			w := httptest.NewRecorder()
+
+			// Gin context
+			gin.SetMode(gin.TestMode)
+			context, _ := gin.CreateTestContext(w)
+			context.Request = req
+			context.AddParam("id", tc.reservationId)
+
+			// Perform the request
+			env.ReservationHandler.CancelReservation(context)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			gotRes, err := env.ReservationRepo.GetById(context.Request.Context(), 1)
+			if err != nil {
+				t.Fatalf("Could not fetch reservation! error: %v", err)
+			}
+			if gotRes.Status != tc.expectedStatus {
+				t.Errorf("Expected status %s; got %s", tc.expectedStatus, gotRes.Status)
+			}
+
+			book, err := env.BookClient.GetBook(context.Request.Context(), 1)
+			if err != nil {
+				t.Fatalf("Could not fetch book! error: %v", err)
+			}
+			if book.AvailableCopies != tc.expectedCopies {
+				t.Errorf("Expected AvailableCopies %d; got %d", tc.expectedCopies, book.AvailableCopies)
+			}
+
+			if tc.expectedErrorMsg != "" {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response["message"] != tc.expectedErrorMsg {
+					t.Errorf("Expected error message '%s'; got '%s'", tc.expectedErrorMsg, response["message"])
+				}
+			}
+		})
+	}
+}
+
+func TestMarkReservationLost(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		reservationsInDB []Reservation
+		role             string
+		requestBody      string
+		expectedCode     int
+		expectedStatus   Status
+		expectedCharges  int
+		expectedEvent    events.Type
+		expectedErrorMsg string
+	}{
+		// Case 1: Staff marks an active loan as lost with a replacement charge
+		{
+			testName:         "Successfully marked as lost",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusActive}},
+			role:             "staff",
+			requestBody:      `{"condition": "lost", "note": "Patron moved away", "replacement_cost_cents": 2500}`,
+			expectedCode:     http.StatusOK,
+			expectedStatus:   StatusLost,
+			expectedCharges:  1,
+			expectedEvent:    events.ReservationLost,
+			expectedErrorMsg: "",
+		},
+		// Case 2: Staff marks an overdue loan as damaged without a charge
+		{
+			testName:         "Successfully marked as damaged",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusOverdue}},
+			role:             "staff",
+			requestBody:      `{"condition": "damaged", "note": "Water damage"}`,
+			expectedCode:     http.StatusOK,
+			expectedStatus:   StatusDamaged,
+			expectedCharges:  0,
+			expectedEvent:    events.ReservationDamaged,
+			expectedErrorMsg: "",
+		},
+		// Case 3: Patrons cannot mark loans as lost
+		{
+			testName:         "Not staff",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusActive}},
+			role:             "",
+			requestBody:      `{"condition": "lost"}`,
+			expectedCode:     http.StatusForbidden,
+			expectedStatus:   StatusActive,
+			expectedCharges:  0,
+			expectedErrorMsg: "Only staff can mark reservations as lost!",
+		},
+		// Case 4: Returned loans cannot be marked as lost
+		{
+			testName:         "Reservation is returned",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusReturned}},
+			role:             "staff",
+			requestBody:      `{"condition": "lost"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedStatus:   StatusReturned,
+			expectedCharges:  0,
+			expectedErrorMsg: "Could not mark returned reservation as lost!",
+		},
+		// Case 5: Unknown condition
+		{
+			testName:         "Bad request",
+			reservationsInDB: []Reservation{{ID: 1, BookId: 1, UserId: 2, Status: StatusActive}},
+			role:             "staff",
+			requestBody:      `{"condition": "returned"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedStatus:   StatusActive,
+			expectedCharges:  0,
+			expectedErrorMsg: "Could not parse request data!",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv(nil, tc.reservationsInDB)
+
+			// HTTP request
+			req := httptest.NewRequest(http.MethodPost, "/reservations/1/lost", strings.NewReader(tc.requestBody))
+			req.Header.Set("If-Match", "*")
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("UserID", "1")
+			req.Header.Set("UserRole", tc.role)
+			w := httptest.NewRecorder()
+
+			// Gin context
+			gin.SetMode(gin.TestMode)
+			context, _ := gin.CreateTestContext(w)
+			context.Request = req
+			context.AddParam("id", "1")
+
+			// Perform the request
+			env.ReservationHandler.MarkReservationLost(context)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			gotRes, err := env.ReservationRepo.GetById(context.Request.Context(), 1)
+			if err != nil {
+				t.Fatalf("Could not fetch reservation! error: %v", err)
+			}
+			if gotRes.Status != tc.expectedStatus {
+				t.Errorf("Expected status %s; got %s", tc.expectedStatus, gotRes.Status)
+			}
+			if len(env.ReservationRepo.charges) != tc.expectedCharges {
+				t.Errorf("Expected %d charges; got %d", tc.expectedCharges, len(env.ReservationRepo.charges))
+			}
+			published := env.Publisher.Events()
+			if tc.expectedEvent != "" && (len(published) != 1 || published[0].Type != tc.expectedEvent) {
+				t.Errorf("Expected one %s event; got %+v", tc.expectedEvent, published)
+			}
+
+			if tc.expectedErrorMsg != "" {
+				var response map[string]string
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if response["message"] != tc.expectedErrorMsg {
+					t.Errorf("Expected error message '%s'; got '%s'", tc.expectedErrorMsg, response["message"])
+				}
+			}
+		})
+	}
+}
+
+func TestAddReservations(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		booksInDB        []Book
+		requestBody      string
+		expectedCode     int
+		expectedStatuses []string
+		expectedCopies   map[int64]int64
+	}{
+		// Case 1: All books are available
+		{
+			testName:         "Successfully added reservations",
+			booksInDB:        []Book{{ID: 1, AvailableCopies: 2}, {ID: 2, AvailableCopies: 1}},
+			requestBody:      `{"book_ids": [1, 2]}`,
+			expectedCode:     http.StatusCreated,
+			expectedStatuses: []string{ItemOK, ItemOK},
+			expectedCopies:   map[int64]int64{1: 1, 2: 0},
+		},
+		// Case 2: The same book twice, the second one is rejected
+		{
+			testName:         "Book requested twice",
+			booksInDB:        []Book{{ID: 1, AvailableCopies: 2}},
+			requestBody:      `{"book_ids": [1, 1]}`,
+			expectedCode:     http.StatusMultiStatus,
+			expectedStatuses: []string{ItemOK, ItemRejected},
+			expectedCopies:   map[int64]int64{1: 1},
+		},
+		// Case 3: Not enough copies, valid items are still applied
+		{
+			testName:         "Partially added reservations",
+			booksInDB:        []Book{{ID: 1, AvailableCopies: 1}, {ID: 2, AvailableCopies: 1}},
+			requestBody:      `{"book_ids": [1, 1, 2, 3]}`,
+			expectedCode:     http.StatusMultiStatus,
+			expectedStatuses: []string{ItemOK, ItemRejected, ItemOK, ItemRejected},
+			expectedCopies:   map[int64]int64{1: 0, 2: 0},
+		},
+		// Case 4: Not enough copies in atomic mode, nothing is applied
+		{
+			testName:         "Atomic batch rejected",
+			booksInDB:        []Book{{ID: 1, AvailableCopies: 1}, {ID: 2, AvailableCopies: 1}},
+			requestBody:      `{"book_ids": [1, 1, 2], "atomic": true}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedStatuses: []string{ItemSkipped, ItemRejected, ItemSkipped},
+			expectedCopies:   map[int64]int64{1: 1, 2: 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv(tc.booksInDB, []Reservation{})
+
+			router := gin.New()
+			router.POST("/reservations/batch", env.ReservationHandler.AddReservations)
+			router.POST("/reservations/:id", env.ReservationHandler.CompleteReservation)
+
+			req := httptest.NewRequest(http.MethodPost, "/reservations/batch", strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("UserID", "1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			var response struct {
+				Results []BatchItemResult `json:"results"`
+			}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Results) != len(tc.expectedStatuses) {
+				t.Fatalf("Expected %d results; got %+v", len(tc.expectedStatuses), response.Results)
+			}
+			for i, result := range response.Results {
+				if result.Status != tc.expectedStatuses[i] {
+					t.Errorf("Item %d: expected status %s; got %+v", i, tc.expectedStatuses[i], result)
+				}
+			}
+
+			for bookId, copies := range tc.expectedCopies {
+				book, _ := env.BookClient.GetBook(req.Context(), bookId)
+				if book.AvailableCopies != copies {
+					t.Errorf("Book %d: expected AvailableCopies %d; got %d", bookId, copies, book.AvailableCopies)
+				}
+			}
+		})
+	}
+}
+
+func TestCompleteReservations(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		reservationsInDB []Reservation
+		ifMatch          string
+		requestBody      string
+		expectedCode     int
+		expectedStatuses []string
+		expectedCopies   int64
+	}{
+		// Case 1: All reservations are returned, the book is updated once
+		{
+			testName: "Successfully completed reservations",
+			reservationsInDB: []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusActive},
+				{ID: 2, BookId: 1, UserId: 1, Status: StatusOverdue},
+			},
+			ifMatch:          "*",
+			requestBody:      `{"reservation_ids": [1, 2]}`,
+			expectedCode:     http.StatusOK,
+			expectedStatuses: []string{ItemOK, ItemOK},
+			expectedCopies:   2,
+		},
+		// Case 2: Reservation of another user in atomic mode
+		{
+			testName: "Atomic batch rejected",
+			reservationsInDB: []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusActive},
+				{ID: 2, BookId: 1, UserId: 2, Status: StatusActive},
+			},
+			ifMatch:          "*",
+			requestBody:      `{"reservation_ids": [1, 2], "atomic": true}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedStatuses: []string{ItemSkipped, ItemRejected},
+			expectedCopies:   0,
+		},
+		// Case 3: The same reservation twice
+		{
+			testName: "Duplicate reservation",
+			reservationsInDB: []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusActive},
+			},
+			ifMatch:          "*",
+			requestBody:      `{"reservation_ids": [1, 1]}`,
+			expectedCode:     http.StatusMultiStatus,
+			expectedStatuses: []string{ItemOK, ItemRejected},
+			expectedCopies:   1,
+		},
+		// Case 4: The versions the patron read are current
+		{
+			testName: "Current versions",
+			reservationsInDB: []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusActive, Version: 3},
+				{ID: 2, BookId: 1, UserId: 1, Status: StatusActive, Version: 1},
+			},
+			requestBody:      `{"reservation_ids": [1, 2], "versions": [3, 1]}`,
+			expectedCode:     http.StatusOK,
+			expectedStatuses: []string{ItemOK, ItemOK},
+			expectedCopies:   2,
+		},
+		// Case 5: One reservation was changed since, so none is returned
+		{
+			testName: "Stale version",
+			reservationsInDB: []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusActive, Version: 3},
+				{ID: 2, BookId: 1, UserId: 1, Status: StatusActive, Version: 2},
+			},
+			requestBody:    `{"reservation_ids": [1, 2], "versions": [3, 1]}`,
+			expectedCode:   http.StatusPreconditionFailed,
+			expectedCopies: 0,
+		},
+		// Case 6: Without versions the batch must be explicitly unconditional
+		{
+			testName: "Versions required",
+			reservationsInDB: []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusActive},
+			},
+			requestBody:    `{"reservation_ids": [1]}`,
+			expectedCode:   http.StatusPreconditionRequired,
+			expectedCopies: 0,
+		},
+		// Case 7: Every reservation needs its version
+		{
+			testName: "Versions do not match",
+			reservationsInDB: []Reservation{
+				{ID: 1, BookId: 1, UserId: 1, Status: StatusActive},
+				{ID: 2, BookId: 1, UserId: 1, Status: StatusActive},
+			},
+			requestBody:    `{"reservation_ids": [1, 2], "versions": [0]}`,
+			expectedCode:   http.StatusBadRequest,
+			expectedCopies: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Set up the test environment
+			env := setupTestEnv([]Book{{ID: 1, AvailableCopies: 0}}, tc.reservationsInDB)
+
+			router := gin.New()
+			router.POST("/reservations/batch/complete", env.ReservationHandler.CompleteReservations)
+			router.POST("/reservations/:id/pickup", env.ReservationHandler.PickUpReservation)
+
+			req := httptest.NewRequest(http.MethodPost, "/reservations/batch/complete", strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("UserID", "1")
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status %d; got %d", tc.expectedCode, w.Code)
+			}
+
+			var response struct {
+				Results []BatchItemResult `json:"results"`
+			}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Results) != len(tc.expectedStatuses) {
+				t.Fatalf("Expected %d results; got %+v", len(tc.expectedStatuses), response.Results)
+			}
+			for i, result := range response.Results {
+				if result.Status != tc.expectedStatuses[i] {
+					t.Errorf("Item %d: expected status %s; got %+v", i, tc.expectedStatuses[i], result)
+				}
+			}
+
+			book, _ := env.BookClient.GetBook(req.Context(), 1)
+			if book.AvailableCopies != tc.expectedCopies {
+				t.Errorf("Expected AvailableCopies %d; got %d", tc.expectedCopies, book.AvailableCopies)
+			}
+		})
+	}
+}
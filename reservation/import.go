@@ -0,0 +1,218 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultImportBatchSize is how many reservations an import commits
+// together unless told otherwise.
+const DefaultImportBatchSize = 500
+
+// ImportOptions control Import.
+type ImportOptions struct {
+	Format Format
+	// BatchSize is how many reservations are committed together.
+	BatchSize int
+	// DryRun imports the whole file in one transaction and rolls it back, so
+	// that the report also lists the rows the database refuses, including
+	// those that conflict with rows of earlier batches.
+	DryRun bool
+	// Overwrite replaces existing reservations with the same ID. Without it,
+	// such rows are rejected. Reservations that are not closed are never
+	// replaced.
+	Overwrite bool
+}
+
+// ImportReport tells how an import went. In a dry run, Imported counts the
+// rows that would have been imported.
+type ImportReport struct {
+	DryRun   bool          `json:"dry_run"`
+	Rows     int           `json:"rows"`
+	Imported int           `json:"imported"`
+	Rejected []RejectedRow `json:"rejected"`
+}
+
+// RejectedRow is a row that was not imported, by its line in the file.
+type RejectedRow struct {
+	Line   int    `json:"line"`
+	ID     int64  `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (r *ImportReport) reject(line int, id int64, err error) {
+	r.Rejected = append(r.Rejected, RejectedRow{Line: line, ID: id, Reason: err.Error()})
+}
+
+// errDryRun rolls back a dry run.
+var errDryRun = errors.New("dry run")
+
+// Import reads reservations exported from this service or another system
+// and inserts them by ID in batches, recording an EventImported for each.
+// Only closed reservations can be imported: the copies of the book service
+// are not adjusted for them. Rows that are malformed, invalid or refused by
+// the database are reported and skipped; the error is only set when the file
+// as a whole cannot be read.
+func Import(ctx context.Context, repo Repository, r io.Reader, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{DryRun: opts.DryRun, Rejected: []RejectedRow{}}
+	reader, err := newRecordReader(r, opts.Format)
+	if err != nil {
+		return report, err
+	}
+	if !opts.DryRun {
+		err = importRecords(ctx, repo, reader, opts, &report)
+		return report, err
+	}
+
+	err = repo.WithTx(ctx, func(tx Repository) error {
+		err := importRecords(ctx, tx, reader, opts, &report)
+		if err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	return report, err
+}
+
+func importRecords(ctx context.Context, repo Repository, reader recordReader, opts ImportOptions, report *ImportReport) error {
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		batchSize = DefaultImportBatchSize
+	}
+
+	var batch []Reservation
+	var lines []int
+	// seen maps the IDs read so far to their line.
+	seen := make(map[int64]int)
+	flush := func() {
+		report.Imported += importBatch(ctx, repo, batch, lines, opts.Overwrite, report)
+		batch, lines = batch[:0], lines[:0]
+	}
+
+	for {
+		res, line, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, ErrMalformedRecord) {
+			return err
+		}
+		report.Rows++
+		if err != nil {
+			report.reject(line, 0, err)
+			continue
+		}
+
+		err = prepareImport(&res)
+		if err != nil {
+			report.reject(line, res.ID, err)
+			continue
+		}
+		if first, ok := seen[res.ID]; ok {
+			report.reject(line, res.ID, fmt.Errorf("id %d is already on line %d", res.ID, first))
+			continue
+		}
+		seen[res.ID] = line
+
+		batch = append(batch, res)
+		lines = append(lines, line)
+		if len(batch) == batchSize {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+	return nil
+}
+
+// importBatch imports the batch in one transaction and returns how many of
+// its reservations were imported. When the database refuses the batch, its
+// reservations are imported one at a time to find the ones to reject.
+func importBatch(ctx context.Context, repo Repository, batch []Reservation, lines []int, overwrite bool, report *ImportReport) int {
+	err := commitImport(ctx, repo, batch, overwrite)
+	if err == nil {
+		return len(batch)
+	}
+	if len(batch) == 1 {
+		report.reject(lines[0], batch[0].ID, err)
+		return 0
+	}
+
+	imported := 0
+	for i, res := range batch {
+		imported += importBatch(ctx, repo, []Reservation{res}, lines[i:i+1], overwrite, report)
+	}
+	return imported
+}
+
+func commitImport(ctx context.Context, repo Repository, batch []Reservation, overwrite bool) error {
+	return repo.WithTx(ctx, func(tx Repository) error {
+		replaced, err := tx.Import(ctx, batch, overwrite)
+		if err != nil {
+			return err
+		}
+		for i, res := range batch {
+			after, err := tx.GetById(ctx, res.ID)
+			if err != nil {
+				return fmt.Errorf("reservation %d: %w", res.ID, err)
+			}
+			err = tx.AddEvent(ctx, Event{
+				ReservationId: res.ID,
+				Type:          EventImported,
+				Before:        replaced[i],
+				After:         &after,
+			})
+			if err != nil {
+				return fmt.Errorf("reservation %d: %w", res.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// prepareImport checks an imported reservation and fills in what other
+// systems may leave out: the status change defaults to the checkout and the
+// version to 1.
+func prepareImport(res *Reservation) error {
+	switch {
+	case res.ID < 1:
+		return errors.New("id must be positive")
+	case res.BookId < 1:
+		return errors.New("book_id must be positive")
+	case res.UserId < 1:
+		return errors.New("user_id must be positive")
+	case res.CheckoutDate.IsZero():
+		return errors.New("checkout_date is required")
+	case res.Version < 0:
+		return errors.New("version must not be negative")
+	}
+	if _, ok := transitions[res.Status]; !ok {
+		return fmt.Errorf("unknown status %q", res.Status)
+	}
+	if !res.Status.terminal() {
+		return fmt.Errorf("status %q is not closed", res.Status)
+	}
+	if res.ReturnDate != nil && res.ReturnDate.Before(res.CheckoutDate) {
+		return errors.New("return_date is before checkout_date")
+	}
+	if res.DueDate != nil && res.DueDate.Before(res.CheckoutDate) {
+		return errors.New("due_date is before checkout_date")
+	}
+	if res.StartDate != nil && res.EndDate != nil && res.EndDate.Before(*res.StartDate) {
+		return errors.New("end_date is before start_date")
+	}
+
+	if res.StatusChangedAt.IsZero() {
+		res.StatusChangedAt = res.CheckoutDate
+	}
+	if res.Version == 0 {
+		res.Version = 1
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package reservation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportFixture() []Reservation {
+	checkout := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	return []Reservation{
+		{ID: 1, BookId: 1, UserId: 1, CheckoutDate: checkout, ReturnDate: ptr(checkout.AddDate(0, 0, 9)), DueDate: ptr(checkout.AddDate(0, 0, 14)),
+			CopyId: ptr(int64(7)), Branch: "main", ReturnBranch: "east", Status: StatusReturned, StatusChangedAt: checkout.AddDate(0, 0, 9), Version: 4},
+		{ID: 2, BookId: 2, UserId: 1, CheckoutDate: checkout, DueDate: ptr(checkout.AddDate(0, 0, 14)), Branch: "main",
+			Status: StatusDamaged, StatusChangedAt: checkout.AddDate(0, 1, 0), StatusNote: "returned with \"coffee\" stains", Version: 2},
+		{ID: 5, BookId: 3, UserId: 2, CheckoutDate: checkout, StartDate: ptr(checkout.AddDate(0, 0, 20)), EndDate: ptr(checkout.AddDate(0, 0, 27)),
+			Branch: "east", Status: StatusCancelled, StatusChangedAt: checkout, Version: 1},
+	}
+}
+
+func export(t *testing.T, reservations []Reservation, format Format) string {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, err := newRecordWriter(&buf, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range reservations {
+		err := writer.Write(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func expectSameReservations(t *testing.T, expected, actual []Reservation) {
+	t.Helper()
+	expectedJSON, _ := json.Marshal(expected)
+	actualJSON, _ := json.Marshal(actual)
+	if !bytes.Equal(expectedJSON, actualJSON) {
+		t.Errorf("Expected reservations %s; got %s", expectedJSON, actualJSON)
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatCSV, FormatNDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			repo := NewMockReservationRepo(nil)
+			report, err := Import(context.Background(), repo, strings.NewReader(export(t, exportFixture(), format)),
+				ImportOptions{Format: format, BatchSize: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Rows != 3 || report.Imported != 3 || len(report.Rejected) != 0 {
+				t.Errorf("Unexpected report %+v", report)
+			}
+			expectSameReservations(t, exportFixture(), repo.reservation)
+			expectImportEvents(t, repo.events, map[int64]*Reservation{1: nil, 2: nil, 5: nil})
+		})
+	}
+}
+
+// expectImportEvents checks that events are one EventImported for each of
+// the reservations in replaced, holding the reservation it replaced.
+func expectImportEvents(t *testing.T, events []Event, replaced map[int64]*Reservation) {
+	t.Helper()
+	if len(events) != len(replaced) {
+		t.Fatalf("Expected %d events; got %+v", len(replaced), events)
+	}
+	for _, event := range events {
+		before, ok := replaced[event.ReservationId]
+		if !ok || event.Type != EventImported || event.After == nil || event.After.ID != event.ReservationId {
+			t.Errorf("Unexpected event %+v", event)
+			continue
+		}
+		expectSameReservations(t, []Reservation{ptrValue(before)}, []Reservation{ptrValue(event.Before)})
+	}
+}
+
+func ptrValue(res *Reservation) Reservation {
+	if res == nil {
+		return Reservation{}
+	}
+	return *res
+}
+
+func TestImportRejects(t *testing.T) {
+	checkout := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	existing := []Reservation{
+		{ID: 1, BookId: 8, UserId: 8, CheckoutDate: checkout, Status: StatusReturned, StatusChangedAt: checkout, Version: 3},
+		{ID: 10, BookId: 9, UserId: 9, CheckoutDate: checkout, Status: StatusActive, StatusChangedAt: checkout, Version: 1},
+	}
+	file := strings.Join([]string{
+		"id,user_id,book_id,checkout_date,status,status_note",
+		"1,1,1,2025-03-01T10:00:00Z,returned,replaces the existing one",
+		"2,1,x,2025-03-01T10:00:00Z,returned,",
+		"3,1,2,2025-03-01T10:00:00Z,mislaid,",
+		"4,1,2,2025-03-01,returned,",
+		"1,1,3,2025-03-01T10:00:00Z,returned,",
+		"5,1,3,2025-03-01T10:00:00Z",
+		"10,9,9,2025-03-01T10:00:00Z,returned,the open 10 is kept",
+		"7,2,2,2025-03-01T10:00:00Z,active,",
+		"8,2,2,2025-03-01T10:00:00Z,cancelled,",
+		"",
+	}, "\n")
+	invalid := []RejectedRow{
+		{Line: 3, Reason: `malformed record: book_id: invalid number "x"`},
+		{Line: 4, ID: 3, Reason: `unknown status "mislaid"`},
+		{Line: 5, Reason: `malformed record: checkout_date: invalid time "2025-03-01"`},
+		{Line: 6, ID: 1, Reason: "id 1 is already on line 2"},
+		{Line: 7, Reason: "malformed record: wrong number of fields"},
+		{Line: 9, ID: 7, Reason: `status "active" is not closed`},
+	}
+	replacement := Reservation{ID: 1, BookId: 1, UserId: 1, CheckoutDate: checkout, Status: StatusReturned, StatusChangedAt: checkout,
+		StatusNote: "replaces the existing one", Version: 4}
+	added := Reservation{ID: 8, BookId: 2, UserId: 2, CheckoutDate: checkout, Status: StatusCancelled, StatusChangedAt: checkout, Version: 1}
+
+	testCases := []struct {
+		testName             string
+		dryRun               bool
+		overwrite            bool
+		expectedImported     int
+		expectedRejected     []RejectedRow
+		expectedReservations []Reservation
+		expectedEvents       map[int64]*Reservation
+	}{
+		{
+			testName:         "Import",
+			overwrite:        true,
+			expectedImported: 2,
+			expectedRejected: append(slices.Clone(invalid),
+				RejectedRow{Line: 8, ID: 10, Reason: "reservation 10: " + ErrReservationOpen.Error()}),
+			expectedReservations: []Reservation{replacement, existing[1], added},
+			expectedEvents:       map[int64]*Reservation{1: &existing[0], 8: nil},
+		},
+		{
+			testName:         "Dry run",
+			dryRun:           true,
+			overwrite:        true,
+			expectedImported: 2,
+			expectedRejected: append(slices.Clone(invalid),
+				RejectedRow{Line: 8, ID: 10, Reason: "reservation 10: " + ErrReservationOpen.Error()}),
+			expectedReservations: existing,
+			expectedEvents:       map[int64]*Reservation{},
+		},
+		{
+			testName:         "Without overwrite",
+			expectedImported: 1,
+			expectedRejected: append(slices.Clone(invalid),
+				RejectedRow{Line: 2, ID: 1, Reason: "reservation 1: " + ErrReservationExists.Error()},
+				RejectedRow{Line: 8, ID: 10, Reason: "reservation 10: " + ErrReservationExists.Error()}),
+			expectedReservations: []Reservation{existing[0], existing[1], added},
+			expectedEvents:       map[int64]*Reservation{8: nil},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := NewMockReservationRepo(slices.Clone(existing))
+			report, err := Import(context.Background(), repo, strings.NewReader(file),
+				ImportOptions{Format: FormatCSV, BatchSize: 3, DryRun: tc.dryRun, Overwrite: tc.overwrite})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if report.DryRun != tc.dryRun || report.Rows != 9 || report.Imported != tc.expectedImported {
+				t.Errorf("Unexpected report %+v", report)
+			}
+			if len(report.Rejected) != len(tc.expectedRejected) {
+				t.Fatalf("Expected rejected rows %+v; got %+v", tc.expectedRejected, report.Rejected)
+			}
+			for i, row := range report.Rejected {
+				if row != tc.expectedRejected[i] {
+					t.Errorf("Expected rejected row %+v; got %+v", tc.expectedRejected[i], row)
+				}
+			}
+			expectSameReservations(t, tc.expectedReservations, repo.reservation)
+			expectImportEvents(t, repo.events, tc.expectedEvents)
+		})
+	}
+}
+
+// TestImportDryRunSeesEarlierBatches checks that a dry run reports the rows
+// the database refuses because of rows in earlier batches.
+func TestImportDryRunSeesEarlierBatches(t *testing.T) {
+	file := `{"id": 1, "book_id": 1, "user_id": 1, "checkout_date": "2025-03-01T10:00:00Z", "status": "returned"}
+{"id": 2, "book_id": 1, "user_id": 1, "checkout_date": "2025-03-01T10:00:00Z", "status": "returned"}
+`
+	repo := &batchConflictRepo{MockReservationRepo: NewMockReservationRepo(nil)}
+	report, err := Import(context.Background(), repo, strings.NewReader(file), ImportOptions{Format: FormatNDJSON, BatchSize: 1, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 || len(report.Rejected) != 1 || report.Rejected[0].Line != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(repo.reservation) != 0 || len(repo.events) != 0 {
+		t.Errorf("Expected the dry run to change nothing; got %+v and %+v", repo.reservation, repo.events)
+	}
+}
+
+// batchConflictRepo refuses to import a reservation of a patron and book it
+// already holds, like a unique index would.
+type batchConflictRepo struct {
+	*MockReservationRepo
+}
+
+func (r *batchConflictRepo) Import(ctx context.Context, reservations []Reservation, overwrite bool) ([]*Reservation, error) {
+	for _, res := range reservations {
+		for _, existing := range r.reservation {
+			if existing.UserId == res.UserId && existing.BookId == res.BookId {
+				return nil, errors.New("duplicate patron and book")
+			}
+		}
+	}
+	return r.MockReservationRepo.Import(ctx, reservations, overwrite)
+}
+
+func (r *batchConflictRepo) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return r.MockReservationRepo.WithTx(ctx, func(Repository) error { return fn(r) })
+}
+
+func TestImportUnreadableFile(t *testing.T) {
+	testCases := []struct {
+		testName string
+		format   Format
+		file     string
+	}{
+		{"Unknown format", "xml", ""},
+		{"Empty CSV", FormatCSV, ""},
+		{"Unknown column", FormatCSV, "id,book_id,user_id,checkout_date,status,fine\n"},
+		{"Missing column", FormatCSV, "id,book_id,checkout_date,status\n"},
+		{"Line too long", FormatNDJSON, `{"id": 1, "status_note": "` + strings.Repeat("a", maxNDJSONLine) + `"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := NewMockReservationRepo(nil)
+			_, err := Import(context.Background(), repo, strings.NewReader(tc.file), ImportOptions{Format: tc.format})
+			if err == nil || errors.Is(err, ErrMalformedRecord) {
+				t.Errorf("Expected the import to fail; got %v", err)
+			}
+			if len(repo.reservation) != 0 {
+				t.Errorf("Expected no reservations; got %+v", repo.reservation)
+			}
+		})
+	}
+}
+
+func TestImportNDJSON(t *testing.T) {
+	file := `{"id": 1, "book_id": 1, "user_id": 1, "checkout_date": "2025-03-01T10:00:00Z", "status": "returned"}
+
+{"id": 2, "book_id": 1, "user_id": 1, "checkout_date": "2025-03-01T10:00:00Z", "status": "returned", "fine": 5}
+{"id": 3, "book_id": 1, "user_id": 1, "checkout_date": "2025-03-01T10:00:00Z", "return_date": "2025-02-01T10:00:00Z", "status": "returned"}
+`
+	repo := NewMockReservationRepo(nil)
+	report, err := Import(context.Background(), repo, strings.NewReader(file), ImportOptions{Format: FormatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedRejected := []RejectedRow{
+		{Line: 3, Reason: `malformed record: json: unknown field "fine"`},
+		{Line: 4, ID: 3, Reason: "return_date is before checkout_date"},
+	}
+	if report.Rows != 3 || report.Imported != 1 || len(report.Rejected) != 2 ||
+		report.Rejected[0] != expectedRejected[0] || report.Rejected[1] != expectedRejected[1] {
+		t.Errorf("Unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,36 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+)
+
+type MockBookClient struct {
+	books []Book
+}
+
+func NewMockBookClient(books []Book) *MockBookClient {
+	return &MockBookClient{books: books}
+}
+
+func (c *MockBookClient) GetBook(ctx context.Context, bookID int64) (Book, error) {
+	for _, b := range c.books {
+		if b.ID == bookID {
+			return b, nil
+		}
+	}
+	return Book{}, errors.New("simulated error fetching book by id")
+}
+
+func (c *MockBookClient) AdjustAvailableCopies(ctx context.Context, bookID, delta int64) (int64, error) {
+	for i := range c.books {
+		if c.books[i].ID == bookID {
+			if c.books[i].AvailableCopies+delta < 0 {
+				return 0, ErrNoCopiesLeft
+			}
+			c.books[i].AvailableCopies += delta
+			return c.books[i].AvailableCopies, nil
+		}
+	}
+	return 0, errors.New("simulated error updating available copies")
+}
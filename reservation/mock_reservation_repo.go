@@ -1,27 +1,65 @@
 package reservation
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 
+	"github.com/shkuran/go-library-microservices/reservation-service/notifier"
 )
 
 type MockReservationRepo struct {
 	reservation []Reservation
+	events      []Event
+	charges     []Charge
+	notified    map[int64][]sentNotification
+	copies      []Copy
+}
+
+// sentNotification is a notification sent through a channel.
+type sentNotification struct {
+	kind    notifier.Kind
+	dueDate time.Time
+	channel string
 }
 
 func NewMockReservationRepo(res []Reservation) *MockReservationRepo {
 	return &MockReservationRepo{reservation: res}
 }
 
-func (r *MockReservationRepo) GetAll() ([]Reservation, error) {
+func (r *MockReservationRepo) GetAll(ctx context.Context, filter Filter) ([]Reservation, error) {
 	if len(r.reservation) == 0 {
 		return nil, errors.New("simulated error fetching reservations")
 	}
-	return r.reservation, nil
+	if filter == (Filter{}) {
+		return r.reservation, nil
+	}
+
+	var reservations []Reservation
+	for _, res := range r.reservation {
+		if filter.Matches(res) {
+			reservations = append(reservations, res)
+		}
+	}
+	return reservations, nil
 }
 
-func (r *MockReservationRepo) GetById(id int64) (Reservation, error) {
+func (r *MockReservationRepo) Each(ctx context.Context, filter Filter, fn func(Reservation) error) error {
+	for _, res := range r.reservation {
+		if !filter.Matches(res) {
+			continue
+		}
+		err := fn(res)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MockReservationRepo) GetById(ctx context.Context, id int64) (Reservation, error) {
 	for _, res := range r.reservation {
 		if res.ID == id {
 			return res, nil
@@ -30,19 +68,377 @@ func (r *MockReservationRepo) GetById(id int64) (Reservation, error) {
 	return Reservation{}, errors.New("simulated error fetching reservation by id")
 }
 
-func (r *MockReservationRepo) Save(res Reservation) error {
+func (r *MockReservationRepo) Save(ctx context.Context, res Reservation) (int64, error) {
+	if r.alreadyReserved(res) {
+		return 0, ErrAlreadyReserved
+	}
 	res.ID = int64(len(r.reservation)) + 1
+	res.Version = 1
+	if res.Status == "" {
+		res.Status = StatusRequested
+	}
 	r.reservation = append(r.reservation, res)
-	return nil
+	return res.ID, nil
+}
+
+// alreadyReserved mirrors the unique index on the open reservations of a
+// patron and book.
+func (r *MockReservationRepo) alreadyReserved(res Reservation) bool {
+	open := func(res Reservation) bool {
+		return res.ReturnDate == nil &&
+			(res.Status == StatusBooked || res.Status == StatusRequested || res.Status == StatusActive || res.Status == StatusOverdue)
+	}
+	// Save leaves the status to the repository, which requests.
+	if res.Status != "" && !open(res) {
+		return false
+	}
+	for _, existing := range r.reservation {
+		if existing.ID != res.ID && existing.UserId == res.UserId && existing.BookId == res.BookId && open(existing) {
+			return true
+		}
+	}
+	return false
 }
 
-func (r *MockReservationRepo) UpdateReturnDate(id int64) error {
+func (r *MockReservationRepo) Import(ctx context.Context, reservations []Reservation, overwrite bool) ([]*Reservation, error) {
+	replaced := make([]*Reservation, len(reservations))
+	for n, res := range reservations {
+		i := slices.IndexFunc(r.reservation, func(existing Reservation) bool { return existing.ID == res.ID })
+		if i < 0 {
+			r.reservation = append(r.reservation, res)
+			continue
+		}
+		existing := r.reservation[i]
+		if !overwrite {
+			return nil, fmt.Errorf("reservation %d: %w", res.ID, ErrReservationExists)
+		}
+		if !existing.Status.terminal() {
+			return nil, fmt.Errorf("reservation %d: %w", res.ID, ErrReservationOpen)
+		}
+		replaced[n] = &existing
+		res.Version = existing.Version + 1
+		r.reservation[i] = res
+	}
+	return replaced, nil
+}
+
+func (r *MockReservationRepo) IncrementVersion(ctx context.Context, id, version int64) (int64, error) {
+	for i := range r.reservation {
+		if r.reservation[i].ID == id {
+			if r.reservation[i].Version != version {
+				return 0, nil
+			}
+			r.reservation[i].Version++
+			return 1, nil
+		}
+	}
+	return 0, errors.New("simulated error updating Version")
+}
+
+func (r *MockReservationRepo) UpdateReturnDate(ctx context.Context, id int64) (int64, error) {
 	returnDate := time.Now()
 	for i := range r.reservation {
 		if r.reservation[i].ID == id {
+			if r.reservation[i].ReturnDate != nil {
+				return 0, nil
+			}
 			r.reservation[i].ReturnDate = &returnDate
+			r.reservation[i].Status = StatusReturned
+			r.reservation[i].StatusChangedAt = returnDate
+			return 1, nil
+		}
+	}
+	return 0, errors.New("simulated error updating ReturnDate")
+}
+
+func (r *MockReservationRepo) UpdateStatus(ctx context.Context, id int64, from, to Status) error {
+	err := ValidateTransition(from, to)
+	if err != nil {
+		return err
+	}
+	for i := range r.reservation {
+		if r.reservation[i].ID == id {
+			if r.reservation[i].Status != from {
+				return ErrInvalidTransition
+			}
+			r.reservation[i].Status = to
+			r.reservation[i].StatusChangedAt = time.Now()
 			return nil
 		}
 	}
-	return errors.New("simulated error updating ReturnDate")
+	return errors.New("simulated error updating status")
+}
+
+func (r *MockReservationRepo) MarkInTransit(ctx context.Context, id int64, from Status, returnBranch string) error {
+	err := r.UpdateStatus(ctx, id, from, StatusInTransit)
+	if err != nil {
+		return err
+	}
+	for i := range r.reservation {
+		if r.reservation[i].ID == id {
+			returnDate := r.reservation[i].StatusChangedAt
+			r.reservation[i].ReturnDate = &returnDate
+			r.reservation[i].ReturnBranch = returnBranch
+		}
+	}
+	return nil
+}
+
+func (r *MockReservationRepo) Renew(ctx context.Context, id int64, dueDate, newDueDate time.Time) error {
+	for i := range r.reservation {
+		if r.reservation[i].ID == id {
+			res := r.reservation[i]
+			if res.Status != StatusActive || res.DueDate == nil || !res.DueDate.Equal(dueDate) {
+				return ErrInvalidTransition
+			}
+			r.reservation[i].DueDate = &newDueDate
+			return nil
+		}
+	}
+	return errors.New("simulated error renewing reservation")
+}
+
+func (r *MockReservationRepo) CountOpenByUser(ctx context.Context, userId int64, branch string) (int64, error) {
+	var open int64
+	for _, res := range r.reservation {
+		if res.UserId == userId && res.Branch == branch && (res.Status.IsOpen() || res.Status == StatusBooked) {
+			open++
+		}
+	}
+	return open, nil
+}
+
+func (r *MockReservationRepo) Activate(ctx context.Context, id int64, copyId *int64, dueDate time.Time) error {
+	err := r.UpdateStatus(ctx, id, StatusRequested, StatusActive)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range r.reservation {
+		if r.reservation[i].ID == id {
+			r.reservation[i].DueDate = &dueDate
+			r.reservation[i].PickedUpAt = &now
+			r.reservation[i].CopyId = copyId
+		}
+	}
+	return nil
+}
+
+func (r *MockReservationRepo) StartBooking(ctx context.Context, id int64) error {
+	err := r.UpdateStatus(ctx, id, StatusBooked, StatusRequested)
+	if err != nil {
+		return err
+	}
+	for i := range r.reservation {
+		if r.reservation[i].ID == id {
+			r.reservation[i].CheckoutDate = time.Now()
+		}
+	}
+	return nil
+}
+
+func (r *MockReservationRepo) GetDueBookings(ctx context.Context, now time.Time) ([]Reservation, error) {
+	var bookings []Reservation
+	for _, res := range r.reservation {
+		if res.Status == StatusBooked && res.StartDate != nil && !res.StartDate.After(now) {
+			bookings = append(bookings, res)
+		}
+	}
+	return bookings, nil
+}
+
+func (r *MockReservationRepo) CountHeld(ctx context.Context, bookId int64) (int64, error) {
+	var held int64
+	for _, res := range r.reservation {
+		if res.BookId == bookId && res.Status.IsOpen() {
+			held++
+		}
+	}
+	return held, nil
+}
+
+func (r *MockReservationRepo) CountOverlapping(ctx context.Context, bookId int64, start, end time.Time) (int64, error) {
+	var overlapping int64
+	for _, res := range r.reservation {
+		if res.BookId != bookId || res.Status == StatusInTransit || (res.Status != StatusBooked && !res.Status.IsOpen()) {
+			continue
+		}
+
+		from := res.CheckoutDate
+		if res.StartDate != nil {
+			from = *res.StartDate
+		}
+		// A booking picked up is due when its loan is, renewals included.
+		until := res.DueDate
+		if until == nil {
+			until = res.EndDate
+		}
+
+		if from.Before(end) && (res.Status == StatusOverdue || until == nil || until.After(start)) {
+			overlapping++
+		}
+	}
+	return overlapping, nil
+}
+
+func (r *MockReservationRepo) GetOpenLoans(ctx context.Context) ([]Reservation, error) {
+	var loans []Reservation
+	for _, res := range r.reservation {
+		if res.Status == StatusActive || res.Status == StatusOverdue {
+			loans = append(loans, res)
+		}
+	}
+	return loans, nil
+}
+
+func (r *MockReservationRepo) WasNotified(ctx context.Context, n notifier.Notification, channel string) (bool, error) {
+	for _, sent := range r.notified[n.ReservationId] {
+		if sent.kind == n.Kind && sent.dueDate.Equal(n.DueDate) && sent.channel == channel {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MockReservationRepo) RecordNotification(ctx context.Context, n notifier.Notification, channel string) error {
+	if r.notified == nil {
+		r.notified = make(map[int64][]sentNotification)
+	}
+	r.notified[n.ReservationId] = append(r.notified[n.ReservationId], sentNotification{n.Kind, n.DueDate, channel})
+	return nil
+}
+
+func (r *MockReservationRepo) MarkLost(ctx context.Context, id int64, from, to Status, note string) error {
+	err := r.UpdateStatus(ctx, id, from, to)
+	if err != nil {
+		return err
+	}
+	for i := range r.reservation {
+		if r.reservation[i].ID == id {
+			r.reservation[i].StatusNote = note
+		}
+	}
+	return nil
+}
+
+func (r *MockReservationRepo) AddCharge(ctx context.Context, charge Charge) (int64, error) {
+	charge.ID = int64(len(r.charges)) + 1
+	charge.CreatedAt = time.Now()
+	r.charges = append(r.charges, charge)
+	return charge.ID, nil
+}
+
+func (r *MockReservationRepo) AddEvent(ctx context.Context, event Event) error {
+	event.ID = int64(len(r.events)) + 1
+	event.CreatedAt = time.Now()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *MockReservationRepo) GetEvents(ctx context.Context, reservationId int64) ([]Event, error) {
+	var events []Event
+	for _, event := range r.events {
+		if event.ReservationId == reservationId {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *MockReservationRepo) CountEvents(ctx context.Context, reservationId int64, eventType EventType) (int64, error) {
+	var count int64
+	for _, event := range r.events {
+		if event.ReservationId == reservationId && event.Type == eventType {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MockReservationRepo) AddCopy(ctx context.Context, c Copy) (int64, error) {
+	for _, existing := range r.copies {
+		if existing.Barcode == c.Barcode {
+			return 0, errors.New("simulated duplicate barcode")
+		}
+	}
+	c.ID = int64(len(r.copies)) + 1
+	c.CreatedAt = time.Now()
+	r.copies = append(r.copies, c)
+	return c.ID, nil
+}
+
+func (r *MockReservationRepo) onLoan(c Copy) bool {
+	for _, res := range r.reservation {
+		if res.CopyId != nil && *res.CopyId == c.ID && (res.Status == StatusActive || res.Status == StatusOverdue || res.Status == StatusInTransit) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MockReservationRepo) GetCopies(ctx context.Context, bookId int64) ([]Copy, error) {
+	var copies []Copy
+	for _, c := range r.copies {
+		if c.BookId == bookId {
+			c.OnLoan = r.onLoan(c)
+			copies = append(copies, c)
+		}
+	}
+	return copies, nil
+}
+
+func (r *MockReservationRepo) GetCopyByBarcode(ctx context.Context, barcode string) (Copy, error) {
+	for _, c := range r.copies {
+		if c.Barcode == barcode {
+			c.OnLoan = r.onLoan(c)
+			return c, nil
+		}
+	}
+	return Copy{}, ErrCopyNotFound
+}
+
+func (r *MockReservationRepo) SetCopyCondition(ctx context.Context, id int64, condition CopyCondition) error {
+	for i := range r.copies {
+		if r.copies[i].ID == id {
+			r.copies[i].Condition = condition
+			return nil
+		}
+	}
+	return ErrCopyNotFound
+}
+
+func (r *MockReservationRepo) CountAvailableCopies(ctx context.Context, bookId int64) (int64, bool, error) {
+	var total, lendable int64
+	for _, c := range r.copies {
+		if c.BookId == bookId {
+			total++
+			if c.Condition.IsLendable() {
+				lendable++
+			}
+		}
+	}
+	held, err := r.CountHeld(ctx, bookId)
+	if err != nil {
+		return 0, false, err
+	}
+	return max(lendable-held, 0), total > 0, nil
+}
+
+// WithTx snapshots the mock's state and restores it when fn fails, so tests
+// can check that multi-step changes are rolled back.
+func (r *MockReservationRepo) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	reservations := slices.Clone(r.reservation)
+	events := slices.Clone(r.events)
+	charges := slices.Clone(r.charges)
+	copies := slices.Clone(r.copies)
+	notified := make(map[int64][]sentNotification, len(r.notified))
+	for id, sent := range r.notified {
+		notified[id] = slices.Clone(sent)
+	}
+
+	err := fn(r)
+	if err != nil {
+		r.reservation, r.events, r.charges, r.copies, r.notified = reservations, events, charges, copies, notified
+	}
+	return err
 }
@@ -3,9 +3,20 @@ package reservation
 import "time"
 
 type Reservation struct {
-	ID           int64      `json:"id" db:"id"`
-	BookId       int64      `json:"book_id" db:"book_id"`
-	UserId       int64      `json:"user_id" db:"user_id"`
-	CheckoutDate time.Time  `json:"checkout_date" db:"checkout_date"`
-	ReturnDate   *time.Time `json:"return_date" db:"return_date"`
+	ID              int64      `json:"id" db:"id"`
+	BookId          int64      `json:"book_id" db:"book_id"`
+	UserId          int64      `json:"user_id" db:"user_id"`
+	CheckoutDate    time.Time  `json:"checkout_date" db:"checkout_date"`
+	ReturnDate      *time.Time `json:"return_date" db:"return_date"`
+	DueDate         *time.Time `json:"due_date" db:"due_date"`
+	PickedUpAt      *time.Time `json:"picked_up_at" db:"picked_up_at"`
+	StartDate       *time.Time `json:"start_date" db:"start_date"`
+	EndDate         *time.Time `json:"end_date" db:"end_date"`
+	CopyId          *int64     `json:"copy_id" db:"copy_id"`
+	Branch          string     `json:"branch" db:"branch"`
+	ReturnBranch    string     `json:"return_branch,omitempty" db:"return_branch"`
+	Status          Status     `json:"status" db:"status"`
+	StatusChangedAt time.Time  `json:"status_changed_at" db:"status_changed_at"`
+	StatusNote      string     `json:"status_note,omitempty" db:"status_note"`
+	Version         int64      `json:"version" db:"version"`
 }
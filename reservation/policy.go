@@ -0,0 +1,44 @@
+package reservation
+
+import "time"
+
+// Policy holds the circulation rules the handler enforces.
+type Policy struct {
+	// CancelWindow is how long after checkout a requested reservation can
+	// still be cancelled by the patron.
+	CancelWindow time.Duration
+	// LoanPeriod is added to the pickup time to get the due date.
+	LoanPeriod time.Duration
+	// DefaultBranch is used for reservations that do not name a branch.
+	DefaultBranch string
+	// MaxRenewals caps how often a loan can be renewed; 0 means no limit.
+	MaxRenewals int64
+	// Branches holds per-branch rules. When it is not empty, reservations
+	// can only be made at the listed branches.
+	Branches map[string]BranchRules
+}
+
+// BranchRules override the general policy at one branch. Zero fields fall
+// back to the general policy or mean no limit.
+type BranchRules struct {
+	LoanPeriod time.Duration
+	// MaxLoans caps the open reservations a patron may hold at the branch.
+	MaxLoans int64
+}
+
+// knowsBranch reports whether reservations can be made at branch.
+func (p Policy) knowsBranch(branch string) bool {
+	if len(p.Branches) == 0 {
+		return true
+	}
+	_, ok := p.Branches[branch]
+	return ok
+}
+
+// loanPeriod returns the loan period for loans picked up at branch.
+func (p Policy) loanPeriod(branch string) time.Duration {
+	if rules, ok := p.Branches[branch]; ok && rules.LoanPeriod > 0 {
+		return rules.LoanPeriod
+	}
+	return p.LoanPeriod
+}
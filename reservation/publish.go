@@ -0,0 +1,58 @@
+package reservation
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	"github.com/shkuran/go-library-microservices/reservation-service/logger"
+)
+
+// domainEvents maps history entries to the events other services consume.
+// Staff overrides currently only close loans as lost or damaged; damaged
+// loans are told apart by their status, see domainEvent. A return at another
+// branch completes the loan for the patron; the copy arriving back home is
+// reported separately.
+var domainEvents = map[EventType]events.Type{
+	EventCreated:    events.ReservationCreated,
+	EventStarted:    events.ReservationStarted,
+	EventPickedUp:   events.ReservationPickedUp,
+	EventCompleted:  events.ReservationCompleted,
+	EventInTransit:  events.ReservationCompleted,
+	EventReceived:   events.ReservationReceived,
+	EventRenewed:    events.ReservationRenewed,
+	EventCancelled:  events.ReservationCancelled,
+	EventOverdue:    events.ReservationOverdue,
+	EventOverridden: events.ReservationLost,
+}
+
+// publish emits the domain event for a history entry with the reservation
+// snapshot as payload. Publishing is best effort: the change is already
+// stored, so failures are only logged.
+func publish(ctx context.Context, publisher events.Publisher, eventType EventType, res Reservation) {
+	domainType, ok := domainEvent(eventType, res)
+	if !ok {
+		return
+	}
+
+	event, err := events.New(domainType, strconv.FormatInt(res.ID, 10), res)
+	if err == nil {
+		err = publisher.Publish(ctx, event)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Could not publish reservation event!",
+			"error", err,
+			"reservation_id", res.ID,
+			"event_type", domainType,
+		)
+	}
+}
+
+// domainEvent returns the domain event for a history entry, if there is one.
+func domainEvent(eventType EventType, res Reservation) (events.Type, bool) {
+	if eventType == EventOverridden && res.Status == StatusDamaged {
+		return events.ReservationDamaged, true
+	}
+	domainType, ok := domainEvents[eventType]
+	return domainType, ok
+}
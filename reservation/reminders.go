@@ -0,0 +1,152 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	"github.com/shkuran/go-library-microservices/reservation-service/notifier"
+)
+
+// ReminderScheduler scans open loans once a day, moves loans past their due
+// date to StatusOverdue and sends "due soon" and "overdue" notifications.
+// Every notification is sent through each channel at most once per loan, kind
+// and due date, so a renewed loan is reminded again.
+type ReminderScheduler struct {
+	repo      Repository
+	channels  notifier.Multi
+	publisher events.Publisher
+	dueSoon   time.Duration
+	runAt     time.Duration
+}
+
+// NewReminderScheduler creates a scheduler that runs every day at runAt
+// (offset from local midnight) and warns about loans due within dueSoon.
+func NewReminderScheduler(repo Repository, channels notifier.Multi, publisher events.Publisher, dueSoon, runAt time.Duration) *ReminderScheduler {
+	return &ReminderScheduler{repo: repo, channels: channels, publisher: publisher, dueSoon: dueSoon, runAt: runAt}
+}
+
+// Start blocks and runs a scan every day until ctx is cancelled.
+func (s *ReminderScheduler) Start(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(s.nextRun(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			err := s.Run(ctx, now)
+			if err != nil {
+				slog.Error("Reminder run finished with errors!", "error", err)
+			}
+		}
+	}
+}
+
+func (s *ReminderScheduler) nextRun(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := midnight.Add(s.runAt)
+	if !next.After(now) {
+		next = midnight.AddDate(0, 0, 1).Add(s.runAt)
+	}
+	return next
+}
+
+// Run performs a single scan as of now. Failures for one loan do not stop
+// the others; they are returned joined together.
+func (s *ReminderScheduler) Run(ctx context.Context, now time.Time) error {
+	loans, err := s.repo.GetOpenLoans(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, loan := range loans {
+		if loan.DueDate == nil {
+			continue
+		}
+
+		err := s.process(ctx, loan, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reservation %d: %w", loan.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *ReminderScheduler) process(ctx context.Context, loan Reservation, now time.Time) error {
+	due := *loan.DueDate
+
+	switch {
+	case now.After(due):
+		if loan.Status == StatusActive {
+			err := s.markOverdue(ctx, loan)
+			if err != nil {
+				return err
+			}
+		}
+		return s.notifyOnce(ctx, loan, notifier.KindOverdue)
+	case due.Sub(now) <= s.dueSoon:
+		return s.notifyOnce(ctx, loan, notifier.KindDueSoon)
+	default:
+		return nil
+	}
+}
+
+func (s *ReminderScheduler) markOverdue(ctx context.Context, loan Reservation) error {
+	var after Reservation
+	err := s.repo.WithTx(ctx, func(tx Repository) error {
+		err := tx.UpdateStatus(ctx, loan.ID, StatusActive, StatusOverdue)
+		if err != nil {
+			return err
+		}
+		after, err = recordEvent(ctx, tx, EventOverdue, 0, &loan, loan.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	publish(ctx, s.publisher, EventOverdue, after)
+	return nil
+}
+
+// notifyOnce sends the notification through the channels that have not
+// sent it yet. A channel that fails is retried on the next run.
+func (s *ReminderScheduler) notifyOnce(ctx context.Context, loan Reservation, kind notifier.Kind) error {
+	n := notifier.Notification{
+		Kind:          kind,
+		ReservationId: loan.ID,
+		UserId:        loan.UserId,
+		BookId:        loan.BookId,
+		DueDate:       *loan.DueDate,
+	}
+
+	var errs []error
+	for _, channel := range s.channels {
+		sent, err := s.repo.WasNotified(ctx, n, channel.Name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if sent {
+			continue
+		}
+
+		err = channel.Notify(ctx, n)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name, err))
+			continue
+		}
+
+		err = s.repo.RecordNotification(ctx, n, channel.Name)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,130 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	"github.com/shkuran/go-library-microservices/reservation-service/notifier"
+)
+
+type recordingNotifier struct {
+	sent []notifier.Notification
+	// err fails every notification while set.
+	err error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification notifier.Notification) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func TestReminderScheduler(t *testing.T) {
+	now := time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC)
+	dueTomorrow := now.Add(24 * time.Hour)
+	dueNextWeek := now.Add(7 * 24 * time.Hour)
+	dueYesterday := now.Add(-24 * time.Hour)
+
+	repo := NewMockReservationRepo([]Reservation{
+		{ID: 1, BookId: 1, UserId: 1, Status: StatusActive, DueDate: &dueTomorrow},
+		{ID: 2, BookId: 2, UserId: 2, Status: StatusActive, DueDate: &dueNextWeek},
+		{ID: 3, BookId: 3, UserId: 3, Status: StatusActive, DueDate: &dueYesterday},
+		{ID: 4, BookId: 4, UserId: 4, Status: StatusLost, DueDate: &dueYesterday},
+	})
+	n := &recordingNotifier{}
+	publisher := events.NewMemoryPublisher()
+	scheduler := NewReminderScheduler(repo, notifier.Multi{{Name: "log", Notifier: n}}, publisher, 48*time.Hour, 2*time.Hour)
+
+	// Running twice must not send duplicates
+	for i := 0; i < 2; i++ {
+		err := scheduler.Run(context.Background(), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[int64]notifier.Kind{1: notifier.KindDueSoon, 3: notifier.KindOverdue}
+	if len(n.sent) != len(expected) {
+		t.Fatalf("Expected %d notifications; got %+v", len(expected), n.sent)
+	}
+	for _, notification := range n.sent {
+		if expected[notification.ReservationId] != notification.Kind {
+			t.Errorf("Unexpected notification %+v", notification)
+		}
+	}
+
+	overdue, err := repo.GetById(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overdue.Status != StatusOverdue {
+		t.Errorf("Expected status %s; got %s", StatusOverdue, overdue.Status)
+	}
+
+	published := publisher.Events()
+	if len(published) != 1 || published[0].Type != events.ReservationOverdue || published[0].Key != "3" {
+		t.Errorf("Expected one ReservationOverdue event for reservation 3; got %+v", published)
+	}
+}
+
+func TestReminderSchedulerChannelsAndRenewals(t *testing.T) {
+	now := time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC)
+	due := now.Add(24 * time.Hour)
+	repo := NewMockReservationRepo([]Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusActive, DueDate: &due}})
+	log, mail := &recordingNotifier{}, &recordingNotifier{err: errors.New("simulated mail failure")}
+	scheduler := NewReminderScheduler(repo, notifier.Multi{{Name: "log", Notifier: log}, {Name: "smtp", Notifier: mail}},
+		events.NewMemoryPublisher(), 48*time.Hour, 2*time.Hour)
+
+	// The failed channel is retried without repeating the other one.
+	err := scheduler.Run(context.Background(), now)
+	if err == nil {
+		t.Fatal("Expected the mail failure")
+	}
+	mail.err = nil
+	err = scheduler.Run(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.sent) != 1 || len(mail.sent) != 1 {
+		t.Fatalf("Expected one notification per channel; got %+v and %+v", log.sent, mail.sent)
+	}
+
+	// A renewed loan is reminded again before its new due date.
+	renewed := due.Add(14 * 24 * time.Hour)
+	err = repo.Renew(context.Background(), 1, due, renewed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = scheduler.Run(context.Background(), renewed.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.sent) != 2 || len(mail.sent) != 2 || !log.sent[1].DueDate.Equal(renewed) {
+		t.Errorf("Expected a second notification per channel for the new due date; got %+v and %+v", log.sent, mail.sent)
+	}
+}
+
+func TestReminderSchedulerNextRun(t *testing.T) {
+	scheduler := NewReminderScheduler(nil, nil, nil, 0, 2*time.Hour)
+
+	testCases := []struct {
+		now      time.Time
+		expected time.Time
+	}{
+		{now: time.Date(2024, 3, 10, 1, 0, 0, 0, time.UTC), expected: time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC)},
+		{now: time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC), expected: time.Date(2024, 3, 11, 2, 0, 0, 0, time.UTC)},
+		{now: time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC), expected: time.Date(2024, 3, 11, 2, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		got := scheduler.nextRun(tc.now)
+		if !got.Equal(tc.expected) {
+			t.Errorf("nextRun(%v): expected %v; got %v", tc.now, tc.expected, got)
+		}
+	}
+}
@@ -1,39 +1,228 @@
 package reservation
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+	"github.com/shkuran/go-library-microservices/reservation-service/db"
+	"github.com/shkuran/go-library-microservices/reservation-service/notifier"
+	"github.com/shkuran/go-library-microservices/reservation-service/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = otel.Tracer("github.com/shkuran/go-library-microservices/reservation-service/reservation")
+
 type Repository interface {
-	GetAll() ([]Reservation, error)
-	GetById(id int64) (Reservation, error)
-	Save(res Reservation) error
-	UpdateReturnDate(id int64) error
+	GetAll(ctx context.Context, filter Filter) ([]Reservation, error)
+	// Each calls fn with the reservations passing filter one at a time, in
+	// ID order, and stops at the first error fn returns.
+	Each(ctx context.Context, filter Filter, fn func(Reservation) error) error
+	GetById(ctx context.Context, id int64) (Reservation, error)
+	Save(ctx context.Context, res Reservation) (int64, error)
+	// Import inserts the reservations under their own IDs and returns the
+	// reservation each of them replaced, or nil. A reservation whose ID is
+	// taken fails with ErrReservationExists unless overwrite is set, and
+	// with ErrReservationOpen when the existing one is not closed.
+	Import(ctx context.Context, reservations []Reservation, overwrite bool) ([]*Reservation, error)
+	UpdateReturnDate(ctx context.Context, id int64) (int64, error)
+	IncrementVersion(ctx context.Context, id, version int64) (int64, error)
+	UpdateStatus(ctx context.Context, id int64, from, to Status) error
+	MarkInTransit(ctx context.Context, id int64, from Status, returnBranch string) error
+	Renew(ctx context.Context, id int64, dueDate, newDueDate time.Time) error
+	CountOpenByUser(ctx context.Context, userId int64, branch string) (int64, error)
+	Activate(ctx context.Context, id int64, copyId *int64, dueDate time.Time) error
+	StartBooking(ctx context.Context, id int64) error
+	GetDueBookings(ctx context.Context, now time.Time) ([]Reservation, error)
+	CountHeld(ctx context.Context, bookId int64) (int64, error)
+	CountOverlapping(ctx context.Context, bookId int64, start, end time.Time) (int64, error)
+	GetOpenLoans(ctx context.Context) ([]Reservation, error)
+	// WasNotified reports whether the notification was sent through the
+	// channel for the loan's current due date.
+	WasNotified(ctx context.Context, n notifier.Notification, channel string) (bool, error)
+	RecordNotification(ctx context.Context, n notifier.Notification, channel string) error
+	MarkLost(ctx context.Context, id int64, from, to Status, note string) error
+	AddCharge(ctx context.Context, charge Charge) (int64, error)
+	AddEvent(ctx context.Context, event Event) error
+	GetEvents(ctx context.Context, reservationId int64) ([]Event, error)
+	CountEvents(ctx context.Context, reservationId int64, eventType EventType) (int64, error)
+	AddCopy(ctx context.Context, c Copy) (int64, error)
+	GetCopies(ctx context.Context, bookId int64) ([]Copy, error)
+	GetCopyByBarcode(ctx context.Context, barcode string) (Copy, error)
+	SetCopyCondition(ctx context.Context, id int64, condition CopyCondition) error
+	CountAvailableCopies(ctx context.Context, bookId int64) (available int64, tracked bool, err error)
+	// WithTx runs fn with a Repository whose changes are committed together
+	// when fn returns nil and rolled back otherwise. Calling WithTx on the
+	// Repository passed to fn runs a nested transaction inside the running
+	// one.
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
+}
+
+// ErrAlreadyReserved is returned by Save when the patron already holds an
+// open reservation for the book.
+var ErrAlreadyReserved = errors.New("user already holds an open reservation for the book")
+
+// ErrReservationExists is returned by Import for a reservation whose ID is
+// taken when it is not told to overwrite.
+var ErrReservationExists = errors.New("reservation already exists")
+
+// ErrReservationOpen is returned by Import for an existing reservation that
+// is not closed: replacing it would leave its copy unaccounted for.
+var ErrReservationOpen = errors.New("an open reservation cannot be replaced")
+
+// openPerUserBookIndex enforces ErrAlreadyReserved in the database. SQLite
+// reports the indexed columns instead of the index name.
+const (
+	openPerUserBookIndex   = "reservations_open_per_user_book_idx"
+	openPerUserBookColumns = "reservations.user_id, reservations.book_id"
+)
+
+// uniqueViolation is the Postgres error code for a violated unique index,
+// sqliteConstraintUnique the SQLite one.
+const (
+	uniqueViolation        = "23505"
+	sqliteConstraintUnique = 2067
+)
+
+// isAlreadyReserved reports whether err is a violation of the
+// openPerUserBookIndex.
+func (r *Repo) isAlreadyReserved(err error) bool {
+	if r.dialect == db.SQLite {
+		var sqliteErr interface{ Code() int }
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqliteConstraintUnique &&
+			strings.Contains(err.Error(), openPerUserBookColumns)
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == openPerUserBookIndex
+}
+
+const reservationColumns = "id, book_id, user_id, checkout_date, return_date, due_date, picked_up_at, start_date, end_date, copy_id, branch, return_branch, status, status_changed_at, status_note, version"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReservation(row rowScanner) (Reservation, error) {
+	var res Reservation
+	err := row.Scan(&res.ID, &res.BookId, &res.UserId, &res.CheckoutDate, &res.ReturnDate, &res.DueDate, &res.PickedUpAt, &res.StartDate, &res.EndDate, &res.CopyId, &res.Branch, &res.ReturnBranch, &res.Status, &res.StatusChangedAt, &res.StatusNote, &res.Version)
+	return res, err
+}
+
+// querier is the part of *sql.DB and *sql.Tx the repository uses.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 type Repo struct {
-	db *sql.DB
+	db querier
+	// conn is nil for a Repo bound to a transaction.
+	conn *sql.DB
+	// replica serves the reads that tolerate lag; nil means the primary.
+	replica querier
+	dialect db.Dialect
+}
+
+func NewRepo(conn *sql.DB) *Repo {
+	return &Repo{db: conn, conn: conn, dialect: db.Postgres}
+}
+
+// NewSQLiteRepo returns a Repo on a SQLite database migrated with
+// db.Migrate, for running the service without a database server.
+func NewSQLiteRepo(conn *sql.DB) *Repo {
+	return &Repo{db: conn, conn: conn, dialect: db.SQLite}
 }
 
-func NewRepo(db *sql.DB) *Repo {
-	return &Repo{db: db}
+// UseReplica sends the list and history reads to a read-only replica. Reads
+// that must see a change just made, like GetById after Save, and every read
+// in a transaction stay on the primary.
+func (r *Repo) UseReplica(replica *sql.DB) {
+	r.replica = replica
+}
+
+// reader returns where reads that tolerate replication lag go.
+func (r *Repo) reader() querier {
+	if r.replica == nil {
+		return r.db
+	}
+	return r.replica
 }
 
-func (r *Repo) GetAll() ([]Reservation, error) {
-	query := "SELECT * FROM reservations"
-	rows, err := r.db.Query(query)
+func (r *Repo) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	if r.conn == nil {
+		return r.withSavepoint(ctx, fn)
+	}
+
+	ctx, span := tracer.Start(ctx, "Repo.WithTx")
+	defer span.End()
+
+	tx, err := r.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return tracing.Fail(span, err)
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback()
+
+	err = fn(&Repo{db: tx, dialect: r.dialect})
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	return nil
+}
+
+// withSavepoint nests a transaction inside the one r is bound to, so that a
+// failing fn only rolls back its own changes.
+func (r *Repo) withSavepoint(ctx context.Context, fn func(tx Repository) error) error {
+	ctx, span := tracer.Start(ctx, "Repo.WithTx")
+	defer span.End()
+
+	_, err := r.db.ExecContext(ctx, "SAVEPOINT nested")
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	err = fn(r)
+	if err != nil {
+		_, rollbackErr := r.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT nested")
+		return tracing.Fail(span, errors.Join(err, rollbackErr))
+	}
+
+	_, err = r.db.ExecContext(ctx, "RELEASE SAVEPOINT nested")
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	return nil
+}
+
+func (r *Repo) GetAll(ctx context.Context, filter Filter) ([]Reservation, error) {
+	ctx, span := tracer.Start(ctx, "Repo.GetAll")
+	defer span.End()
+
+	where, args := filter.where()
+	query := "SELECT " + reservationColumns + " FROM reservations" + where + " ORDER BY id"
+	rows, err := r.reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
 	}
 	defer rows.Close()
 
 	var reservations []Reservation
 	for rows.Next() {
-		var res Reservation
-		err := rows.Scan(&res.ID, &res.BookId, &res.UserId, &res.CheckoutDate, &res.ReturnDate)
+		res, err := scanReservation(rows)
 		if err != nil {
-			return nil, err
+			return nil, tracing.Fail(span, err)
 		}
 		reservations = append(reservations, res)
 	}
@@ -41,45 +230,678 @@ func (r *Repo) GetAll() ([]Reservation, error) {
 	return reservations, nil
 }
 
-func (r *Repo) GetById(id int64) (Reservation, error) {
-	var res Reservation
+// Each streams the reservations instead of loading them all like GetAll,
+// for exports of the whole table.
+func (r *Repo) Each(ctx context.Context, filter Filter, fn func(Reservation) error) error {
+	ctx, span := tracer.Start(ctx, "Repo.Each")
+	defer span.End()
+
+	where, args := filter.where()
+	query := "SELECT " + reservationColumns + " FROM reservations" + where + " ORDER BY id"
+	rows, err := r.reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		res, err := scanReservation(rows)
+		if err != nil {
+			return tracing.Fail(span, err)
+		}
+		err = fn(res)
+		if err != nil {
+			return tracing.Fail(span, err)
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	return nil
+}
+
+func (r *Repo) GetById(ctx context.Context, id int64) (Reservation, error) {
+	ctx, span := tracer.Start(ctx, "Repo.GetById")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", id))
+
 	query := `
-	SELECT * FROM reservations 
+	SELECT ` + reservationColumns + ` FROM reservations 
 	WHERE id = $1
 	`
-	row := r.db.QueryRow(query, id)
-	err := row.Scan(&res.ID, &res.BookId, &res.UserId, &res.CheckoutDate, &res.ReturnDate)
+	row := r.db.QueryRowContext(ctx, query, id)
+	res, err := scanReservation(row)
 	if err != nil {
-		return res, err
+		return res, tracing.Fail(span, err)
 	}
 
 	return res, nil
 }
 
-func (r *Repo) Save(res Reservation) error {
+// Save inserts a new reservation and returns its ID. The reservation is
+// requested unless another status (StatusBooked for advance bookings) is set.
+func (r *Repo) Save(ctx context.Context, res Reservation) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.Save")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("book.id", res.BookId))
+
 	query := `
-	INSERT INTO reservations (book_id, user_id, checkout_date) 
-	VALUES ($1, $2, $3)
+	INSERT INTO reservations (book_id, user_id, checkout_date, status, status_changed_at, start_date, end_date, branch) 
+	VALUES ($1, $2, $3, $4, $3, $5, $6, $7)
+	RETURNING id
 	`
 	reservationDate := time.Now()
+	status := res.Status
+	if status == "" {
+		status = StatusRequested
+	}
 
-	_, err := r.db.Exec(query, res.BookId, res.UserId, reservationDate)
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, res.BookId, res.UserId, reservationDate, status, res.StartDate, res.EndDate, res.Branch).Scan(&id)
+	if r.isAlreadyReserved(err) {
+		return 0, tracing.Fail(span, ErrAlreadyReserved)
+	}
 	if err != nil {
-		return err
+		return 0, tracing.Fail(span, err)
 	}
 
-	return nil
+	return id, nil
 }
 
-func (r *Repo) UpdateReturnDate(id int64) error {
+// Import upserts the reservations by ID. A replaced reservation gets a new
+// version, so clients holding the old one cannot change it unseen. On
+// Postgres the ID sequence is moved past the imported IDs; sequences ignore
+// rollbacks, so a rolled back import leaves a gap in the IDs.
+func (r *Repo) Import(ctx context.Context, reservations []Reservation, overwrite bool) ([]*Reservation, error) {
+	ctx, span := tracer.Start(ctx, "Repo.Import")
+	defer span.End()
+	span.SetAttributes(attribute.Int("reservations.count", len(reservations)))
+
+	insert := `
+	INSERT INTO reservations (book_id, user_id, checkout_date, return_date, due_date, picked_up_at, start_date, end_date,
+		copy_id, branch, return_branch, status, status_changed_at, status_note, id, version)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+	update := `
+	UPDATE reservations
+	SET book_id = $1, user_id = $2, checkout_date = $3, return_date = $4, due_date = $5, picked_up_at = $6,
+		start_date = $7, end_date = $8, copy_id = $9, branch = $10, return_branch = $11, status = $12,
+		status_changed_at = $13, status_note = $14, version = version + 1
+	WHERE id = $15
+	`
+	replaced := make([]*Reservation, len(reservations))
+	for i, res := range reservations {
+		args := []any{res.BookId, res.UserId, res.CheckoutDate, res.ReturnDate, res.DueDate, res.PickedUpAt, res.StartDate,
+			res.EndDate, res.CopyId, res.Branch, res.ReturnBranch, res.Status, res.StatusChangedAt, res.StatusNote, res.ID}
+
+		existing, err := r.GetById(ctx, res.ID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			_, err = r.db.ExecContext(ctx, insert, append(args, res.Version)...)
+		case err != nil:
+		case !overwrite:
+			err = ErrReservationExists
+		case !existing.Status.terminal():
+			err = ErrReservationOpen
+		default:
+			replaced[i] = &existing
+			_, err = r.db.ExecContext(ctx, update, args...)
+		}
+		if err != nil {
+			return nil, tracing.Fail(span, fmt.Errorf("reservation %d: %w", res.ID, err))
+		}
+	}
+
+	if r.dialect == db.Postgres {
+		_, err := r.db.ExecContext(ctx, `
+		SELECT setval(pg_get_serial_sequence('reservations', 'id'), MAX(id))
+		FROM reservations
+		`)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+	}
+	return replaced, nil
+}
+
+// IncrementVersion bumps the version of the reservation if it still is
+// version, and reports the number of changed rows: 0 means the reservation
+// was changed since the caller read it.
+func (r *Repo) IncrementVersion(ctx context.Context, id, version int64) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.IncrementVersion")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", id))
+
+	query := `
+	UPDATE reservations
+	SET version = version + 1
+	WHERE id = $1 AND version = $2
+	`
+	result, err := r.db.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	return affected, nil
+}
+
+// UpdateReturnDate completes the reservation: it records the return date and
+// moves the reservation to StatusReturned. Only a reservation without a
+// return date is changed, and the number of changed rows is reported, so a
+// concurrent return shows up as 0.
+func (r *Repo) UpdateReturnDate(ctx context.Context, id int64) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.UpdateReturnDate")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", id))
+
 	query := `
 	UPDATE reservations
-	SET return_date = $1
-	WHERE id = $2
+	SET return_date = $1, status = $2, status_changed_at = $1
+	WHERE id = $3 AND return_date IS NULL
 	`
 	returnDate := time.Now()
 
-	_, err := r.db.Exec(query, returnDate, id)
+	result, err := r.db.ExecContext(ctx, query, returnDate, StatusReturned, id)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	return affected, nil
+}
+
+// UpdateStatus moves the reservation from one status to another. The update
+// only applies while the reservation is still in from, so a concurrent
+// transition makes it fail with ErrInvalidTransition.
+func (r *Repo) UpdateStatus(ctx context.Context, id int64, from, to Status) error {
+	ctx, span := tracer.Start(ctx, "Repo.UpdateStatus")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("reservation.id", id),
+		attribute.String("reservation.status.from", string(from)),
+		attribute.String("reservation.status.to", string(to)),
+	)
+
+	err := ValidateTransition(from, to)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	query := `
+	UPDATE reservations
+	SET status = $1, status_changed_at = $2
+	WHERE id = $3 AND status = $4
+	`
+	result, err := r.db.ExecContext(ctx, query, to, time.Now(), id, from)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if affected == 0 {
+		return tracing.Fail(span, fmt.Errorf("%w: reservation %d is no longer %s", ErrInvalidTransition, id, from))
+	}
+
+	return nil
+}
+
+// Activate hands a requested reservation over to the patron and starts the
+// loan with the given due date. copyId records the physical copy handed
+// out, when the book's copies are tracked.
+func (r *Repo) Activate(ctx context.Context, id int64, copyId *int64, dueDate time.Time) error {
+	ctx, span := tracer.Start(ctx, "Repo.Activate")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", id))
+
+	query := `
+	UPDATE reservations
+	SET status = $1, status_changed_at = $2, picked_up_at = $2, due_date = $3, copy_id = $4
+	WHERE id = $5 AND status = $6
+	`
+	result, err := r.db.ExecContext(ctx, query, StatusActive, time.Now(), dueDate, copyId, id, StatusRequested)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if affected == 0 {
+		return tracing.Fail(span, fmt.Errorf("%w: reservation %d is no longer %s", ErrInvalidTransition, id, StatusRequested))
+	}
+
+	return nil
+}
+
+// MarkInTransit completes a loan returned at returnBranch, away from the
+// branch it was picked up at. The copy stays held until it is received back.
+func (r *Repo) MarkInTransit(ctx context.Context, id int64, from Status, returnBranch string) error {
+	ctx, span := tracer.Start(ctx, "Repo.MarkInTransit")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", id), attribute.String("reservation.return_branch", returnBranch))
+
+	err := ValidateTransition(from, StatusInTransit)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	query := `
+	UPDATE reservations
+	SET status = $1, status_changed_at = $2, return_date = $2, return_branch = $3
+	WHERE id = $4 AND status = $5
+	`
+	result, err := r.db.ExecContext(ctx, query, StatusInTransit, time.Now(), returnBranch, id, from)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if affected == 0 {
+		return tracing.Fail(span, fmt.Errorf("%w: reservation %d is no longer %s", ErrInvalidTransition, id, from))
+	}
+
+	return nil
+}
+
+// Renew moves the due date of an active loan from dueDate to newDueDate. It
+// only applies while the loan is still active with the due date the caller
+// saw, so concurrent renewals cannot both extend it.
+func (r *Repo) Renew(ctx context.Context, id int64, dueDate, newDueDate time.Time) error {
+	ctx, span := tracer.Start(ctx, "Repo.Renew")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", id))
+
+	query := `
+	UPDATE reservations
+	SET due_date = $1
+	WHERE id = $2 AND status = $3 AND due_date = $4
+	`
+	result, err := r.db.ExecContext(ctx, query, newDueDate, id, StatusActive, dueDate)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if affected == 0 {
+		return tracing.Fail(span, fmt.Errorf("%w: reservation %d changed while renewing", ErrInvalidTransition, id))
+	}
+
+	return nil
+}
+
+// CountOpenByUser returns how many reservations the patron holds at branch:
+// requested, booked, lent out or on their way back after a return elsewhere.
+func (r *Repo) CountOpenByUser(ctx context.Context, userId int64, branch string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.CountOpenByUser")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("user.id", userId), attribute.String("branch", branch))
+
+	query := `
+	SELECT COUNT(*) FROM reservations
+	WHERE user_id = $1 AND branch = $2 AND status IN ($3, $4, $5, $6, $7)
+	`
+	var open int64
+	err := r.db.QueryRowContext(ctx, query, userId, branch, StatusRequested, StatusBooked, StatusActive, StatusOverdue, StatusInTransit).Scan(&open)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	return open, nil
+}
+
+// StartBooking turns an advance booking whose window has started into a
+// requested reservation. The checkout date is moved to now, because this is
+// when a copy is set aside for the patron.
+func (r *Repo) StartBooking(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "Repo.StartBooking")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", id))
+
+	query := `
+	UPDATE reservations
+	SET status = $1, status_changed_at = $2, checkout_date = $2
+	WHERE id = $3 AND status = $4
+	`
+	result, err := r.db.ExecContext(ctx, query, StatusRequested, time.Now(), id, StatusBooked)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if affected == 0 {
+		return tracing.Fail(span, fmt.Errorf("%w: reservation %d is no longer %s", ErrInvalidTransition, id, StatusBooked))
+	}
+
+	return nil
+}
+
+// GetDueBookings returns advance bookings whose window has started by now.
+func (r *Repo) GetDueBookings(ctx context.Context, now time.Time) ([]Reservation, error) {
+	ctx, span := tracer.Start(ctx, "Repo.GetDueBookings")
+	defer span.End()
+
+	query := `
+	SELECT ` + reservationColumns + ` FROM reservations
+	WHERE status = $1 AND start_date <= $2
+	ORDER BY start_date, id
+	`
+	rows, err := r.db.QueryContext(ctx, query, StatusBooked, now)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	var reservations []Reservation
+	for rows.Next() {
+		res, err := scanReservation(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		reservations = append(reservations, res)
+	}
+
+	return reservations, rows.Err()
+}
+
+// CountHeld returns how many copies of the book are currently set aside for
+// or lent to patrons.
+func (r *Repo) CountHeld(ctx context.Context, bookId int64) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.CountHeld")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("book.id", bookId))
+
+	query := `
+	SELECT COUNT(*) FROM reservations
+	WHERE book_id = $1 AND status IN ($2, $3, $4, $5)
+	`
+	var held int64
+	err := r.db.QueryRowContext(ctx, query, bookId, StatusRequested, StatusActive, StatusOverdue, StatusInTransit).Scan(&held)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	return held, nil
+}
+
+// CountOverlapping returns how many bookings and loans of the book need a
+// copy at some point between start and end. Loans without an end (no due
+// date yet, or already overdue) are assumed to stay out indefinitely.
+func (r *Repo) CountOverlapping(ctx context.Context, bookId int64, start, end time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.CountOverlapping")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("book.id", bookId))
+
+	query := `
+	SELECT COUNT(*) FROM reservations
+	WHERE book_id = $1
+		AND status IN ($2, $3, $4, $5)
+		AND COALESCE(start_date, checkout_date) < $7
+		AND (
+			status = $5
+			OR COALESCE(due_date, end_date) IS NULL
+			OR COALESCE(due_date, end_date) > $6
+		)
+	`
+	var overlapping int64
+	err := r.db.QueryRowContext(ctx, query, bookId, StatusBooked, StatusRequested, StatusActive, StatusOverdue, start, end).Scan(&overlapping)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	return overlapping, nil
+}
+
+// GetOpenLoans returns the loans a patron currently holds: active and
+// overdue reservations. Lost and damaged loans are closed and not included.
+func (r *Repo) GetOpenLoans(ctx context.Context) ([]Reservation, error) {
+	ctx, span := tracer.Start(ctx, "Repo.GetOpenLoans")
+	defer span.End()
+
+	query := `
+	SELECT ` + reservationColumns + ` FROM reservations
+	WHERE status IN ($1, $2)
+	ORDER BY due_date
+	`
+	rows, err := r.db.QueryContext(ctx, query, StatusActive, StatusOverdue)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	var reservations []Reservation
+	for rows.Next() {
+		res, err := scanReservation(rows)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		reservations = append(reservations, res)
+	}
+
+	return reservations, rows.Err()
+}
+
+// WasNotified also counts the notifications recorded before channels were
+// told apart, which have an empty channel.
+func (r *Repo) WasNotified(ctx context.Context, n notifier.Notification, channel string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "Repo.WasNotified")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", n.ReservationId))
+
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM reservation_notifications
+		WHERE reservation_id = $1 AND kind = $2 AND due_date = $3 AND channel IN ($4, '')
+	)
+	`
+	var sent bool
+	err := r.db.QueryRowContext(ctx, query, n.ReservationId, n.Kind, n.DueDate, channel).Scan(&sent)
+	if err != nil {
+		return false, tracing.Fail(span, err)
+	}
+
+	return sent, nil
+}
 
-	return err
+func (r *Repo) RecordNotification(ctx context.Context, n notifier.Notification, channel string) error {
+	ctx, span := tracer.Start(ctx, "Repo.RecordNotification")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", n.ReservationId))
+
+	query := `
+	INSERT INTO reservation_notifications (reservation_id, kind, due_date, channel, sent_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT DO NOTHING
+	`
+	_, err := r.db.ExecContext(ctx, query, n.ReservationId, n.Kind, n.DueDate, channel, time.Now())
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	return nil
+}
+
+// MarkLost closes the reservation as lost or damaged with a staff note. Like
+// UpdateStatus it only applies while the reservation is still in from.
+func (r *Repo) MarkLost(ctx context.Context, id int64, from, to Status, note string) error {
+	ctx, span := tracer.Start(ctx, "Repo.MarkLost")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("reservation.id", id),
+		attribute.String("reservation.status.from", string(from)),
+		attribute.String("reservation.status.to", string(to)),
+	)
+
+	err := ValidateTransition(from, to)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	query := `
+	UPDATE reservations
+	SET status = $1, status_changed_at = $2, status_note = $3
+	WHERE id = $4 AND status = $5
+	`
+	result, err := r.db.ExecContext(ctx, query, to, time.Now(), note, id, from)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	if affected == 0 {
+		return tracing.Fail(span, fmt.Errorf("%w: reservation %d is no longer %s", ErrInvalidTransition, id, from))
+	}
+
+	return nil
+}
+
+func (r *Repo) AddCharge(ctx context.Context, charge Charge) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.AddCharge")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", charge.ReservationId))
+
+	query := `
+	INSERT INTO charges (reservation_id, user_id, amount_cents, reason, note, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+	`
+	var id int64
+	err := r.db.QueryRowContext(ctx, query, charge.ReservationId, charge.UserId, charge.AmountCents, charge.Reason, charge.Note, time.Now()).Scan(&id)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+
+	return id, nil
+}
+
+func (r *Repo) AddEvent(ctx context.Context, event Event) error {
+	ctx, span := tracer.Start(ctx, "Repo.AddEvent")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("reservation.id", event.ReservationId),
+		attribute.String("reservation.event", string(event.Type)),
+	)
+
+	before, err := marshalSnapshot(event.Before)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+	after, err := marshalSnapshot(event.After)
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	query := `
+	INSERT INTO reservation_events (reservation_id, event_type, actor_id, source_ip, before_snapshot, after_snapshot, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = r.db.ExecContext(ctx, query, event.ReservationId, event.Type, event.ActorId, event.SourceIP, before, after, time.Now())
+	if err != nil {
+		return tracing.Fail(span, err)
+	}
+
+	return nil
+}
+
+// CountEvents counts the reservation's history entries of one type. Unlike
+// GetEvents it reads the primary, as rules are decided on the count.
+func (r *Repo) CountEvents(ctx context.Context, reservationId int64, eventType EventType) (int64, error) {
+	ctx, span := tracer.Start(ctx, "Repo.CountEvents")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", reservationId))
+
+	query := `
+	SELECT COUNT(*) FROM reservation_events
+	WHERE reservation_id = $1 AND event_type = $2
+	`
+	var count int64
+	err := r.db.QueryRowContext(ctx, query, reservationId, eventType).Scan(&count)
+	if err != nil {
+		return 0, tracing.Fail(span, err)
+	}
+	return count, nil
+}
+
+func (r *Repo) GetEvents(ctx context.Context, reservationId int64) ([]Event, error) {
+	ctx, span := tracer.Start(ctx, "Repo.GetEvents")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("reservation.id", reservationId))
+
+	query := `
+	SELECT id, reservation_id, event_type, actor_id, source_ip, before_snapshot, after_snapshot, created_at
+	FROM reservation_events
+	WHERE reservation_id = $1
+	ORDER BY created_at, id
+	`
+	rows, err := r.reader().QueryContext(ctx, query, reservationId)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		var before, after []byte
+		err := rows.Scan(&event.ID, &event.ReservationId, &event.Type, &event.ActorId, &event.SourceIP, &before, &after, &event.CreatedAt)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+
+		event.Before, err = unmarshalSnapshot(before)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		event.After, err = unmarshalSnapshot(after)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func marshalSnapshot(res *Reservation) ([]byte, error) {
+	if res == nil {
+		return nil, nil
+	}
+	return json.Marshal(res)
+}
+
+func unmarshalSnapshot(data []byte) (*Reservation, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var res Reservation
+	err := json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
 }
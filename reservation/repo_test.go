@@ -0,0 +1,231 @@
+package reservation
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestRepoWithTx(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		eventErr       error
+		expectedCommit bool
+	}{
+		{
+			testName:       "Commit when every step succeeds",
+			expectedCommit: true,
+		},
+		{
+			testName:       "Roll back when a step fails",
+			eventErr:       errors.New("simulated insert error"),
+			expectedCommit: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE reservations").
+				WithArgs(StatusCancelled, sqlmock.AnyArg(), int64(1), StatusRequested).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("SAVEPOINT nested").WillReturnResult(sqlmock.NewResult(0, 0))
+			insert := mock.ExpectExec("INSERT INTO reservation_events")
+			if tc.eventErr != nil {
+				insert.WillReturnError(tc.eventErr)
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT nested").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			} else {
+				insert.WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("RELEASE SAVEPOINT nested").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			}
+
+			repo := NewRepo(db)
+			err = repo.WithTx(context.Background(), func(tx Repository) error {
+				err := tx.UpdateStatus(context.Background(), 1, StatusRequested, StatusCancelled)
+				if err != nil {
+					return err
+				}
+				// A nested WithTx runs inside the running transaction.
+				return tx.WithTx(context.Background(), func(tx Repository) error {
+					return tx.AddEvent(context.Background(), Event{ReservationId: 1, Type: EventCancelled})
+				})
+			})
+
+			if tc.expectedCommit && err != nil {
+				t.Errorf("Expected no error; got %v", err)
+			}
+			if !tc.expectedCommit && !errors.Is(err, tc.eventErr) {
+				t.Errorf("Expected %v; got %v", tc.eventErr, err)
+			}
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMockRepoWithTx(t *testing.T) {
+	repo := NewMockReservationRepo([]Reservation{{ID: 1, BookId: 1, UserId: 1, Status: StatusRequested}})
+	ctx := context.Background()
+	failure := errors.New("simulated failure")
+
+	err := repo.WithTx(ctx, func(tx Repository) error {
+		_, err := tx.Save(ctx, Reservation{BookId: 2, UserId: 1})
+		if err != nil {
+			return err
+		}
+		err = tx.UpdateStatus(ctx, 1, StatusRequested, StatusCancelled)
+		if err != nil {
+			return err
+		}
+		err = tx.AddEvent(ctx, Event{ReservationId: 1, Type: EventCancelled})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected %v; got %v", failure, err)
+	}
+
+	all, _ := repo.GetAll(ctx, Filter{})
+	if len(all) != 1 || all[0].Status != StatusRequested {
+		t.Errorf("Expected the original reservation only; got %+v", all)
+	}
+	history, _ := repo.GetEvents(ctx, 1)
+	if len(history) != 0 {
+		t.Errorf("Expected no history; got %+v", history)
+	}
+}
+
+func TestRepoSaveAlreadyReserved(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("INSERT INTO reservations").
+		WillReturnError(&pq.Error{Code: uniqueViolation, Constraint: openPerUserBookIndex})
+
+	_, err = NewRepo(db).Save(context.Background(), Reservation{BookId: 1, UserId: 1})
+	if !errors.Is(err, ErrAlreadyReserved) {
+		t.Errorf("Expected %v; got %v", ErrAlreadyReserved, err)
+	}
+}
+
+func TestRepoUpdateReturnDate(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		rowsAffected     int64
+		expectedAffected int64
+	}{
+		{"Return an open reservation", 1, 1},
+		{"Return a reservation returned meanwhile", 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec("UPDATE reservations .* AND return_date IS NULL").
+				WithArgs(sqlmock.AnyArg(), StatusReturned, int64(1)).
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+
+			affected, err := NewRepo(db).UpdateReturnDate(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("Expected no error; got %v", err)
+			}
+			if affected != tc.expectedAffected {
+				t.Errorf("Expected %d affected rows; got %d", tc.expectedAffected, affected)
+			}
+		})
+	}
+}
+
+func TestRepoUseReplica(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+
+	columns := []string{"id", "book_id", "user_id", "checkout_date", "return_date", "due_date", "picked_up_at", "start_date", "end_date",
+		"copy_id", "branch", "return_branch", "status", "status_changed_at", "status_note", "version"}
+	row := []driver.Value{1, 1, 1, time.Now(), nil, nil, nil, nil, nil, nil, "main", "", StatusRequested, time.Now(), "", 1}
+
+	replicaMock.ExpectQuery("SELECT .* FROM reservations ORDER BY id").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(row...))
+	replicaMock.ExpectQuery("SELECT .* FROM reservation_events").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	primaryMock.ExpectQuery("SELECT .* FROM reservations").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(row...))
+	primaryMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM reservation_events").
+		WithArgs(int64(1), EventRenewed).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery("SELECT .* FROM reservations ORDER BY id").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(row...))
+	primaryMock.ExpectCommit()
+
+	repo := NewRepo(primary)
+	repo.UseReplica(replica)
+	ctx := context.Background()
+
+	_, err = repo.GetAll(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.GetEvents(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.GetById(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Renewal limits are checked on the primary.
+	_, err = repo.CountEvents(ctx, 1, EventRenewed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reads in a transaction see its own changes.
+	err = repo.WithTx(ctx, func(tx Repository) error {
+		_, err := tx.GetAll(ctx, Filter{})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mock := range map[string]sqlmock.Sqlmock{"primary": primaryMock, "replica": replicaMock} {
+		err = mock.ExpectationsWereMet()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,818 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	"github.com/shkuran/go-library-microservices/reservation-service/metrics"
+)
+
+// Service holds the reservation rules shared by the REST handler and the
+// gRPC server. Rule violations and failures are returned as *Error.
+type Service struct {
+	repo      Repository
+	books     BookClient
+	publisher events.Publisher
+	policy    Policy
+}
+
+func NewService(repo Repository, books BookClient, publisher events.Publisher, policy Policy) *Service {
+	return &Service{repo: repo, books: books, publisher: publisher, policy: policy}
+}
+
+// ReserveOptions are the optional parts of a reservation request. A
+// StartDate in the future makes it an advance booking.
+type ReserveOptions struct {
+	Branch    string
+	StartDate *time.Time
+	EndDate   *time.Time
+}
+
+type sourceIPKey struct{}
+
+// WithSourceIP returns a copy of ctx carrying the caller's address, which is
+// recorded in the reservation history.
+func WithSourceIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, sourceIPKey{}, ip)
+}
+
+func sourceIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(sourceIPKey{}).(string)
+	return ip
+}
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion returns a copy of ctx under which changes to a
+// reservation fail with ErrStaleVersion unless it is still at version.
+func WithExpectedVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+func expectedVersionFromContext(ctx context.Context) (int64, bool) {
+	version, ok := ctx.Value(expectedVersionKey{}).(int64)
+	return version, ok
+}
+
+// List returns the reservations matching filter. Patrons only see their own
+// reservations; staff see everyone's.
+func (s *Service) List(ctx context.Context, userId int64, staff bool, filter Filter) ([]Reservation, error) {
+	if !staff {
+		if filter.UserId != 0 && filter.UserId != userId {
+			return nil, reject(ErrNotOwner, "Not access to reservations of other users!")
+		}
+		filter.UserId = userId
+	}
+
+	reservations, err := s.repo.GetAll(ctx, filter)
+	if err != nil {
+		return nil, internal("Could not fetch reservations!", err)
+	}
+	return reservations, nil
+}
+
+// Export calls fn with the reservations matching filter one at a time, so
+// that large exports are not held in memory.
+func (s *Service) Export(ctx context.Context, filter Filter, fn func(Reservation) error) error {
+	err := s.repo.Each(ctx, filter, fn)
+	if err != nil {
+		return internal("Could not export reservations!", err)
+	}
+	return nil
+}
+
+// Get returns a reservation to its owner or to staff.
+func (s *Service) Get(ctx context.Context, userId int64, staff bool, reservationId int64) (Reservation, error) {
+	reservation, err := s.repo.GetById(ctx, reservationId)
+	if err != nil {
+		return Reservation{}, lookupFailed(err)
+	}
+	if reservation.UserId != userId && !staff {
+		return Reservation{}, reject(ErrNotOwner, "Not access to reservation!")
+	}
+	return reservation, nil
+}
+
+// Availability returns the book with the number of copies that can be lent
+// now, counted like Reserve does: copies on the shelf that no booking needs
+// during a loan starting now at the default branch.
+func (s *Service) Availability(ctx context.Context, bookId int64) (Book, int64, error) {
+	book, err := s.books.GetBook(ctx, bookId)
+	if err != nil {
+		return Book{}, 0, internal("Could not fetch book!", err)
+	}
+	available, err := s.availableCopies(ctx, book)
+	if err != nil {
+		return Book{}, 0, internal("Could not check the book availability!", err)
+	}
+	now := time.Now()
+	free, err := s.freeCopies(ctx, book, now, now.Add(s.policy.loanPeriod(s.policy.DefaultBranch)))
+	if err != nil {
+		return Book{}, 0, internal("Could not check the book availability!", err)
+	}
+	return book, max(min(available, free), 0), nil
+}
+
+// Reserve sets a copy of the book aside for the patron, or books it for a
+// later window when opts.StartDate lies in the future.
+func (s *Service) Reserve(ctx context.Context, userId, bookId int64, opts ReserveOptions) (Reservation, error) {
+	book, err := s.books.GetBook(ctx, bookId)
+	if err != nil {
+		return Reservation{}, internal("Could not fetch book!", err)
+	}
+
+	if opts.StartDate != nil && opts.StartDate.After(time.Now()) {
+		return s.book(ctx, userId, book, opts)
+	}
+
+	numberOfBookCopies, err := s.availableCopies(ctx, book)
+	if err != nil {
+		return Reservation{}, internal("Could not check the book availability!", err)
+	}
+	if numberOfBookCopies < 1 {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnavailable).Inc()
+		return Reservation{}, reject(ErrUnavailable, "The book is not available!")
+	}
+
+	reservation := Reservation{BookId: bookId, UserId: userId, Branch: opts.Branch}
+	err = s.checkBranch(ctx, userId, &reservation.Branch, 1)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	// A copy on the shelf may still be promised to a booking that starts
+	// before this loan would end.
+	now := time.Now()
+	free, err := s.copyFree(ctx, book, now, now.Add(s.policy.loanPeriod(reservation.Branch)))
+	if err != nil {
+		return Reservation{}, internal("Could not check the book availability!", err)
+	}
+	if !free {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnavailable).Inc()
+		return Reservation{}, reject(ErrUnavailable, "The book is booked by someone else during the loan period!")
+	}
+
+	// The copy is taken first: the book service refuses it when the cached
+	// count was stale.
+	err = s.takeCopies(ctx, book.ID, 1)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	created, err := s.create(ctx, userId, reservation)
+	if err != nil {
+		s.giveBackCopies(ctx, book.ID, 1)
+		return Reservation{}, err
+	}
+
+	metrics.ReservationsCreated.Inc()
+	return created, nil
+}
+
+// book stores a reservation whose window starts in the future. The book's
+// copies are left alone until the BookingScheduler starts the booking;
+// instead the request is checked against every copy that is already lent out
+// or promised to someone else during the requested window.
+func (s *Service) book(ctx context.Context, userId int64, book Book, opts ReserveOptions) (Reservation, error) {
+	start := *opts.StartDate
+	if opts.EndDate == nil || !opts.EndDate.After(start) {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonParseError).Inc()
+		return Reservation{}, reject(ErrInvalidRequest, "The booking must end after it starts!")
+	}
+	end := *opts.EndDate
+	if end.Sub(start) > s.policy.LoanPeriod {
+		return Reservation{}, reject(ErrInvalidRequest, "The booking is longer than the loan period!")
+	}
+
+	reservation := Reservation{
+		BookId:    book.ID,
+		UserId:    userId,
+		Branch:    opts.Branch,
+		StartDate: &start,
+		EndDate:   &end,
+		Status:    StatusBooked,
+	}
+	err := s.checkBranch(ctx, userId, &reservation.Branch, 1)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	free, err := s.copyFree(ctx, book, start, end)
+	if err != nil {
+		return Reservation{}, internal("Could not check the book availability!", err)
+	}
+	if !free {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnavailable).Inc()
+		return Reservation{}, reject(ErrUnavailable, "The book is not available for the requested dates!")
+	}
+
+	created, err := s.create(ctx, userId, reservation)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	metrics.ReservationsCreated.Inc()
+	return created, nil
+}
+
+// Return completes the patron's loan. A copy handed in at a branch other
+// than the pickup branch travels back before it can be lent again, so the
+// book service is only told once it is received.
+func (s *Service) Return(ctx context.Context, userId, reservationId int64, returnBranch string) (Reservation, error) {
+	reservation, err := s.repo.GetById(ctx, reservationId)
+	if err != nil {
+		return Reservation{}, lookupFailed(err)
+	}
+
+	if reservation.UserId != userId {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnauthorized).Inc()
+		return Reservation{}, reject(ErrNotOwner, "Not access to copmlete reservation!")
+	}
+
+	if reservation.ReturnDate != nil || reservation.Status == StatusReturned {
+		return Reservation{}, reject(ErrInvalidTransition, "The reservation is copleted already!")
+	}
+
+	if !reservation.Status.returnable() {
+		return Reservation{}, reject(ErrInvalidTransition, fmt.Sprintf("Could not complete %s reservation!", reservation.Status))
+	}
+
+	if returnBranch != "" && !s.policy.knowsBranch(returnBranch) {
+		return Reservation{}, reject(ErrInvalidRequest, fmt.Sprintf("Unknown branch %s!", returnBranch))
+	}
+
+	// A copy that was never picked up has not left its branch.
+	if returnBranch != "" && reservation.Branch != "" && returnBranch != reservation.Branch && reservation.Status != StatusRequested {
+		inTransit, err := s.change(ctx, EventInTransit, userId, reservation, func(tx Repository) error {
+			err := tx.MarkInTransit(ctx, reservationId, reservation.Status, returnBranch)
+			if err != nil {
+				return internal("Could not copmlete reservation!", err)
+			}
+			return recoverCopy(ctx, tx, reservation)
+		})
+		if err != nil {
+			return Reservation{}, err
+		}
+
+		metrics.ReservationsCompleted.Inc()
+		return inTransit, nil
+	}
+
+	returned, err := s.change(ctx, EventCompleted, userId, reservation, func(tx Repository) error {
+		if err := returnFailed(tx.UpdateReturnDate(ctx, reservationId)); err != nil {
+			return err
+		}
+		return recoverCopy(ctx, tx, reservation)
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	err = s.restoreCopies(ctx, reservation.BookId, 1)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	metrics.ReservationsCompleted.Inc()
+	return returned, nil
+}
+
+// recoverCopy makes the copy of a lost loan that is handed in after all
+// lendable again; MarkLost took it out of circulation.
+func recoverCopy(ctx context.Context, tx Repository, reservation Reservation) error {
+	if reservation.Status != StatusLost || reservation.CopyId == nil {
+		return nil
+	}
+	err := tx.SetCopyCondition(ctx, *reservation.CopyId, CopyGood)
+	if err != nil {
+		return internal("Could not update the copy condition!", err)
+	}
+	return nil
+}
+
+// Cancel drops a requested reservation within the cancellation window, or a
+// booking at any time before it starts.
+func (s *Service) Cancel(ctx context.Context, userId, reservationId int64) (Reservation, error) {
+	reservation, err := s.repo.GetById(ctx, reservationId)
+	if err != nil {
+		return Reservation{}, lookupFailed(err)
+	}
+
+	if reservation.UserId != userId {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnauthorized).Inc()
+		return Reservation{}, reject(ErrNotOwner, "Not access to cancel reservation!")
+	}
+
+	if !reservation.Status.CanTransitionTo(StatusCancelled) {
+		return Reservation{}, reject(ErrInvalidTransition, fmt.Sprintf("Could not cancel %s reservation!", reservation.Status))
+	}
+
+	// A booking has not taken a copy yet, so it can be dropped at any time
+	// before its window starts.
+	booked := reservation.Status == StatusBooked
+	if !booked && time.Since(reservation.CheckoutDate) > s.policy.CancelWindow {
+		return Reservation{}, reject(ErrInvalidRequest, "The cancellation window has expired!")
+	}
+
+	cancelled, err := s.change(ctx, EventCancelled, userId, reservation, func(tx Repository) error {
+		err := tx.UpdateStatus(ctx, reservationId, reservation.Status, StatusCancelled)
+		if err != nil {
+			return internal("Could not cancel reservation!", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	if !booked {
+		err = s.restoreCopies(ctx, reservation.BookId, 1)
+		if err != nil {
+			return Reservation{}, err
+		}
+	}
+
+	metrics.ReservationsCancelled.Inc()
+	return cancelled, nil
+}
+
+// Renew extends an active loan by another loan period of its branch, up to
+// Policy.MaxRenewals times.
+func (s *Service) Renew(ctx context.Context, userId, reservationId int64) (Reservation, error) {
+	reservation, err := s.repo.GetById(ctx, reservationId)
+	if err != nil {
+		return Reservation{}, lookupFailed(err)
+	}
+
+	if reservation.UserId != userId {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnauthorized).Inc()
+		return Reservation{}, reject(ErrNotOwner, "Not access to renew reservation!")
+	}
+
+	if reservation.Status != StatusActive || reservation.DueDate == nil {
+		return Reservation{}, reject(ErrInvalidTransition, fmt.Sprintf("Could not renew %s reservation!", reservation.Status))
+	}
+
+	dueDate := reservation.DueDate.Add(s.policy.loanPeriod(reservation.Branch))
+
+	// The loan keeps its copy past the old due date, which bookings starting
+	// then may have counted on.
+	book, err := s.books.GetBook(ctx, reservation.BookId)
+	if err != nil {
+		return Reservation{}, internal("Could not fetch book!", err)
+	}
+	free, err := s.copyFree(ctx, book, *reservation.DueDate, dueDate)
+	if err != nil {
+		return Reservation{}, internal("Could not check the book availability!", err)
+	}
+	if !free {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnavailable).Inc()
+		return Reservation{}, reject(ErrUnavailable, "The book is booked by someone else after the due date!")
+	}
+
+	return s.change(ctx, EventRenewed, userId, reservation, func(tx Repository) error {
+		// Counted in the transaction, as a concurrent renewal bumps the
+		// version and makes this one fail.
+		if s.policy.MaxRenewals > 0 {
+			renewals, err := tx.CountEvents(ctx, reservationId, EventRenewed)
+			if err != nil {
+				return internal("Could not fetch reservation history!", err)
+			}
+			if renewals >= s.policy.MaxRenewals {
+				return reject(ErrInvalidRequest, fmt.Sprintf("The reservation was renewed %d times already!", renewals))
+			}
+		}
+
+		err := tx.Renew(ctx, reservationId, *reservation.DueDate, dueDate)
+		if err != nil {
+			return internal("Could not renew reservation!", err)
+		}
+		return nil
+	})
+}
+
+// History returns the audit history of a reservation to its owner or to
+// staff.
+func (s *Service) History(ctx context.Context, userId int64, staff bool, reservationId int64) ([]Event, error) {
+	reservation, err := s.repo.GetById(ctx, reservationId)
+	if err != nil {
+		return nil, lookupFailed(err)
+	}
+	if reservation.UserId != userId && !staff {
+		return nil, reject(ErrNotOwner, "Not access to reservation history!")
+	}
+
+	events, err := s.repo.GetEvents(ctx, reservationId)
+	if err != nil {
+		return nil, internal("Could not fetch reservation history!", err)
+	}
+	return events, nil
+}
+
+// PickUp hands the reserved copy to the patron and starts the loan. For
+// books with tracked copies the scanned barcode must name a lendable copy of
+// the reserved book; see pickUpCopy.
+func (s *Service) PickUp(ctx context.Context, userId, reservationId int64, barcode string) (Reservation, error) {
+	reservation, err := s.repo.GetById(ctx, reservationId)
+	if err != nil {
+		return Reservation{}, lookupFailed(err)
+	}
+
+	if reservation.UserId != userId {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnauthorized).Inc()
+		return Reservation{}, reject(ErrNotOwner, "Not access to pick up reservation!")
+	}
+
+	if !reservation.Status.CanTransitionTo(StatusActive) {
+		return Reservation{}, reject(ErrInvalidTransition, fmt.Sprintf("Could not pick up %s reservation!", reservation.Status))
+	}
+
+	copyId, err := s.pickUpCopy(ctx, reservation, barcode)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	dueDate := time.Now().Add(s.policy.loanPeriod(reservation.Branch))
+	if reservation.EndDate != nil {
+		dueDate = *reservation.EndDate
+	}
+
+	return s.change(ctx, EventPickedUp, userId, reservation, func(tx Repository) error {
+		err := tx.Activate(ctx, reservationId, copyId, dueDate)
+		if err != nil {
+			return internal("Could not pick up reservation!", err)
+		}
+		return nil
+	})
+}
+
+// pickUpCopy resolves the barcode scanned at pickup. Books without tracked
+// copies can be picked up without a scan; for the rest the barcode must name
+// a lendable copy of the reserved book that is not already out.
+func (s *Service) pickUpCopy(ctx context.Context, reservation Reservation, barcode string) (*int64, error) {
+	if barcode == "" {
+		copies, err := s.repo.GetCopies(ctx, reservation.BookId)
+		if err != nil {
+			return nil, internal("Could not fetch copy!", err)
+		}
+		if len(copies) > 0 {
+			return nil, reject(ErrInvalidRequest, "Scan the barcode of the copy to pick up!")
+		}
+		return nil, nil
+	}
+
+	c, err := s.repo.GetCopyByBarcode(ctx, barcode)
+	if errors.Is(err, ErrCopyNotFound) {
+		return nil, reject(ErrInvalidRequest, "Unknown barcode!")
+	}
+	if err != nil {
+		return nil, internal("Could not fetch copy!", err)
+	}
+
+	switch {
+	case c.BookId != reservation.BookId:
+		return nil, reject(ErrInvalidRequest, "The copy does not belong to the reserved book!")
+	case c.Branch != "" && reservation.Branch != "" && c.Branch != reservation.Branch:
+		return nil, reject(ErrInvalidRequest, fmt.Sprintf("The copy is shelved at branch %s, not %s!", c.Branch, reservation.Branch))
+	case !c.Condition.IsLendable():
+		return nil, reject(ErrUnavailable, "The copy is "+string(c.Condition)+"!")
+	case c.OnLoan:
+		return nil, reject(ErrUnavailable, "The copy is already on loan!")
+	}
+
+	return &c.ID, nil
+}
+
+// LossReport describes how staff close a loan that did not come back intact.
+type LossReport struct {
+	// Condition is StatusLost or StatusDamaged.
+	Condition Status
+	Note      string
+	// ReplacementCostCents is charged to the patron when positive.
+	ReplacementCostCents int64
+}
+
+// MarkLost closes an active or overdue loan as lost or damaged. The copy is
+// not given back to the book service. The returned charge ID is 0 when no
+// replacement cost was given.
+func (s *Service) MarkLost(ctx context.Context, staffId, reservationId int64, report LossReport) (Reservation, int64, error) {
+	if report.Condition != StatusLost && report.Condition != StatusDamaged {
+		return Reservation{}, 0, reject(ErrInvalidRequest, fmt.Sprintf("Could not mark reservation as %s!", report.Condition))
+	}
+	if report.ReplacementCostCents < 0 {
+		return Reservation{}, 0, reject(ErrInvalidRequest, "The replacement cost must not be negative!")
+	}
+
+	reservation, err := s.repo.GetById(ctx, reservationId)
+	if err != nil {
+		return Reservation{}, 0, lookupFailed(err)
+	}
+
+	if !reservation.Status.CanTransitionTo(report.Condition) {
+		return Reservation{}, 0, reject(ErrInvalidTransition,
+			fmt.Sprintf("Could not mark %s reservation as %s!", reservation.Status, report.Condition))
+	}
+
+	var chargeId int64
+	closed, err := s.change(ctx, EventOverridden, staffId, reservation, func(tx Repository) error {
+		err := tx.MarkLost(ctx, reservationId, reservation.Status, report.Condition, report.Note)
+		if err != nil {
+			return internal("Could not mark reservation as lost!", err)
+		}
+
+		if reservation.CopyId != nil {
+			err = tx.SetCopyCondition(ctx, *reservation.CopyId, CopyCondition(report.Condition))
+			if err != nil {
+				return internal("Could not update the copy condition!", err)
+			}
+		}
+
+		if report.ReplacementCostCents > 0 {
+			chargeId, err = tx.AddCharge(ctx, Charge{
+				ReservationId: reservationId,
+				UserId:        reservation.UserId,
+				AmountCents:   report.ReplacementCostCents,
+				Reason:        report.Condition,
+				Note:          report.Note,
+			})
+			if err != nil {
+				return internal("Could not create replacement charge!", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Reservation{}, 0, err
+	}
+
+	metrics.ReservationsLost.WithLabelValues(string(report.Condition)).Inc()
+	return closed, chargeId, nil
+}
+
+// Receive confirms that a copy returned at another branch has arrived back
+// home. Only then is the copy given back to the book service.
+func (s *Service) Receive(ctx context.Context, staffId, reservationId int64) (Reservation, error) {
+	reservation, err := s.repo.GetById(ctx, reservationId)
+	if err != nil {
+		return Reservation{}, lookupFailed(err)
+	}
+
+	if reservation.Status != StatusInTransit {
+		return Reservation{}, reject(ErrInvalidTransition, fmt.Sprintf("Could not receive %s reservation!", reservation.Status))
+	}
+
+	received, err := s.change(ctx, EventReceived, staffId, reservation, func(tx Repository) error {
+		err := tx.UpdateStatus(ctx, reservationId, StatusInTransit, StatusReturned)
+		if err != nil {
+			return internal("Could not receive reservation!", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	err = s.restoreCopies(ctx, reservation.BookId, 1)
+	if err != nil {
+		return Reservation{}, err
+	}
+	return received, nil
+}
+
+// AddCopy registers a physical copy of a book that the book service knows.
+// The copy is in good condition unless stated otherwise.
+func (s *Service) AddCopy(ctx context.Context, c Copy) (int64, error) {
+	if c.Barcode == "" {
+		return 0, reject(ErrInvalidRequest, "The copy needs a barcode!")
+	}
+	if c.Condition == "" {
+		c.Condition = CopyGood
+	}
+	if c.Branch != "" && !s.policy.knowsBranch(c.Branch) {
+		return 0, reject(ErrInvalidRequest, fmt.Sprintf("Unknown branch %s!", c.Branch))
+	}
+
+	_, err := s.books.GetBook(ctx, c.BookId)
+	if err != nil {
+		return 0, internal("Could not fetch book!", err)
+	}
+
+	copyId, err := s.repo.AddCopy(ctx, c)
+	if err != nil {
+		return 0, internal("Could not add copy!", err)
+	}
+	return copyId, nil
+}
+
+// Copies lists the copies of a book and whether each is out on loan.
+func (s *Service) Copies(ctx context.Context, bookId int64) ([]Copy, error) {
+	copies, err := s.repo.GetCopies(ctx, bookId)
+	if err != nil {
+		return nil, internal("Could not fetch copies!", err)
+	}
+	return copies, nil
+}
+
+// CopyByBarcode looks a copy up by its barcode.
+func (s *Service) CopyByBarcode(ctx context.Context, barcode string) (Copy, error) {
+	c, err := s.repo.GetCopyByBarcode(ctx, barcode)
+	if errors.Is(err, ErrCopyNotFound) {
+		return Copy{}, &Error{Kind: ErrNotFound, Message: "Copy not found!", Err: err}
+	}
+	if err != nil {
+		return Copy{}, internal("Could not fetch copy!", err)
+	}
+	return c, nil
+}
+
+// restoreCopies gives count copies of the book back to the book service.
+func (s *Service) restoreCopies(ctx context.Context, bookId, count int64) error {
+	_, err := s.books.AdjustAvailableCopies(ctx, bookId, count)
+	if err != nil {
+		return internal("Failed to update the number of book copies in book service", err)
+	}
+	return nil
+}
+
+// takeCopies lends count copies of the book in the book service, and
+// rejects the request when it has fewer left.
+func (s *Service) takeCopies(ctx context.Context, bookId, count int64) error {
+	_, err := s.books.AdjustAvailableCopies(ctx, bookId, -count)
+	if errors.Is(err, ErrNoCopiesLeft) {
+		metrics.ReservationsRejected.WithLabelValues(metrics.ReasonUnavailable).Inc()
+		return reject(ErrUnavailable, "The book is not available!")
+	}
+	if err != nil {
+		return internal("Failed to update the number of book copies in book service", err)
+	}
+	return nil
+}
+
+// giveBackCopies returns copies taken for reservations that were not stored
+// after all. A failure is only logged, as the caller is already failing.
+func (s *Service) giveBackCopies(ctx context.Context, bookId, count int64) {
+	_, err := s.books.AdjustAvailableCopies(ctx, bookId, count)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not give back book copies!", "error", err, "book_id", bookId, "count", count)
+	}
+}
+
+// availableCopies derives availability from the book's copies once any are
+// registered, and falls back to the book service's count otherwise.
+func (s *Service) availableCopies(ctx context.Context, book Book) (int64, error) {
+	available, tracked, err := s.repo.CountAvailableCopies(ctx, book.ID)
+	if err != nil {
+		return 0, err
+	}
+	if !tracked {
+		return book.AvailableCopies, nil
+	}
+	return available, nil
+}
+
+// copyFree reports whether a copy of the book is left for one more
+// reservation between start and end.
+func (s *Service) copyFree(ctx context.Context, book Book, start, end time.Time) (bool, error) {
+	free, err := s.freeCopies(ctx, book, start, end)
+	return free > 0, err
+}
+
+// freeCopies counts the copies of the book left between start and end: every
+// copy, on the shelf or lent out, counts against the bookings and loans that
+// need one in that window.
+func (s *Service) freeCopies(ctx context.Context, book Book, start, end time.Time) (int64, error) {
+	held, err := s.repo.CountHeld(ctx, book.ID)
+	if err != nil {
+		return 0, err
+	}
+	available, err := s.availableCopies(ctx, book)
+	if err != nil {
+		return 0, err
+	}
+	overlapping, err := s.repo.CountOverlapping(ctx, book.ID, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return available + held - overlapping, nil
+}
+
+// checkBranch fills in the default branch and enforces the branch's
+// borrowing limit: the patron's open reservations there plus the pending
+// ones about to be created must not exceed it.
+func (s *Service) checkBranch(ctx context.Context, userId int64, branch *string, pending int64) error {
+	if *branch == "" {
+		*branch = s.policy.DefaultBranch
+	}
+	if !s.policy.knowsBranch(*branch) {
+		return reject(ErrInvalidRequest, fmt.Sprintf("Unknown branch %s!", *branch))
+	}
+
+	rules := s.policy.Branches[*branch]
+	if rules.MaxLoans == 0 {
+		return nil
+	}
+	open, err := s.repo.CountOpenByUser(ctx, userId, *branch)
+	if err != nil {
+		return internal("Could not check the branch rules!", err)
+	}
+	if open+pending > rules.MaxLoans {
+		return reject(ErrInvalidRequest, fmt.Sprintf("The borrowing limit of %d at branch %s is reached!", rules.MaxLoans, *branch))
+	}
+	return nil
+}
+
+// create stores a new reservation together with its EventCreated history
+// entry and publishes the event once both are committed.
+func (s *Service) create(ctx context.Context, userId int64, reservation Reservation) (Reservation, error) {
+	var created Reservation
+	err := s.repo.WithTx(ctx, func(tx Repository) error {
+		reservationId, err := tx.Save(ctx, reservation)
+		if err != nil {
+			return saveFailed(err)
+		}
+		created, err = recordEvent(ctx, tx, EventCreated, userId, nil, reservationId)
+		return err
+	})
+	if err != nil {
+		return Reservation{}, asServiceError(err)
+	}
+
+	publish(ctx, s.publisher, EventCreated, created)
+	return created, nil
+}
+
+// change applies a change to an existing reservation together with its
+// history entry, so either both are stored or neither is. The domain event is
+// published once they are committed. apply reports failures as *Error; the
+// stored reservation after the change is returned. A version the caller
+// expects (see WithExpectedVersion) must match before.
+func (s *Service) change(ctx context.Context, eventType EventType, actorId int64, before Reservation, apply func(tx Repository) error) (Reservation, error) {
+	if version, ok := expectedVersionFromContext(ctx); ok && version != before.Version {
+		return Reservation{}, staleVersion()
+	}
+
+	var after Reservation
+	err := s.repo.WithTx(ctx, func(tx Repository) error {
+		err := apply(tx)
+		if err != nil {
+			return err
+		}
+		after, err = recordEvent(ctx, tx, eventType, actorId, &before, before.ID)
+		return err
+	})
+	if err != nil {
+		return Reservation{}, asServiceError(err)
+	}
+
+	publish(ctx, s.publisher, eventType, after)
+	return after, nil
+}
+
+// recordEvent appends an entry to the reservation history through repo. A
+// change to an existing reservation bumps its version, which fails with
+// ErrStaleVersion if another change was stored since before was read. The
+// after snapshot is re-read so it reflects what was actually stored, and is
+// returned.
+func recordEvent(ctx context.Context, repo Repository, eventType EventType, actorId int64, before *Reservation, reservationId int64) (Reservation, error) {
+	if before != nil {
+		affected, err := repo.IncrementVersion(ctx, reservationId, before.Version)
+		if err != nil {
+			return Reservation{}, internal("Could not save the change!", err)
+		}
+		if affected == 0 {
+			return Reservation{}, staleVersion()
+		}
+	}
+
+	after, err := repo.GetById(ctx, reservationId)
+	if err != nil {
+		return Reservation{}, internal("Could not fetch reservation!", err)
+	}
+
+	err = repo.AddEvent(ctx, Event{
+		ReservationId: reservationId,
+		Type:          eventType,
+		ActorId:       actorId,
+		SourceIP:      sourceIPFromContext(ctx),
+		Before:        before,
+		After:         &after,
+	})
+	if err != nil {
+		return Reservation{}, internal("Could not record reservation history!", err)
+	}
+	return after, nil
+}
+
+// asServiceError keeps *Error values and reports anything else, such as a
+// failed commit, as an internal failure.
+func asServiceError(err error) error {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return err
+	}
+	return internal("Could not save the change!", err)
+}
@@ -0,0 +1,623 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+)
+
+func newTestService(books []Book, reservations []Reservation) (*Service, *MockReservationRepo, *MockBookClient) {
+	repo := NewMockReservationRepo(reservations)
+	bookClient := NewMockBookClient(books)
+	service := NewService(repo, bookClient, events.NewMemoryPublisher(),
+		Policy{CancelWindow: time.Hour, LoanPeriod: 14 * 24 * time.Hour, DefaultBranch: "main"})
+	return service, repo, bookClient
+}
+
+// expectKind fails the test unless err is a Service error of the given kind.
+// A nil kind expects success.
+func expectKind(t *testing.T, err error, kind error) {
+	t.Helper()
+	if kind == nil {
+		if err != nil {
+			t.Fatalf("Expected no error; got %v", err)
+		}
+		return
+	}
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || !errors.Is(err, kind) {
+		t.Fatalf("Expected a %q error; got %v", kind, err)
+	}
+}
+
+func availableCopies(t *testing.T, books *MockBookClient, bookId int64) int64 {
+	t.Helper()
+	book, err := books.GetBook(context.Background(), bookId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book.AvailableCopies
+}
+
+func TestServiceReserve(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		books          []Book
+		reservations   []Reservation
+		bookId         int64
+		expectedKind   error
+		expectedCopies int64
+	}{
+		{
+			testName:       "Reserve an available book",
+			books:          []Book{{ID: 1, AvailableCopies: 2}},
+			bookId:         1,
+			expectedCopies: 1,
+		},
+		{
+			testName:       "Reserve an unavailable book",
+			books:          []Book{{ID: 1, AvailableCopies: 0}},
+			bookId:         1,
+			expectedKind:   ErrUnavailable,
+			expectedCopies: 0,
+		},
+		{
+			testName:       "Reserve a book already held",
+			books:          []Book{{ID: 1, AvailableCopies: 2}},
+			reservations:   []Reservation{{ID: 1, BookId: 1, UserId: 7, Status: StatusActive}},
+			bookId:         1,
+			expectedKind:   ErrConflict,
+			expectedCopies: 2,
+		},
+		{
+			testName:       "Reserve a book returned before",
+			books:          []Book{{ID: 1, AvailableCopies: 2}},
+			reservations:   []Reservation{{ID: 1, BookId: 1, UserId: 7, ReturnDate: &time.Time{}, Status: StatusReturned}},
+			bookId:         1,
+			expectedCopies: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			service, repo, books := newTestService(tc.books, tc.reservations)
+
+			created, err := service.Reserve(context.Background(), 7, tc.bookId, ReserveOptions{})
+			expectKind(t, err, tc.expectedKind)
+
+			if copies := availableCopies(t, books, tc.bookId); copies != tc.expectedCopies {
+				t.Errorf("Expected AvailableCopies %d; got %d", tc.expectedCopies, copies)
+			}
+			if tc.expectedKind != nil {
+				return
+			}
+
+			if created.UserId != 7 || created.Status != StatusRequested || created.Branch != "main" {
+				t.Errorf("Unexpected reservation %+v", created)
+			}
+			history, _ := repo.GetEvents(context.Background(), created.ID)
+			if len(history) != 1 || history[0].Type != EventCreated {
+				t.Errorf("Expected one %s event; got %+v", EventCreated, history)
+			}
+		})
+	}
+
+	t.Run("Book service failure", func(t *testing.T) {
+		service, _, _ := newTestService(nil, nil)
+
+		_, err := service.Reserve(context.Background(), 7, 1, ReserveOptions{})
+		var serviceErr *Error
+		if !errors.As(err, &serviceErr) || serviceErr.Kind != nil {
+			t.Fatalf("Expected an internal error; got %v", err)
+		}
+	})
+}
+
+func TestServiceReturn(t *testing.T) {
+	due := time.Now().Add(24 * time.Hour)
+	returned := time.Now()
+
+	testCases := []struct {
+		testName       string
+		reservation    Reservation
+		userId         int64
+		expectedKind   error
+		expectedStatus Status
+		expectedCopies int64
+	}{
+		{
+			testName:       "Return an active loan",
+			reservation:    Reservation{ID: 1, BookId: 1, UserId: 1, DueDate: &due, Status: StatusActive},
+			userId:         1,
+			expectedStatus: StatusReturned,
+			expectedCopies: 1,
+		},
+		{
+			testName:       "Return another user's loan",
+			reservation:    Reservation{ID: 1, BookId: 1, UserId: 2, DueDate: &due, Status: StatusActive},
+			userId:         1,
+			expectedKind:   ErrNotOwner,
+			expectedStatus: StatusActive,
+		},
+		{
+			testName:       "Return twice",
+			reservation:    Reservation{ID: 1, BookId: 1, UserId: 1, ReturnDate: &returned, Status: StatusReturned},
+			userId:         1,
+			expectedKind:   ErrInvalidTransition,
+			expectedStatus: StatusReturned,
+		},
+		{
+			testName:       "Return a reservation that was not picked up",
+			reservation:    Reservation{ID: 1, BookId: 1, UserId: 1, Status: StatusRequested},
+			userId:         1,
+			expectedStatus: StatusReturned,
+			expectedCopies: 1,
+		},
+		{
+			testName:       "Return a cancelled reservation",
+			reservation:    Reservation{ID: 1, BookId: 1, UserId: 1, Status: StatusCancelled},
+			userId:         1,
+			expectedKind:   ErrInvalidTransition,
+			expectedStatus: StatusCancelled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			service, repo, books := newTestService([]Book{{ID: 1}}, []Reservation{tc.reservation})
+
+			_, err := service.Return(context.Background(), tc.userId, 1, "")
+			expectKind(t, err, tc.expectedKind)
+
+			stored, _ := repo.GetById(context.Background(), 1)
+			if stored.Status != tc.expectedStatus {
+				t.Errorf("Expected status %s; got %s", tc.expectedStatus, stored.Status)
+			}
+			if copies := availableCopies(t, books, 1); copies != tc.expectedCopies {
+				t.Errorf("Expected AvailableCopies %d; got %d", tc.expectedCopies, copies)
+			}
+		})
+	}
+}
+
+func TestServicePickUp(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		copies         []Copy
+		barcode        string
+		expectedKind   error
+		expectedStatus Status
+	}{
+		{
+			testName:       "Pick up an untracked book",
+			expectedStatus: StatusActive,
+		},
+		{
+			testName:       "Pick up a tracked book without a scan",
+			copies:         []Copy{{BookId: 1, Barcode: "A-1", Condition: CopyGood}},
+			expectedKind:   ErrInvalidRequest,
+			expectedStatus: StatusRequested,
+		},
+		{
+			testName:       "Pick up a damaged copy",
+			copies:         []Copy{{BookId: 1, Barcode: "A-1", Condition: CopyDamaged}},
+			barcode:        "A-1",
+			expectedKind:   ErrUnavailable,
+			expectedStatus: StatusRequested,
+		},
+		{
+			testName:       "Pick up a copy of another book",
+			copies:         []Copy{{BookId: 2, Barcode: "B-1", Condition: CopyGood}},
+			barcode:        "B-1",
+			expectedKind:   ErrInvalidRequest,
+			expectedStatus: StatusRequested,
+		},
+		{
+			testName:       "Pick up a copy shelved at another branch",
+			copies:         []Copy{{BookId: 1, Barcode: "A-1", Branch: "east", Condition: CopyGood}},
+			barcode:        "A-1",
+			expectedKind:   ErrInvalidRequest,
+			expectedStatus: StatusRequested,
+		},
+		{
+			testName:       "Pick up a scanned copy",
+			copies:         []Copy{{BookId: 1, Barcode: "A-1", Branch: "main", Condition: CopyWorn}},
+			barcode:        "A-1",
+			expectedStatus: StatusActive,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			service, repo, _ := newTestService([]Book{{ID: 1}},
+				[]Reservation{{ID: 1, BookId: 1, UserId: 1, Branch: "main", Status: StatusRequested}})
+			for _, c := range tc.copies {
+				_, err := repo.AddCopy(context.Background(), c)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			picked, err := service.PickUp(context.Background(), 1, 1, tc.barcode)
+			expectKind(t, err, tc.expectedKind)
+
+			stored, _ := repo.GetById(context.Background(), 1)
+			if stored.Status != tc.expectedStatus {
+				t.Errorf("Expected status %s; got %s", tc.expectedStatus, stored.Status)
+			}
+			if tc.expectedKind == nil && picked.DueDate == nil {
+				t.Errorf("Expected a due date; got %+v", picked)
+			}
+		})
+	}
+}
+
+func TestServiceAddCopy(t *testing.T) {
+	repo := NewMockReservationRepo(nil)
+	service := NewService(repo, NewMockBookClient([]Book{{ID: 1}}), events.NewMemoryPublisher(),
+		Policy{LoanPeriod: 14 * 24 * time.Hour, DefaultBranch: "main", Branches: map[string]BranchRules{"main": {}}})
+
+	testCases := []struct {
+		testName     string
+		copy         Copy
+		expectedKind error
+	}{
+		{"Add a copy without a barcode", Copy{BookId: 1, Branch: "main"}, ErrInvalidRequest},
+		{"Add a copy to an unknown branch", Copy{BookId: 1, Barcode: "A-1", Branch: "east"}, ErrInvalidRequest},
+		{"Add a copy", Copy{BookId: 1, Barcode: "A-2", Branch: "main"}, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := service.AddCopy(context.Background(), tc.copy)
+			expectKind(t, err, tc.expectedKind)
+		})
+	}
+}
+
+func TestServiceMarkLost(t *testing.T) {
+	copyId := int64(1)
+	service, repo, books := newTestService([]Book{{ID: 1, AvailableCopies: 0}},
+		[]Reservation{{ID: 1, BookId: 1, UserId: 1, CopyId: &copyId, Status: StatusOverdue}})
+	_, err := repo.AddCopy(context.Background(), Copy{BookId: 1, Barcode: "A-1", Condition: CopyGood})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed, chargeId, err := service.MarkLost(context.Background(), 99, 1,
+		LossReport{Condition: StatusLost, ReplacementCostCents: 2500})
+	expectKind(t, err, nil)
+	if closed.Status != StatusLost || chargeId == 0 {
+		t.Errorf("Expected a lost reservation with a charge; got %+v and charge %d", closed, chargeId)
+	}
+
+	c, _ := repo.GetCopyByBarcode(context.Background(), "A-1")
+	if c.Condition != CopyLost {
+		t.Errorf("Expected copy condition %s; got %s", CopyLost, c.Condition)
+	}
+	if copies := availableCopies(t, books, 1); copies != 0 {
+		t.Errorf("Expected AvailableCopies %d; got %d", 0, copies)
+	}
+
+	_, _, err = service.MarkLost(context.Background(), 99, 1, LossReport{Condition: StatusDamaged})
+	expectKind(t, err, ErrInvalidTransition)
+
+	// The patron finds the copy and hands it in.
+	returned, err := service.Return(context.Background(), 1, 1, "")
+	expectKind(t, err, nil)
+	if returned.Status != StatusReturned {
+		t.Errorf("Expected status %s; got %s", StatusReturned, returned.Status)
+	}
+	c, _ = repo.GetCopyByBarcode(context.Background(), "A-1")
+	if !c.Condition.IsLendable() {
+		t.Errorf("Expected a lendable copy; got %s", c.Condition)
+	}
+	available, _, err := repo.CountAvailableCopies(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available != 1 {
+		t.Errorf("Expected 1 available copy; got %d", available)
+	}
+}
+
+// staleBookClient reports one copy more than the book service has, like an
+// outdated local catalog.
+type staleBookClient struct {
+	*MockBookClient
+}
+
+func (c staleBookClient) GetBook(ctx context.Context, bookID int64) (Book, error) {
+	book, err := c.MockBookClient.GetBook(ctx, bookID)
+	book.AvailableCopies++
+	return book, err
+}
+
+func TestServiceReserveStaleCount(t *testing.T) {
+	repo := NewMockReservationRepo(nil)
+	books := NewMockBookClient([]Book{{ID: 1, AvailableCopies: 0}})
+	service := NewService(repo, staleBookClient{books}, events.NewMemoryPublisher(),
+		Policy{CancelWindow: time.Hour, LoanPeriod: 14 * 24 * time.Hour, DefaultBranch: "main"})
+
+	_, err := service.Reserve(context.Background(), 1, 1, ReserveOptions{})
+	expectKind(t, err, ErrUnavailable)
+	if len(repo.reservation) != 0 {
+		t.Errorf("Expected no reservation; got %+v", repo.reservation)
+	}
+	if copies := availableCopies(t, books, 1); copies != 0 {
+		t.Errorf("Expected AvailableCopies %d; got %d", 0, copies)
+	}
+
+	batch, err := service.ReserveBatch(context.Background(), 1, []int64{1}, "", false)
+	expectKind(t, err, nil)
+	if len(batch.Results) != 1 || batch.Results[0].Status != ItemRejected || len(repo.reservation) != 0 {
+		t.Errorf("Expected the item to be rejected; got %+v", batch)
+	}
+}
+
+func TestServiceReserveBatch(t *testing.T) {
+	testCases := []struct {
+		testName        string
+		reservations    []Reservation
+		bookIds         []int64
+		atomic          bool
+		expectedApplied bool
+		expectedStatus  []string
+		expectedCopies  map[int64]int64
+	}{
+		{
+			testName:        "Reserve the available books",
+			bookIds:         []int64{1, 2, 2},
+			expectedApplied: true,
+			expectedStatus:  []string{ItemOK, ItemOK, ItemRejected},
+			expectedCopies:  map[int64]int64{1: 1, 2: 0},
+		},
+		{
+			testName:        "Refuse an atomic batch",
+			bookIds:         []int64{1, 2, 2},
+			atomic:          true,
+			expectedApplied: false,
+			expectedStatus:  []string{ItemSkipped, ItemSkipped, ItemRejected},
+			expectedCopies:  map[int64]int64{1: 2, 2: 1},
+		},
+		{
+			testName: "Leave the copies promised to bookings",
+			reservations: []Reservation{
+				{ID: 1, BookId: 1, UserId: 2, Status: StatusBooked, StartDate: ptr(time.Now().Add(48 * time.Hour)),
+					EndDate: ptr(time.Now().Add(96 * time.Hour))},
+				{ID: 2, BookId: 2, UserId: 2, Status: StatusBooked, StartDate: ptr(time.Now().Add(48 * time.Hour)),
+					EndDate: ptr(time.Now().Add(96 * time.Hour))},
+			},
+			bookIds:         []int64{1, 2},
+			expectedApplied: true,
+			expectedStatus:  []string{ItemOK, ItemRejected},
+			expectedCopies:  map[int64]int64{1: 1, 2: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			service, _, books := newTestService([]Book{{ID: 1, AvailableCopies: 2}, {ID: 2, AvailableCopies: 1}}, tc.reservations)
+
+			batch, err := service.ReserveBatch(context.Background(), 1, tc.bookIds, "", tc.atomic)
+			expectKind(t, err, nil)
+
+			if batch.Applied != tc.expectedApplied {
+				t.Errorf("Expected Applied %v; got %v", tc.expectedApplied, batch.Applied)
+			}
+			for i, result := range batch.Results {
+				if result.Status != tc.expectedStatus[i] {
+					t.Errorf("Item %d: expected status %s; got %s", i, tc.expectedStatus[i], result.Status)
+				}
+			}
+			for bookId, expected := range tc.expectedCopies {
+				if copies := availableCopies(t, books, bookId); copies != expected {
+					t.Errorf("Book %d: expected AvailableCopies %d; got %d", bookId, expected, copies)
+				}
+			}
+		})
+	}
+}
+
+// missingRepo reports every reservation as missing, like the SQL
+// repository does for an unknown ID.
+type missingRepo struct {
+	*MockReservationRepo
+}
+
+func (missingRepo) GetById(ctx context.Context, id int64) (Reservation, error) {
+	return Reservation{}, sql.ErrNoRows
+}
+
+func TestServiceNotFound(t *testing.T) {
+	service := NewService(missingRepo{NewMockReservationRepo(nil)}, NewMockBookClient(nil),
+		events.NewMemoryPublisher(), Policy{})
+	ctx := context.Background()
+
+	_, err := service.Get(ctx, 1, false, 1)
+	expectKind(t, err, ErrNotFound)
+	_, err = service.Return(ctx, 1, 1, "")
+	expectKind(t, err, ErrNotFound)
+	_, err = service.PickUp(ctx, 1, 1, "")
+	expectKind(t, err, ErrNotFound)
+	_, err = service.History(ctx, 1, true, 1)
+	expectKind(t, err, ErrNotFound)
+	_, err = service.CopyByBarcode(ctx, "unknown")
+	expectKind(t, err, ErrNotFound)
+}
+
+// racedReturnRepo lets another request return the reservation between the
+// Service's checks and its own UpdateReturnDate.
+type racedReturnRepo struct {
+	*MockReservationRepo
+}
+
+func (r racedReturnRepo) UpdateReturnDate(ctx context.Context, id int64) (int64, error) {
+	_, err := r.MockReservationRepo.UpdateReturnDate(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	return r.MockReservationRepo.UpdateReturnDate(ctx, id)
+}
+
+func (r racedReturnRepo) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return r.MockReservationRepo.WithTx(ctx, func(Repository) error { return fn(r) })
+}
+
+func TestServiceReturnConflict(t *testing.T) {
+	due := time.Now().Add(24 * time.Hour)
+	repo := NewMockReservationRepo([]Reservation{
+		{ID: 1, BookId: 1, UserId: 1, DueDate: &due, Status: StatusActive},
+		{ID: 2, BookId: 1, UserId: 1, DueDate: &due, Status: StatusActive},
+	})
+	books := NewMockBookClient([]Book{{ID: 1}})
+	service := NewService(racedReturnRepo{repo}, books, events.NewMemoryPublisher(), Policy{LoanPeriod: 14 * 24 * time.Hour})
+	ctx := context.Background()
+
+	_, err := service.Return(ctx, 1, 1, "")
+	expectKind(t, err, ErrConflict)
+
+	batch, err := service.ReturnBatch(ctx, 1, []int64{2}, nil, false)
+	expectKind(t, err, nil)
+	if batch.Results[0].Status != ItemFailed {
+		t.Errorf("Expected the item to fail; got %+v", batch.Results[0])
+	}
+
+	// The copies were restored by the request that won.
+	if copies := availableCopies(t, books, 1); copies != 0 {
+		t.Errorf("Expected AvailableCopies 0; got %d", copies)
+	}
+	history, _ := repo.GetEvents(ctx, 1)
+	if len(history) != 0 {
+		t.Errorf("Expected no history; got %+v", history)
+	}
+}
+
+// concurrentChangeRepo stores a change made by another request between the
+// Service reading a reservation and recording its own change.
+type concurrentChangeRepo struct {
+	*MockReservationRepo
+}
+
+func (r concurrentChangeRepo) IncrementVersion(ctx context.Context, id, version int64) (int64, error) {
+	_, err := r.MockReservationRepo.IncrementVersion(ctx, id, version)
+	if err != nil {
+		return 0, err
+	}
+	return r.MockReservationRepo.IncrementVersion(ctx, id, version)
+}
+
+func (r concurrentChangeRepo) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return r.MockReservationRepo.WithTx(ctx, func(Repository) error { return fn(r) })
+}
+
+func TestServiceStaleVersion(t *testing.T) {
+	due := time.Now().Add(24 * time.Hour)
+	loan := Reservation{ID: 1, BookId: 1, UserId: 1, DueDate: &due, Status: StatusActive, Version: 2}
+
+	t.Run("Expected version is stale", func(t *testing.T) {
+		service, repo, _ := newTestService([]Book{{ID: 1}}, []Reservation{loan})
+
+		_, err := service.Renew(WithExpectedVersion(context.Background(), 1), 1, 1)
+		expectKind(t, err, ErrStaleVersion)
+
+		stored, _ := repo.GetById(context.Background(), 1)
+		if !stored.DueDate.Equal(due) || stored.Version != 2 {
+			t.Errorf("Expected the loan unchanged; got %+v", stored)
+		}
+	})
+
+	t.Run("Changed concurrently", func(t *testing.T) {
+		repo := NewMockReservationRepo([]Reservation{loan})
+		service := NewService(concurrentChangeRepo{repo}, NewMockBookClient([]Book{{ID: 1}}), events.NewMemoryPublisher(),
+			Policy{LoanPeriod: 14 * 24 * time.Hour})
+
+		_, err := service.Renew(WithExpectedVersion(context.Background(), 2), 1, 1)
+		expectKind(t, err, ErrStaleVersion)
+
+		stored, _ := repo.GetById(context.Background(), 1)
+		if !stored.DueDate.Equal(due) {
+			t.Errorf("Expected the renewal to be rolled back; got %+v", stored)
+		}
+	})
+
+	t.Run("Expected version is current", func(t *testing.T) {
+		service, _, _ := newTestService([]Book{{ID: 1}}, []Reservation{loan})
+
+		renewed, err := service.Renew(WithExpectedVersion(context.Background(), 2), 1, 1)
+		expectKind(t, err, nil)
+		if renewed.Version != 3 {
+			t.Errorf("Expected version 3; got %d", renewed.Version)
+		}
+	})
+}
+
+// failingEventsRepo cannot record history, so every change must be rolled
+// back.
+type failingEventsRepo struct {
+	*MockReservationRepo
+}
+
+func (failingEventsRepo) AddEvent(ctx context.Context, event Event) error {
+	return errors.New("simulated error recording event")
+}
+
+func (r failingEventsRepo) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return r.MockReservationRepo.WithTx(ctx, func(Repository) error { return fn(r) })
+}
+
+func TestServiceRollsBack(t *testing.T) {
+	due := time.Now().Add(24 * time.Hour)
+	repo := NewMockReservationRepo([]Reservation{
+		{ID: 1, BookId: 1, UserId: 1, DueDate: &due, Status: StatusActive},
+		{ID: 2, BookId: 1, UserId: 1, DueDate: &due, Status: StatusActive},
+	})
+	books := NewMockBookClient([]Book{{ID: 1, AvailableCopies: 1}})
+	publisher := events.NewMemoryPublisher()
+	service := NewService(failingEventsRepo{repo}, books, publisher, Policy{LoanPeriod: 14 * 24 * time.Hour})
+	ctx := context.Background()
+
+	expectInternal := func(err error) {
+		t.Helper()
+		var serviceErr *Error
+		if !errors.As(err, &serviceErr) || serviceErr.Kind != nil {
+			t.Fatalf("Expected an internal error; got %v", err)
+		}
+	}
+
+	_, err := service.Return(ctx, 1, 1, "")
+	expectInternal(err)
+
+	_, err = service.Reserve(ctx, 2, 1, ReserveOptions{})
+	expectInternal(err)
+
+	batch, err := service.ReturnBatch(ctx, 1, []int64{1, 2}, nil, false)
+	expectKind(t, err, nil)
+	for i, result := range batch.Results {
+		if result.Status != ItemFailed {
+			t.Errorf("Item %d: expected status %s; got %s", i, ItemFailed, result.Status)
+		}
+	}
+
+	_, err = service.ReturnBatch(ctx, 1, []int64{1, 2}, nil, true)
+	expectInternal(err)
+
+	all, _ := repo.GetAll(ctx, Filter{})
+	if len(all) != 2 {
+		t.Errorf("Expected 2 reservations; got %d", len(all))
+	}
+	for _, res := range all {
+		if res.Status != StatusActive || res.ReturnDate != nil {
+			t.Errorf("Expected reservation %d to stay active; got %+v", res.ID, res)
+		}
+	}
+	if copies := availableCopies(t, books, 1); copies != 1 {
+		t.Errorf("Expected AvailableCopies %d; got %d", 1, copies)
+	}
+	if published := publisher.Events(); len(published) != 0 {
+		t.Errorf("Expected no published events; got %+v", published)
+	}
+}
@@ -0,0 +1,313 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shkuran/go-library-microservices/reservation-service/db"
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	"github.com/shkuran/go-library-microservices/reservation-service/notifier"
+)
+
+// newSQLiteRepo returns a Repo on a fresh in-memory SQLite database.
+func newSQLiteRepo(t *testing.T) *Repo {
+	t.Helper()
+	conn, err := sql.Open("sqlite", db.SQLiteDSN(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+
+	err = db.Migrate(conn, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLiteRepo(conn)
+}
+
+func TestSQLiteRepoLifecycle(t *testing.T) {
+	repo := newSQLiteRepo(t)
+	service := NewService(repo, NewMockBookClient([]Book{{ID: 1, AvailableCopies: 2}}), events.NewMemoryPublisher(),
+		Policy{CancelWindow: time.Hour, LoanPeriod: 14 * 24 * time.Hour, DefaultBranch: "main"})
+	ctx := context.Background()
+
+	created, err := service.Reserve(ctx, 1, 1, ReserveOptions{})
+	expectKind(t, err, nil)
+	if created.Status != StatusRequested || created.Version != 1 {
+		t.Fatalf("Unexpected reservation %+v", created)
+	}
+
+	_, err = service.Reserve(ctx, 1, 1, ReserveOptions{})
+	expectKind(t, err, ErrConflict)
+
+	picked, err := service.PickUp(ctx, 1, created.ID, "")
+	expectKind(t, err, nil)
+	if picked.PickedUpAt == nil || picked.PickedUpAt.Before(created.CheckoutDate) {
+		t.Errorf("Expected the pickup time to be set; got %+v", picked)
+	}
+
+	renewed, err := service.Renew(WithExpectedVersion(ctx, 2), 1, created.ID)
+	expectKind(t, err, nil)
+	if renewed.Version != 3 {
+		t.Errorf("Expected version 3; got %d", renewed.Version)
+	}
+
+	_, err = service.Return(WithExpectedVersion(ctx, 2), 1, created.ID, "")
+	expectKind(t, err, ErrStaleVersion)
+
+	returned, err := service.Return(WithExpectedVersion(ctx, 3), 1, created.ID, "")
+	expectKind(t, err, nil)
+	if returned.Status != StatusReturned || returned.ReturnDate == nil {
+		t.Errorf("Unexpected reservation %+v", returned)
+	}
+
+	_, err = service.Return(ctx, 1, created.ID, "")
+	expectKind(t, err, ErrInvalidTransition)
+
+	history, err := service.History(ctx, 1, false, created.ID)
+	expectKind(t, err, nil)
+	expectedTypes := []EventType{EventCreated, EventPickedUp, EventRenewed, EventCompleted}
+	if len(history) != len(expectedTypes) {
+		t.Fatalf("Expected %d events; got %+v", len(expectedTypes), history)
+	}
+	for i, event := range history {
+		if event.Type != expectedTypes[i] || event.After == nil {
+			t.Errorf("Event %d: expected %s with a snapshot; got %+v", i, expectedTypes[i], event)
+		}
+	}
+
+	// A returned book can be reserved again.
+	_, err = service.Reserve(ctx, 1, 1, ReserveOptions{})
+	expectKind(t, err, nil)
+}
+
+func TestCountOverlappingRenewedBooking(t *testing.T) {
+	repos := map[string]Repository{
+		"SQLite": newSQLiteRepo(t),
+		"Mock":   NewMockReservationRepo(nil),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+			end := now.Add(7 * 24 * time.Hour)
+			id, err := repo.Save(ctx, Reservation{BookId: 1, UserId: 1, Status: StatusBooked, StartDate: ptr(now.Add(-time.Hour)), EndDate: &end})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = repo.StartBooking(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = repo.Activate(ctx, id, nil, end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = repo.Renew(ctx, id, end, end.Add(14*24*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The loan keeps its copy until the renewed due date, past the
+			// end of the booking it started as.
+			overlapping, err := repo.CountOverlapping(ctx, 1, end.Add(24*time.Hour), end.Add(48*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if overlapping != 1 {
+				t.Errorf("Expected the renewed loan to overlap; got %d", overlapping)
+			}
+		})
+	}
+}
+
+func TestSQLiteNotifications(t *testing.T) {
+	repo := newSQLiteRepo(t)
+	ctx := context.Background()
+	id, err := repo.Save(ctx, Reservation{BookId: 1, UserId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Now().Add(24 * time.Hour)
+	n := notifier.Notification{Kind: notifier.KindDueSoon, ReservationId: id, DueDate: due}
+	err = repo.RecordNotification(ctx, n, "smtp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed := n
+	renewed.DueDate = due.Add(14 * 24 * time.Hour)
+	testCases := []struct {
+		testName     string
+		notification notifier.Notification
+		channel      string
+		expectedSent bool
+	}{
+		{"Sent", n, "smtp", true},
+		{"Other channel", n, "webhook", false},
+		{"Renewed", renewed, "smtp", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			sent, err := repo.WasNotified(ctx, tc.notification, tc.channel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent != tc.expectedSent {
+				t.Errorf("Expected sent %v; got %v", tc.expectedSent, sent)
+			}
+		})
+	}
+}
+
+func TestSQLiteRepoWithTx(t *testing.T) {
+	repo := newSQLiteRepo(t)
+	ctx := context.Background()
+	failure := errors.New("simulated failure")
+
+	err := repo.WithTx(ctx, func(tx Repository) error {
+		_, err := tx.Save(ctx, Reservation{BookId: 1, UserId: 1})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected %v; got %v", failure, err)
+	}
+
+	all, err := repo.GetAll(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Errorf("Expected no reservations; got %+v", all)
+	}
+
+	// A failing nested transaction only rolls back its own changes.
+	err = repo.WithTx(ctx, func(tx Repository) error {
+		_, err := tx.Save(ctx, Reservation{BookId: 1, UserId: 1})
+		if err != nil {
+			return err
+		}
+		err = tx.WithTx(ctx, func(nested Repository) error {
+			_, err := nested.Save(ctx, Reservation{BookId: 2, UserId: 1})
+			if err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("Expected %v; got %v", failure, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err = repo.GetAll(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].BookId != 1 {
+		t.Errorf("Expected only the reservation of book 1; got %+v", all)
+	}
+}
+
+func TestSQLiteImport(t *testing.T) {
+	repo := newSQLiteRepo(t)
+	ctx := context.Background()
+
+	_, err := repo.Save(ctx, Reservation{BookId: 1, UserId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reservation 1 of the file is refused: the requested reservation 1 is
+	// still open.
+	file := export(t, exportFixture(), FormatCSV)
+
+	report, err := Import(ctx, repo, strings.NewReader(file), ImportOptions{Format: FormatCSV, DryRun: true, Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 || len(report.Rejected) != 1 || report.Rejected[0].Line != 2 || report.Rejected[0].ID != 1 {
+		t.Fatalf("Unexpected dry run report %+v", report)
+	}
+	all, err := repo.GetAll(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Status != StatusRequested {
+		t.Fatalf("Expected the dry run to change nothing; got %+v", all)
+	}
+	events, err := repo.GetEvents(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("Expected the dry run to record no events; got %+v", events)
+	}
+
+	report, err = Import(ctx, repo, strings.NewReader(file), ImportOptions{Format: FormatCSV, BatchSize: 2, Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 || len(report.Rejected) != 1 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+
+	var exported []Reservation
+	err = repo.Each(ctx, Filter{Branch: "east"}, func(res Reservation) error {
+		exported = append(exported, res)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectSameReservations(t, exportFixture()[2:], exported)
+
+	// Importing a reservation again is refused unless told to overwrite it,
+	// which replaces it under a new version.
+	again := export(t, exportFixture()[1:2], FormatNDJSON)
+	report, err = Import(ctx, repo, strings.NewReader(again), ImportOptions{Format: FormatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 0 || len(report.Rejected) != 1 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	_, err = Import(ctx, repo, strings.NewReader(again), ImportOptions{Format: FormatNDJSON, Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaced, err := repo.GetById(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.Version != 3 || replaced.Status != StatusDamaged {
+		t.Errorf("Expected the damaged reservation at version 3; got %+v", replaced)
+	}
+	events, err = repo.GetEvents(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != EventImported || events[0].Before != nil ||
+		events[1].Before == nil || events[1].Before.Version != 2 || events[1].After.Version != 3 {
+		t.Errorf("Unexpected events %+v", events)
+	}
+
+	// Later reservations are numbered after the imported ones.
+	next, err := repo.Save(ctx, Reservation{BookId: 2, UserId: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 6 {
+		t.Errorf("Expected ID 6; got %d", next)
+	}
+}
@@ -0,0 +1,72 @@
+package reservation
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Status string
+
+const (
+	StatusBooked    Status = "booked"
+	StatusRequested Status = "requested"
+	StatusActive    Status = "active"
+	StatusOverdue   Status = "overdue"
+	StatusInTransit Status = "in_transit"
+	StatusReturned  Status = "returned"
+	StatusLost      Status = "lost"
+	StatusDamaged   Status = "damaged"
+	StatusCancelled Status = "cancelled"
+)
+
+var ErrInvalidTransition = errors.New("invalid reservation status transition")
+
+// transitions lists, for every status, the statuses a reservation may move to.
+var transitions = map[Status][]Status{
+	StatusBooked:    {StatusRequested, StatusCancelled},
+	StatusRequested: {StatusActive, StatusReturned, StatusCancelled},
+	StatusActive:    {StatusOverdue, StatusInTransit, StatusReturned, StatusLost, StatusDamaged},
+	StatusOverdue:   {StatusInTransit, StatusReturned, StatusLost, StatusDamaged},
+	StatusInTransit: {StatusReturned},
+	StatusLost:      {StatusInTransit, StatusReturned},
+	StatusDamaged:   {},
+	StatusReturned:  {},
+	StatusCancelled: {},
+}
+
+// CanTransitionTo reports whether the transition table allows s -> to.
+func (s Status) CanTransitionTo(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// returnable reports whether a reservation in status s can be completed.
+// Reservations stored before statuses were tracked have none.
+func (s Status) returnable() bool {
+	return s == "" || s.CanTransitionTo(StatusReturned)
+}
+
+// IsOpen reports whether the reservation still holds a copy of the book.
+// A copy returned at another branch is held until it arrives back home.
+func (s Status) IsOpen() bool {
+	return s == StatusRequested || s == StatusActive || s == StatusOverdue || s == StatusInTransit
+}
+
+// terminal reports whether a reservation in status s is closed for good.
+func (s Status) terminal() bool {
+	next, ok := transitions[s]
+	return ok && len(next) == 0
+}
+
+// ValidateTransition returns an error wrapping ErrInvalidTransition when
+// s -> to is not allowed.
+func ValidateTransition(from, to Status) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package reservation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateTransition(t *testing.T) {
+	testCases := []struct {
+		from    Status
+		to      Status
+		allowed bool
+	}{
+		{from: StatusRequested, to: StatusActive, allowed: true},
+		{from: StatusRequested, to: StatusCancelled, allowed: true},
+		{from: StatusRequested, to: StatusReturned, allowed: true},
+		{from: StatusActive, to: StatusReturned, allowed: true},
+		{from: StatusActive, to: StatusOverdue, allowed: true},
+		{from: StatusActive, to: StatusCancelled, allowed: false},
+		{from: StatusOverdue, to: StatusReturned, allowed: true},
+		{from: StatusLost, to: StatusReturned, allowed: true},
+		{from: StatusActive, to: StatusInTransit, allowed: true},
+		{from: StatusInTransit, to: StatusReturned, allowed: true},
+		{from: StatusInTransit, to: StatusActive, allowed: false},
+		{from: StatusCancelled, to: StatusReturned, allowed: false},
+		{from: StatusReturned, to: StatusActive, allowed: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.from)+"->"+string(tc.to), func(t *testing.T) {
+			err := ValidateTransition(tc.from, tc.to)
+			if tc.allowed && err != nil {
+				t.Errorf("Expected transition to be allowed; got %v", err)
+			}
+			if !tc.allowed && !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("Expected ErrInvalidTransition; got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package reservation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/utils"
+)
+
+// ETag renders a reservation version as a strong entity tag.
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseETag returns the reservation version named by an entity tag.
+func ParseETag(value string) (int64, error) {
+	if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return 0, fmt.Errorf("invalid entity tag %q", value)
+	}
+	return strconv.ParseInt(value[1:len(value)-1], 10, 64)
+}
+
+// versionedContext requires the If-Match header on a change to a reservation
+// and returns the service context expecting the version it names. "*" matches
+// any version. Otherwise the error is rendered and ok is false.
+func versionedContext(context *gin.Context) (ctx context.Context, ok bool) {
+	ctx = serviceContext(context)
+
+	header := context.Request.Header.Get("If-Match")
+	switch header {
+	case "":
+		utils.HandlePreconditionRequired(context, "The If-Match header is required!", nil)
+		return nil, false
+	case "*":
+		return ctx, true
+	}
+
+	version, err := ParseETag(header)
+	if err != nil {
+		utils.HandleBadRequest(context, "Could not parse If-Match!", err)
+		return nil, false
+	}
+	return WithExpectedVersion(ctx, version), true
+}
@@ -2,12 +2,48 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/catalog"
+	"github.com/shkuran/go-library-microservices/reservation-service/health"
+	"github.com/shkuran/go-library-microservices/reservation-service/logger"
+	"github.com/shkuran/go-library-microservices/reservation-service/metrics"
+	"github.com/shkuran/go-library-microservices/reservation-service/openapi"
+	"github.com/shkuran/go-library-microservices/reservation-service/report"
 	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func RegisterRoutes(server *gin.Engine, reservation reservation.Handler) {
+func RegisterRoutes(server *gin.Engine, serviceName string, reservation reservation.Handler, catalog catalog.Handler, reports report.Handler, health *health.Handler) {
+	server.Use(otelgin.Middleware(serviceName), logger.RequestID(), logger.AccessLog(), metrics.Middleware())
+
+	server.GET("/metrics", metrics.Handler())
+	server.GET("/healthz", health.Liveness)
+	server.GET("/readyz", health.Readiness)
+	server.GET("/startupz", health.Startup)
+	server.GET("/openapi.json", openapi.Handler)
+
 	server.GET("/reservations", reservation.GetReservations)
+	server.GET("/reservations/export", reservation.ExportReservations)
 	server.POST("/reservations", reservation.AddReservation)
+	server.POST("/reservations/batch", reservation.AddReservations)
+	server.POST("/reservations/batch/complete", reservation.CompleteReservations)
+	server.GET("/reservations/:id", reservation.GetReservation)
 	server.POST("/reservations/:id", reservation.CompleteReservation)
+	server.POST("/reservations/:id/renew", reservation.RenewReservation)
+	server.POST("/reservations/:id/pickup", reservation.PickUpReservation)
+	server.DELETE("/reservations/:id", reservation.CancelReservation)
+	server.POST("/reservations/:id/lost", reservation.MarkReservationLost)
+	server.POST("/reservations/:id/receive", reservation.ReceiveReservation)
+	server.GET("/reservations/:id/history", reservation.GetReservationHistory)
+
+	server.GET("/books/:id/availability", catalog.GetAvailability)
+	server.GET("/books/:id/copies", reservation.GetCopies)
+	server.POST("/books/:id/copies", reservation.AddCopy)
+	server.GET("/copies/:barcode", reservation.GetCopy)
+
+	server.GET("/reports/loans", reports.Loans)
+	server.GET("/reports/popular-books", reports.PopularBooks)
+	server.GET("/reports/loan-duration", reports.LoanDuration)
+	server.GET("/reports/overdue-rate", reports.OverdueRate)
+	server.GET("/reports/active-borrowers", reports.ActiveBorrowers)
 
 }
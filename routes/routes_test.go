@@ -0,0 +1,219 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/catalog"
+	"github.com/shkuran/go-library-microservices/reservation-service/events"
+	"github.com/shkuran/go-library-microservices/reservation-service/health"
+	"github.com/shkuran/go-library-microservices/reservation-service/openapi"
+	"github.com/shkuran/go-library-microservices/reservation-service/report"
+	"github.com/shkuran/go-library-microservices/reservation-service/reservation"
+)
+
+// setupServer registers all routes on a server that validates every request
+// and response against the OpenAPI document.
+func setupServer(t *testing.T) *gin.Engine {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("Invalid OpenAPI document: %v", err)
+	}
+	validator, err := openapi.NewValidator(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	due := now.Add(7 * 24 * time.Hour)
+	books := []reservation.Book{
+		{ID: 1, Title: "Dune", AvailableCopies: 3},
+		{ID: 2, Title: "Emma", AvailableCopies: 1},
+		{ID: 3, Title: "Ulysses", AvailableCopies: 2},
+		{ID: 4, Title: "Beloved", AvailableCopies: 1},
+		{ID: 5, Title: "Walden", AvailableCopies: 1},
+		{ID: 6, Title: "Persuasion", AvailableCopies: 1},
+	}
+	reservations := []reservation.Reservation{
+		{ID: 1, BookId: 1, UserId: 1, CheckoutDate: now, Branch: "main", Status: reservation.StatusRequested, StatusChangedAt: now},
+		{ID: 2, BookId: 1, UserId: 1, CheckoutDate: now, DueDate: &due, Branch: "main", Status: reservation.StatusActive, StatusChangedAt: now},
+		{ID: 3, BookId: 2, UserId: 1, CheckoutDate: now, Branch: "main", Status: reservation.StatusRequested, StatusChangedAt: now},
+		{ID: 4, BookId: 1, UserId: 1, CheckoutDate: now, DueDate: &due, Branch: "main", Status: reservation.StatusActive, StatusChangedAt: now},
+		{ID: 5, BookId: 2, UserId: 1, CheckoutDate: now, DueDate: &due, Branch: "main", Status: reservation.StatusActive, StatusChangedAt: now},
+		{ID: 6, BookId: 2, UserId: 2, CheckoutDate: now, Branch: "main", Status: reservation.StatusRequested, StatusChangedAt: now},
+	}
+
+	bookClient := reservation.NewMockBookClient(books)
+	cached := catalog.NewCachedBookClient(bookClient, catalog.NewMockStore(books))
+	policy := reservation.Policy{CancelWindow: time.Hour, LoanPeriod: 14 * 24 * time.Hour, DefaultBranch: "main"}
+	service := reservation.NewService(reservation.NewMockReservationRepo(reservations), cached, events.NewMemoryPublisher(), policy)
+	reservationHandler := reservation.NewHandler(service)
+	healthHandler := health.NewHandler(time.Second)
+	healthHandler.MarkStarted()
+
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(validator.Middleware(func(err error) { t.Error(err) }))
+	reportHandler := report.NewHandler(report.NewMockStore(reservations, now))
+	RegisterRoutes(server, "reservation-service", reservationHandler, catalog.NewHandler(service), reportHandler, healthHandler)
+	return server
+}
+
+func TestRoutesMatchSpec(t *testing.T) {
+	server := setupServer(t)
+
+	// The steps share one server, so later steps see the effects of earlier ones.
+	testCases := []struct {
+		testName     string
+		method       string
+		path         string
+		body         string
+		staff        bool
+		expectedCode int
+	}{
+		{"Spec", "GET", "/openapi.json", "", false, http.StatusOK},
+		{"Liveness", "GET", "/healthz", "", false, http.StatusOK},
+		{"Startup", "GET", "/startupz", "", false, http.StatusOK},
+		{"Readiness", "GET", "/readyz", "", false, http.StatusOK},
+		{"List reservations", "GET", "/reservations?branch=main&status=active", "", false, http.StatusOK},
+		{"Invalid filter", "GET", "/reservations?user_id=x", "", false, http.StatusBadRequest},
+		{"Export", "GET", "/reservations/export?branch=main", "", true, http.StatusOK},
+		{"Export as NDJSON", "GET", "/reservations/export?status=active&format=ndjson", "", true, http.StatusOK},
+		{"Export as patron", "GET", "/reservations/export", "", false, http.StatusForbidden},
+		{"Export in an unknown format", "GET", "/reservations/export?format=xml", "", true, http.StatusBadRequest},
+		{"Get reservation", "GET", "/reservations/1", "", false, http.StatusOK},
+		{"Get reservation of another user", "GET", "/reservations/6", "", false, http.StatusUnauthorized},
+		{"Reserve", "POST", "/reservations", `{"book_id": 3}`, false, http.StatusCreated},
+		{"Reserve a book already held", "POST", "/reservations", `{"book_id": 3}`, false, http.StatusConflict},
+		{"Return before pickup", "POST", "/reservations/7", "", false, http.StatusOK},
+		{"Reserve with malformed body", "POST", "/reservations", `{"book_id": "one"}`, false, http.StatusBadRequest},
+		{"Book in advance", "POST", "/reservations", `{"book_id": 4, "start_date": "` +
+			time.Now().Add(48*time.Hour).Format(time.RFC3339) + `", "end_date": "` +
+			time.Now().Add(96*time.Hour).Format(time.RFC3339) + `"}`, false, http.StatusCreated},
+		{"Batch checkout", "POST", "/reservations/batch", `{"book_ids": [5, 6]}`, false, http.StatusCreated},
+		{"Atomic batch checkout", "POST", "/reservations/batch", `{"book_ids": [2, 99], "atomic": true}`, false, http.StatusBadRequest},
+		{"Renew", "POST", "/reservations/2/renew", "", false, http.StatusOK},
+		{"Pick up", "POST", "/reservations/3/pickup", `{}`, false, http.StatusOK},
+		{"Return", "POST", "/reservations/2", `{"branch": "main"}`, false, http.StatusOK},
+		{"Return twice", "POST", "/reservations/2", "", false, http.StatusBadRequest},
+		{"Batch return", "POST", "/reservations/batch/complete", `{"reservation_ids": [4]}`, false, http.StatusOK},
+		{"Batch return of a changed reservation", "POST", "/reservations/batch/complete", `{"reservation_ids": [1], "versions": [99]}`, false, http.StatusPreconditionFailed},
+		{"Cancel", "DELETE", "/reservations/1", "", false, http.StatusOK},
+		{"Mark lost as patron", "POST", "/reservations/5/lost", `{"condition": "lost"}`, false, http.StatusForbidden},
+		{"Mark lost", "POST", "/reservations/5/lost", `{"condition": "lost", "replacement_cost_cents": 1500}`, true, http.StatusOK},
+		{"Receive", "POST", "/reservations/5/receive", "", true, http.StatusBadRequest},
+		{"History", "GET", "/reservations/1/history", "", false, http.StatusOK},
+		{"Availability", "GET", "/books/1/availability", "", false, http.StatusOK},
+		{"Add copy", "POST", "/books/2/copies", `{"barcode": "B-1", "branch": "main"}`, true, http.StatusCreated},
+		{"List copies", "GET", "/books/2/copies", "", false, http.StatusOK},
+		{"List copies of a book without copies", "GET", "/books/1/copies", "", false, http.StatusOK},
+		{"Get copy", "GET", "/copies/B-1", "", false, http.StatusOK},
+		{"Get unknown copy", "GET", "/copies/B-2", "", false, http.StatusNotFound},
+		{"Loans report", "GET", "/reports/loans?group_by=week", "", true, http.StatusOK},
+		{"Loans report as patron", "GET", "/reports/loans", "", false, http.StatusForbidden},
+		{"Loans report with bad dates", "GET", "/reports/loans?from=2026-02-01&to=2026-01-01", "", true, http.StatusBadRequest},
+		{"Popular books report", "GET", "/reports/popular-books?limit=3&format=csv", "", true, http.StatusOK},
+		{"Loan duration report", "GET", "/reports/loan-duration?group_by=book", "", true, http.StatusOK},
+		{"Overdue rate report", "GET", "/reports/overdue-rate", "", true, http.StatusOK},
+		{"Active borrowers report", "GET", "/reports/active-borrowers?group_by=day&format=csv", "", true, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var req *http.Request
+			if tc.body == "" {
+				req = httptest.NewRequest(tc.method, tc.path, nil)
+			} else {
+				req = httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req.Header.Set("UserID", "1")
+			req.Header.Set("If-Match", "*")
+			if tc.staff {
+				req.Header.Set("UserRole", "staff")
+			}
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status code %d, but got %d: %s", tc.expectedCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestVersionsMatchSpec(t *testing.T) {
+	server := setupServer(t)
+
+	testCases := []struct {
+		testName     string
+		method       string
+		path         string
+		ifMatch      string
+		expectedCode int
+	}{
+		{"Get reservation", "GET", "/reservations/2", "", http.StatusOK},
+		{"Renew without If-Match", "POST", "/reservations/2/renew", "", http.StatusPreconditionRequired},
+		{"Renew a stale version", "POST", "/reservations/2/renew", `"7"`, http.StatusPreconditionFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("UserID", "1")
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected status code %d, but got %d: %s", tc.expectedCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAllRoutesInSpec(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("Invalid OpenAPI document: %v", err)
+	}
+	server := setupServer(t)
+
+	registered := make(map[string]bool)
+	for _, route := range server.Routes() {
+		path := toSpecPath(route.Path)
+		registered[route.Method+" "+path] = true
+
+		item := doc.Paths.Value(path)
+		if item == nil || item.GetOperation(route.Method) == nil {
+			t.Errorf("%s %s is not described in the spec", route.Method, route.Path)
+		}
+	}
+
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			if !registered[method+" "+path] {
+				t.Errorf("%s %s is described in the spec but not served", method, path)
+			}
+		}
+	}
+}
+
+// toSpecPath turns gin's :param segments into OpenAPI {param} segments.
+func toSpecPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -1,33 +1,63 @@
 package utils
 
 import (
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shkuran/go-library-microservices/reservation-service/logger"
 )
 
 func HandleBadRequest(context *gin.Context, message string, err error) {
-	context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": message})
-	if err != nil {
-		log.Println(err)
-	}
+	handleError(context, http.StatusBadRequest, message, err)
 }
 
 func HandleInternalServerError(context *gin.Context, message string, err error) {
-	context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": message})
-	if err != nil {
-		log.Println(err)
-	}
+	handleError(context, http.StatusInternalServerError, message, err)
 }
 
 func HandleStatusUnauthorized(context *gin.Context, message string, err error) {
-	context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": message})
-	if err != nil {
-		log.Println(err)
-	}
+	handleError(context, http.StatusUnauthorized, message, err)
+}
+
+func HandleStatusForbidden(context *gin.Context, message string, err error) {
+	handleError(context, http.StatusForbidden, message, err)
+}
+
+func HandleNotFound(context *gin.Context, message string, err error) {
+	handleError(context, http.StatusNotFound, message, err)
+}
+
+func HandleConflict(context *gin.Context, message string, err error) {
+	handleError(context, http.StatusConflict, message, err)
+}
+
+func HandlePreconditionFailed(context *gin.Context, message string, err error) {
+	handleError(context, http.StatusPreconditionFailed, message, err)
+}
+
+func HandlePreconditionRequired(context *gin.Context, message string, err error) {
+	handleError(context, http.StatusPreconditionRequired, message, err)
 }
 
 func HandleStatusCreated(context *gin.Context, message string) {
 	context.JSON(http.StatusCreated, gin.H{"message": message})
 }
+
+// handleError aborts with message and the request ID, and logs err (if any)
+// together with the request it belongs to.
+func handleError(context *gin.Context, code int, message string, err error) {
+	body := gin.H{"message": message}
+	requestID := logger.RequestIDFromContext(context.Request.Context())
+	if requestID != "" {
+		body["request_id"] = requestID
+	}
+	context.AbortWithStatusJSON(code, body)
+	if err != nil {
+		logger.FromContext(context.Request.Context()).Error(message,
+			"error", err,
+			"status", code,
+			"method", context.Request.Method,
+			"path", context.Request.URL.Path,
+		)
+	}
+}
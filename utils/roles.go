@@ -0,0 +1,8 @@
+package utils
+
+import "github.com/gin-gonic/gin"
+
+// IsStaff reports whether the gateway marked the caller as library staff.
+func IsStaff(context *gin.Context) bool {
+	return context.Request.Header.Get("UserRole") == "staff"
+}